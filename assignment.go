@@ -8,6 +8,8 @@ type Assignable interface {
 }
 
 type Assignment struct {
+	// table 列所在的表，用于多表 UPDATE，为 nil 的时候就是要更新的模型
+	table  TableReference
 	column string
 	val    Expression
 }
@@ -27,6 +29,14 @@ func Assign(column string, val any) Assignment {
 	}
 }
 
+// Assign 给当前列赋值，和 Assign 函数不同的是会带上列所在的表，
+// 多表 UPDATE 的时候用于更新别名对应的表，例如 TableOf(&Order{}).As("o").C("Region").Assign(u.C("Region"))
+func (c Column) Assign(val any) Assignment {
+	a := Assign(c.name, val)
+	a.table = c.table
+	return a
+}
+
 // assign 方法是 Assignment 类的一个私有方法(所以在这里func(a Assignment)assign()就没有使用指针，因为私有方法不能修改)
 // 它负责执行具体的分配逻辑，该逻辑在 Assignment 类的实例化对象中被调用
 // 该方法目前没有参数和返回值，它的作用是封装分配相关的操作，增强代码的模块化和可维护性
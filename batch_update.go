@@ -0,0 +1,211 @@
+package sorm
+
+import (
+	"context"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/internal/valuer"
+	"github.com/xzhHas/sorm/model"
+)
+
+// BatchUpdater 用一条语句把多行更新成各自不同的值，生成的语句形如：
+// UPDATE t SET score = CASE id WHEN ? THEN ? ... END WHERE id IN (?, ...)
+// 行数太多的时候会按照数据库方言的参数限制自动分批执行
+type BatchUpdater[T any] struct {
+	builder
+	// values 要更新的行，每一行按照 key 找到数据库里面对应的行
+	values []*T
+	// sets 要更新的字段
+	sets []string
+	// key 用于定位行的字段，没有指定的时候使用主键
+	key string
+	// batchSize 分批更新的时候，每一批最多多少行，0 表示只受数据库参数数量的限制
+	batchSize int
+	// inTx 分批更新的时候，是否在同一个事务里面执行全部批次
+	inTx bool
+	sess Session
+}
+
+// NewBatchUpdater 创建并返回一个新的 BatchUpdater 实例
+func NewBatchUpdater[T any](sess Session) *BatchUpdater[T] {
+	c := coreOf(sess)
+	return &BatchUpdater[T]{
+		builder: builder{
+			core:    c,
+			dialect: c.dialect,
+			quoter:  c.dialect.quoter(),
+		},
+		sess: sess,
+	}
+}
+
+// Values 指定要更新的行，多次调用的时候以最后一次为准
+func (b *BatchUpdater[T]) Values(rows ...*T) *BatchUpdater[T] {
+	b.values = rows
+	return b
+}
+
+// Set 指定要更新的字段，每一行都会被更新成 Values 里面对应字段的值
+func (b *BatchUpdater[T]) Set(fields ...string) *BatchUpdater[T] {
+	b.sets = fields
+	return b
+}
+
+// Key 指定用于定位行的字段，它的值在 Values 里面应该是唯一的。
+// 没有指定的时候使用主键，此时模型必须有且只有一个主键
+func (b *BatchUpdater[T]) Key(field string) *BatchUpdater[T] {
+	b.key = field
+	return b
+}
+
+// BatchSize 指定分批更新的时候，每一批最多更新多少行
+// 不管设置多大，每一批的参数数量都不会超过数据库方言的限制
+func (b *BatchUpdater[T]) BatchSize(n int) *BatchUpdater[T] {
+	b.batchSize = n
+	return b
+}
+
+// InTx 指定分批更新的时候，全部批次在同一个事务里面执行
+// 任何一批失败都会导致整个事务回滚。如果 BatchUpdater 本身就是在事务里面创建的，那么直接复用该事务
+func (b *BatchUpdater[T]) InTx() *BatchUpdater[T] {
+	b.inTx = true
+	return b
+}
+
+// Build 构建批量更新语句，不会分批，分批是在 Exec 里面完成的
+func (b *BatchUpdater[T]) Build() (*Query, error) {
+	if len(b.values) == 0 {
+		return nil, errs.ErrUpdateZeroRow
+	}
+	if len(b.sets) == 0 {
+		return nil, errs.ErrNoUpdatedColumns
+	}
+	m, err := b.r.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	b.model = m
+	keyFd, err := b.keyField(m)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]any, 0, len(b.values))
+	vals := make([]valuer.Value, 0, len(b.values))
+	for _, row := range b.values {
+		val := b.valCreator(row, m)
+		key, err := val.Field(keyFd.GoName)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		vals = append(vals, val)
+	}
+	b.args = make([]any, 0, len(b.values)*(2*len(b.sets)+1))
+	b.sb.WriteString("UPDATE ")
+	b.quote(b.modelTable(m))
+	b.sb.WriteString(" SET ")
+	for i, fd := range b.sets {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err = b.buildColumn(nil, fd); err != nil {
+			return nil, err
+		}
+		b.sb.WriteString("=CASE ")
+		b.quote(keyFd.ColName)
+		for idx, val := range vals {
+			arg, err := val.Field(fd)
+			if err != nil {
+				return nil, err
+			}
+			b.sb.WriteString(" WHEN ? THEN ?")
+			b.addArgs(keys[idx], arg)
+		}
+		b.sb.WriteString(" END")
+	}
+	b.sb.WriteString(" WHERE ")
+	b.quote(keyFd.ColName)
+	b.sb.WriteString(" IN (")
+	for i, key := range keys {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		b.sb.WriteByte('?')
+		b.addArgs(key)
+	}
+	b.sb.WriteString(");")
+	return &Query{
+		SQL:  b.sb.String(),
+		Args: b.args,
+	}, nil
+}
+
+// keyField 返回用于定位行的字段
+func (b *BatchUpdater[T]) keyField(m *model.Model) (*model.Field, error) {
+	if b.key == "" {
+		if len(m.PrimaryKeys) != 1 {
+			return nil, errs.ErrBatchUpdateNoKey
+		}
+		return m.PrimaryKeys[0], nil
+	}
+	fd, ok := m.FieldMap[b.key]
+	if !ok {
+		return nil, errs.NewErrUnknownField(b.key)
+	}
+	return fd, nil
+}
+
+// Exec 执行批量更新
+// 和 Inserter 一样，行数超过一条语句所能容纳的数量的时候，会自动拆分成多条语句执行，并且汇总结果。
+// 默认情况下，某一批失败并不会影响其它批次，失败的批次可以通过 Result.ChunkErrors 获得；
+// 如果调用了 InTx，那么全部批次在同一个事务里面执行。
+// 模型按照时间分表的时候，按照每一行分表字段的值在对应的分表上更新，不同的分表也算作不同的批次
+func (b *BatchUpdater[T]) Exec(ctx context.Context) Result {
+	if m := b.partitionModel(new(T), nil); m != nil {
+		return b.partitionExec(ctx, m)
+	}
+	chunks := b.chunks()
+	if len(chunks) <= 1 {
+		return b.exec(ctx, b.sess)
+	}
+	fns := make([]execFunc, 0, len(chunks))
+	for _, chunk := range chunks {
+		fns = append(fns, chunk.exec)
+	}
+	return execChunks(ctx, b.sess, fns, b.inTx)
+}
+
+// exec 在 sess 上执行一批更新
+func (b *BatchUpdater[T]) exec(ctx context.Context, sess Session) Result {
+	return exec(ctx, sess, b.core, newQueryContext[T](b.core, TypeUpdate, b))
+}
+
+// chunks 按照每一批最多能够更新的行数，把 values 拆分成多个 BatchUpdater
+// 不需要拆分的时候返回 nil
+func (b *BatchUpdater[T]) chunks() []*BatchUpdater[T] {
+	rows := len(b.values)
+	// 每一行在每个 CASE 里面有两个参数，在 IN 里面还有一个参数
+	size := b.dialect.paramLimit() / (2*len(b.sets) + 1)
+	if b.batchSize > 0 && b.batchSize < size {
+		size = b.batchSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	if rows <= size {
+		return nil
+	}
+	res := make([]*BatchUpdater[T], 0, (rows+size-1)/size)
+	for start := 0; start < rows; start += size {
+		end := min(start+size, rows)
+		chunk := *b
+		// 不能复用已经写入过数据的 builder
+		chunk.builder = builder{
+			core:    b.core,
+			dialect: b.dialect,
+			quoter:  b.quoter,
+		}
+		chunk.values = b.values[start:end]
+		res = append(res, &chunk)
+	}
+	return res
+}
@@ -0,0 +1,185 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+)
+
+func TestBatchUpdater_Build(t *testing.T) {
+	db := MemoryDB(t)
+	testCases := []struct {
+		name    string
+		u       QueryBuilder
+		want    *Query
+		wantErr error
+	}{
+		{
+			name: "primary key",
+			u: NewBatchUpdater[TestModel](db).Values(
+				&TestModel{Id: 1, FirstName: "Tom", Age: 18},
+				&TestModel{Id: 2, FirstName: "Jerry", Age: 20},
+			).Set("FirstName", "Age"),
+			want: &Query{
+				SQL: "UPDATE `test_model` SET " +
+					"`first_name`=CASE `id` WHEN ? THEN ? WHEN ? THEN ? END," +
+					"`age`=CASE `id` WHEN ? THEN ? WHEN ? THEN ? END " +
+					"WHERE `id` IN (?,?);",
+				Args: []any{int64(1), "Tom", int64(2), "Jerry",
+					int64(1), int8(18), int64(2), int8(20), int64(1), int64(2)},
+			},
+		},
+		{
+			name: "key",
+			u: NewBatchUpdater[TestModel](db).Values(
+				&TestModel{FirstName: "Tom", Age: 18},
+				&TestModel{FirstName: "Jerry", Age: 20},
+			).Set("Age").Key("FirstName"),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=CASE `first_name` WHEN ? THEN ? WHEN ? THEN ? END WHERE `first_name` IN (?,?);",
+				Args: []any{"Tom", int8(18), "Jerry", int8(20), "Tom", "Jerry"},
+			},
+		},
+		{
+			name:    "no rows",
+			u:       NewBatchUpdater[TestModel](db).Set("Age"),
+			wantErr: errs.ErrUpdateZeroRow,
+		},
+		{
+			name:    "no columns",
+			u:       NewBatchUpdater[TestModel](db).Values(&TestModel{Id: 1}),
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name:    "no key",
+			u:       NewBatchUpdater[testNoPKModel](db).Values(&testNoPKModel{Name: "Tom"}).Set("Name"),
+			wantErr: errs.ErrBatchUpdateNoKey,
+		},
+		{
+			name:    "unknown key",
+			u:       NewBatchUpdater[TestModel](db).Values(&TestModel{Id: 1}).Set("Age").Key("Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "unknown field",
+			u:       NewBatchUpdater[TestModel](db).Values(&TestModel{Id: 1}).Set("Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.u.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, q)
+		})
+	}
+}
+
+func TestBatchUpdater_Exec_Chunk(t *testing.T) {
+	newValues := func(n int) []*TestModel {
+		res := make([]*TestModel, 0, n)
+		for i := 1; i <= n; i++ {
+			res = append(res, &TestModel{Id: int64(i), Age: 18})
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name         string
+		exec         func(db *DB, mock sqlmock.Sqlmock) Result
+		wantAffected int64
+	}{
+		{
+			name: "batch size",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				mock.ExpectExec("UPDATE `test_model` SET `age`=CASE `id` WHEN \\? THEN \\? WHEN \\? THEN \\? END WHERE `id` IN \\(\\?,\\?\\);").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("UPDATE `test_model` SET `age`=CASE `id` WHEN \\? THEN \\? END WHERE `id` IN \\(\\?\\);").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return NewBatchUpdater[TestModel](db).Values(newValues(3)...).Set("Age").BatchSize(2).Exec(context.Background())
+			},
+			wantAffected: 3,
+		},
+		{
+			// SQLite 最多 999 个参数，每行 3 个参数，所以每一批 333 行
+			name: "dialect limit",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				db.dialect = SQLite3
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 333))
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+				return NewBatchUpdater[TestModel](db).Values(newValues(334)...).Set("Age").Exec(context.Background())
+			},
+			wantAffected: 334,
+		},
+		{
+			name: "in tx",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return NewBatchUpdater[TestModel](db).Values(newValues(3)...).Set("Age").
+					BatchSize(2).InTx().Exec(context.Background())
+			},
+			wantAffected: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := tc.exec(db, mock)
+			assert.Nil(t, res.Err())
+			affected, err := res.RowsAffected()
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantAffected, affected)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBatchUpdater_Exec(t *testing.T) {
+	db := memoryDBWithDB("batch_update", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES " +
+		"(1, 'Tom', 18, 'Cat'), (2, 'Jerry', 20, 'Mouse'), (3, 'Deng', 30, 'Ming')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	res := NewBatchUpdater[TestModel](db).Values(
+		&TestModel{Id: 1, FirstName: "Tom2", Age: 19},
+		&TestModel{Id: 3, FirstName: "Deng2", Age: 31},
+	).Set("FirstName", "Age").Exec(ctx)
+	assert.Nil(t, res.Err())
+	affected, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	got, err := NewSelector[TestModel](db).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 1, FirstName: "Tom2", Age: 19, LastName: &sql.NullString{String: "Cat", Valid: true}},
+		{Id: 2, FirstName: "Jerry", Age: 20, LastName: &sql.NullString{String: "Mouse", Valid: true}},
+		{Id: 3, FirstName: "Deng2", Age: 31, LastName: &sql.NullString{String: "Ming", Valid: true}},
+	}, got)
+}
@@ -3,6 +3,7 @@ package sorm
 import (
 	"github.com/xzhHas/sorm/internal/errs"
 	"github.com/xzhHas/sorm/model"
+	"slices"
 	"strings"
 )
 
@@ -14,6 +15,16 @@ type builder struct {
 	dialect Dialect         // 数据库方言
 	quoter  byte            // 表示SQL标识符(表名和列名)的引号
 	model   *model.Model    // model包含结构体和字段信息，用于生成与特定数据表相关的SQL查询
+	// tableName 不为空的时候代替 model 对应的表名，用于分库分表的时候指定物理表
+	tableName string
+}
+
+// modelTable 返回 model 对应的表名，分库分表的时候是物理表的名字
+func (b *builder) modelTable(m *model.Model) string {
+	if b.tableName != "" && m == b.model {
+		return b.tableName
+	}
+	return m.TableName
 }
 
 // buildColumn 构造列
@@ -85,6 +96,158 @@ func (b *builder) colName(table TableReference, fd string) (string, error) {
 	}
 }
 
+// buildTable 根据给定的表引用构建查询表部分，没有指定的时候使用 model 对应的表
+// 它处理了不同类型的表引用，并相应地构建查询语句
+func (b *builder) buildTable(table TableReference) error {
+	switch tab := table.(type) {
+	case nil:
+		b.quote(b.modelTable(b.model))
+	case Table:
+		model, err := b.r.Get(tab.entity)
+		if err != nil {
+			return err
+		}
+		b.quote(b.modelTable(model))
+		if tab.alias != "" {
+			b.sb.WriteString(" AS ")
+			b.quote(tab.alias)
+		}
+	case Join:
+		return b.buildJoin(tab)
+	case Subquery:
+		return b.buildSubquery(tab, true)
+	default:
+		return errs.NewErrUnsupportedExpressionType(tab)
+	}
+	return nil
+}
+
+// buildJoin 构建一个 JOIN 语句
+// tab: Join 类型的参数，包含构建 JOIN 语句所需的所有信息
+func (b *builder) buildJoin(tab Join) error {
+	b.sb.WriteByte('(')
+	// 构建JOIN的左侧表
+	if err := b.buildTable(tab.left); err != nil {
+		return err
+	}
+	// 添加空格和JOIN类型
+	b.sb.WriteString(" ")
+	b.sb.WriteString(tab.typ)
+	b.sb.WriteString(" ")
+	// 构建JOIN的右侧表
+	if err := b.buildTable(tab.right); err != nil {
+		return err
+	}
+	// 处理 USING 子句
+	if len(tab.using) > 0 {
+		b.sb.WriteString(" USING (")
+		for i, col := range tab.using {
+			if i > 0 {
+				b.sb.WriteByte(',')
+			}
+			// 构建USING子句中的列名
+			err := b.buildColumn(nil, col)
+			if err != nil {
+				return err
+			}
+		}
+		b.sb.WriteString(")")
+	}
+	// 如果使用ON关键字，则构建ON子句
+	if len(tab.on) > 0 {
+		b.sb.WriteString(" ON ")
+		err := b.buildPredicates(tab.on)
+		if err != nil {
+			return err
+		}
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// targetTable 返回 UPDATE 和 DELETE 实际修改的表，也就是 JOIN 最左边的表
+func targetTable(table TableReference) (TableReference, error) {
+	switch tab := table.(type) {
+	case nil, Table:
+		return tab, nil
+	case Join:
+		return targetTable(tab.left)
+	default:
+		return nil, errs.NewErrUnsupportedTableType(tab)
+	}
+}
+
+// flattenJoin 把内连接拆分成参与连接的表和连接条件，
+// 用于不支持 UPDATE ... JOIN 的数据库，改写成 UPDATE ... FROM ... WHERE
+func flattenJoin(j Join) ([]TableReference, []Predicate, error) {
+	if j.typ != "JOIN" || len(j.using) > 0 {
+		return nil, nil, errs.NewErrUnsupportedJoin(j.typ)
+	}
+	var tables []TableReference
+	for _, side := range []TableReference{j.left, j.right} {
+		switch tab := side.(type) {
+		case Join:
+			subTables, subOn, err := flattenJoin(tab)
+			if err != nil {
+				return nil, nil, err
+			}
+			tables = append(tables, subTables...)
+			j.on = append(subOn, j.on...)
+		case Table:
+			tables = append(tables, tab)
+		default:
+			return nil, nil, errs.NewErrUnsupportedTableType(tab)
+		}
+	}
+	return tables, j.on, nil
+}
+
+// quoteTableName 写入用于限定列的表名，有别名的时候使用别名
+func (b *builder) quoteTableName(table TableReference) error {
+	switch tab := table.(type) {
+	case nil:
+		b.quote(b.modelTable(b.model))
+	case Table:
+		if tab.alias != "" {
+			b.quote(tab.alias)
+			return nil
+		}
+		m, err := b.r.Get(tab.entity)
+		if err != nil {
+			return err
+		}
+		b.quote(b.modelTable(m))
+	default:
+		return errs.NewErrUnsupportedTableType(tab)
+	}
+	return nil
+}
+
+// mapFields 把 map 的 key 解析成字段，key 可以是字段名，也可以是列名
+// 返回的字段按照在模型里面定义的顺序排列，这样不管 map 的遍历顺序如何，生成的 SQL 都是稳定的
+func (b *builder) mapFields(row map[string]any) ([]*model.Field, error) {
+	res := make([]*model.Field, 0, len(row))
+	for key := range row {
+		fd, ok := b.model.FieldMap[key]
+		if !ok {
+			fd, ok = b.model.ColumnMap[key]
+		}
+		if !ok {
+			return nil, errs.NewErrUnknownField(key)
+		}
+		res = append(res, fd)
+	}
+	slices.SortFunc(res, func(a, c *model.Field) int {
+		return a.Index - c.Index
+	})
+	for idx := 1; idx < len(res); idx++ {
+		if res[idx] == res[idx-1] {
+			return nil, errs.NewErrDuplicateField(res[idx].GoName)
+		}
+	}
+	return res, nil
+}
+
 // quote 方法用于将给定的名称用引号包围并添加到构建器中
 // 此方法主要用于处理需要被引号包围的标识符，如列名或变量名，以确保在生成的语句中它们被正确地识别和处理
 // 该方法直接操作 builder 内部的字符串构建器（StringBuilder）
@@ -149,8 +312,11 @@ func (b *builder) buildExpression(e Expression) error {
 		// 当表达式为原始SQL时，直接将其添加到构建的SQL中
 		b.raw(exp)
 	case MathExpr:
-		// 当表达式为数学表达式时，构建相应的二元表达式
-		return b.buildBinaryExpr(binaryExpr(exp))
+		// 当表达式为数学表达式时，构建相应的运算表达式
+		return b.buildMathExpr(exp)
+	case FuncExpr:
+		// 当表达式为函数调用时，构建函数名和参数
+		return b.buildFunc(exp)
 	case Predicate:
 		// 当表达式为谓词时，构建相应的二元表达式
 		return b.buildBinaryExpr(binaryExpr(exp))
@@ -190,7 +356,6 @@ func (b *builder) buildSubquery(tab Subquery, useAlias bool) error {
 	// 写入右括号
 	b.sb.WriteByte(')')
 	// 如果需要使用别名，写入AS关键字和子查询的别名
-	b.sb.WriteByte(' ')
 	if useAlias {
 		b.sb.WriteString(" AS ")
 		b.quote(tab.alias)
@@ -198,11 +363,34 @@ func (b *builder) buildSubquery(tab Subquery, useAlias bool) error {
 	return nil
 }
 
+// buildOrderBy 构造 ORDER BY 子句，调用者需要保证 obs 不为空
+func (b *builder) buildOrderBy(obs []OrderBy) error {
+	b.sb.WriteString(" ORDER BY ")
+	for i, ob := range obs {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildColumn(ob.table, ob.col); err != nil {
+			return err
+		}
+		b.sb.WriteByte(' ')
+		b.sb.WriteString(ob.order)
+	}
+	return nil
+}
+
 // buildBinaryExpr 构建并处理二元表达式。
 // 该方法递归地构建二元表达式的左右子表达式，并处理它们之间的操作符。
 // 参数 e: 二元表达式对象，包含左子表达式、操作符和右子表达式。
 // 返回值 err: 如果在构建过程中发生错误，则返回错误，否则返回nil。
 func (b *builder) buildBinaryExpr(e binaryExpr) error {
+	// IN () 在 SQL 里面不合法，没有任何值的 IN 恒为假，所以写成 1=0，NOT 之后也是正确的
+	if val, ok := e.right.(value); ok && e.op == opIN {
+		if vals, ok := val.val.([]any); ok && len(vals) == 0 {
+			b.sb.WriteString("1=0")
+			return nil
+		}
+	}
 	err := b.buildSubExpr(e.left)
 	if err != nil {
 		return err
@@ -211,10 +399,59 @@ func (b *builder) buildBinaryExpr(e binaryExpr) error {
 		b.sb.WriteByte(' ')
 		b.sb.WriteString(e.op.String())
 	}
-	if e.right != nil {
-		b.sb.WriteByte(' ')
+	if e.right == nil {
+		return nil
+	}
+	b.sb.WriteByte(' ')
+	// IN 后面的多个值展开成 (?,?,?)
+	if val, ok := e.right.(value); ok && e.op == opIN {
+		if vals, ok := val.val.([]any); ok {
+			b.sb.WriteByte('(')
+			for i, v := range vals {
+				if i > 0 {
+					b.sb.WriteByte(',')
+				}
+				b.sb.WriteByte('?')
+				b.addArgs(v)
+			}
+			b.sb.WriteByte(')')
+			return nil
+		}
+	}
+	return b.buildSubExpr(e.right)
+}
+
+// buildMathExpr 构建数学表达式
+// 一元运算直接把操作符写在操作数前面，例如 -`age`；
+// 字符串拼接和按位异或在不同数据库里面写法不同，交给 dialect 处理；
+// 其余的都是普通的二元表达式
+func (b *builder) buildMathExpr(e MathExpr) error {
+	switch {
+	case e.left == nil:
+		b.sb.WriteString(e.op.String())
 		return b.buildSubExpr(e.right)
+	case e.op == opConcat:
+		return b.dialect.buildConcat(b, e)
+	case e.op == opBitXor:
+		return b.dialect.buildBitXor(b, e)
+	default:
+		return b.buildBinaryExpr(binaryExpr(e))
+	}
+}
+
+// buildFunc 构建函数调用，参数之间用逗号分隔
+func (b *builder) buildFunc(f FuncExpr) error {
+	b.sb.WriteString(f.name)
+	b.sb.WriteByte('(')
+	for i, arg := range f.args {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildSubExpr(arg); err != nil {
+			return err
+		}
 	}
+	b.sb.WriteByte(')')
 	return nil
 }
 
@@ -226,7 +463,7 @@ func (b *builder) buildSubExpr(subExpr Expression) error {
 	switch sub := subExpr.(type) {
 	case MathExpr:
 		_ = b.sb.WriteByte('(')
-		if err := b.buildBinaryExpr(binaryExpr(sub)); err != nil {
+		if err := b.buildMathExpr(sub); err != nil {
 			return err
 		}
 		_ = b.sb.WriteByte(')')
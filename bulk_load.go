@@ -0,0 +1,461 @@
+package sorm
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/model"
+	"io"
+	"iter"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// readerHandler 向驱动注册 LOAD DATA LOCAL INFILE 'Reader::name' 的数据来源
+type readerHandler struct {
+	register   func(name string, handler func() io.Reader)
+	deregister func(name string)
+}
+
+// loadDataSeq 用于生成不重复的 Reader 名字
+var loadDataSeq atomic.Int64
+
+// BulkLoader 用于大批量导入数据，会根据数据库选择最快的方式：
+// MySQL 并且设置了 DBWithLoadDataReader 的时候，把数据编码成 CSV，通过 LOAD DATA LOCAL INFILE 导入；
+// 其它情况下，在同一个事务里面复用一个预编译的 INSERT 语句逐行插入，SQLite 上这是最快的方式。
+// 数据以流的形式读取，不会一次性全部加载到内存里面
+type BulkLoader[T any] struct {
+	builder
+	sess    Session
+	columns []string
+	fields  []*model.Field
+	// progressEvery 每导入多少行回调一次 onProgress
+	progressEvery int64
+	onProgress    func(loaded int64)
+	onRowError    func(err *RowError) error
+	// readerName LOAD DATA 使用的 Reader 名字，为空说明使用预编译语句
+	readerName string
+	// loc LOAD DATA 编码时间使用的时区，为 nil 的时候使用 UTC
+	loc *time.Location
+}
+
+// NewBulkLoader 创建一个 BulkLoader
+func NewBulkLoader[T any](sess Session) *BulkLoader[T] {
+	c := coreOf(sess)
+	return &BulkLoader[T]{
+		sess: sess,
+		builder: builder{
+			core:    c,
+			dialect: c.dialect,
+			quoter:  c.dialect.quoter(),
+		},
+	}
+}
+
+// Columns 指定要导入的列，默认是除了自增主键以外的全部列
+// 需要导入自增主键的时候，必须显式指定
+func (l *BulkLoader[T]) Columns(cols ...string) *BulkLoader[T] {
+	l.columns = cols
+	return l
+}
+
+// OnProgress 每导入 every 行回调一次 fn，导入结束的时候也会回调一次，loaded 是已经导入的行数
+// 使用 LOAD DATA 的时候，loaded 是已经发送给数据库的行数，并且 fn 会在另外一个 goroutine 里面被调用
+func (l *BulkLoader[T]) OnProgress(every int64, fn func(loaded int64)) *BulkLoader[T] {
+	l.progressEvery = every
+	l.onProgress = fn
+	return l
+}
+
+// Location 设置 LOAD DATA 编码时间的时区，需要和驱动写入时间的时区保持一致，
+// 例如 github.com/go-sql-driver/mysql 的 loc 参数，它默认是 UTC，所以没有设置的时候使用 UTC。
+// 使用预编译语句导入的时候，时间由驱动编码，这个设置不生效
+func (l *BulkLoader[T]) Location(loc *time.Location) *BulkLoader[T] {
+	l.loc = loc
+	return l
+}
+
+// OnRowError 设置某一行导入失败的时候的处理方式
+// fn 返回 nil 的时候跳过这一行继续导入，否则终止导入并且返回该错误。
+// 没有设置的时候，任何一行失败都会终止导入。
+// 使用 LOAD DATA 的时候，只有编码失败的行会被回调，数据库层面的错误由 MySQL 自己处理
+func (l *BulkLoader[T]) OnRowError(fn func(err *RowError) error) *BulkLoader[T] {
+	l.onRowError = fn
+	return l
+}
+
+// Build 构造导入语句，LOAD DATA 或者单行的 INSERT 语句
+func (l *BulkLoader[T]) Build() (*Query, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	l.sb.Reset()
+	if l.readerName != "" {
+		l.sb.WriteString("LOAD DATA LOCAL INFILE 'Reader::")
+		l.sb.WriteString(l.readerName)
+		l.sb.WriteString("' INTO TABLE ")
+		l.quote(l.model.TableName)
+		l.sb.WriteString(` CHARACTER SET utf8mb4 FIELDS TERMINATED BY ',' ENCLOSED BY '"' ESCAPED BY ''`)
+		l.sb.WriteString(` LINES TERMINATED BY '\n' (`)
+		l.buildLoadDataFields()
+		l.sb.WriteByte(';')
+		return &Query{SQL: l.sb.String()}, nil
+	}
+	l.sb.WriteString("INSERT INTO ")
+	l.quote(l.model.TableName)
+	l.sb.WriteByte('(')
+	l.buildFields()
+	l.sb.WriteString(") VALUES(")
+	for idx := range l.fields {
+		if idx > 0 {
+			l.sb.WriteByte(',')
+		}
+		l.sb.WriteByte('?')
+	}
+	l.sb.WriteString(");")
+	return &Query{SQL: l.sb.String()}, nil
+}
+
+func (l *BulkLoader[T]) buildFields() {
+	for idx, fd := range l.fields {
+		if idx > 0 {
+			l.sb.WriteByte(',')
+		}
+		l.quote(fd.ColName)
+	}
+}
+
+// buildLoadDataFields 构造 LOAD DATA 的列，二进制的列以十六进制编码，
+// 先读到用户变量里面，再通过 SET 子句用 UNHEX 解码
+func (l *BulkLoader[T]) buildLoadDataFields() {
+	var binary []int
+	for idx, fd := range l.fields {
+		if idx > 0 {
+			l.sb.WriteByte(',')
+		}
+		if isBinaryField(fd) {
+			binary = append(binary, idx)
+			l.sb.WriteString("@sorm_bin_" + strconv.Itoa(idx))
+			continue
+		}
+		l.quote(fd.ColName)
+	}
+	l.sb.WriteByte(')')
+	for i, idx := range binary {
+		if i == 0 {
+			l.sb.WriteString(" SET ")
+		} else {
+			l.sb.WriteByte(',')
+		}
+		l.quote(l.fields[idx].ColName)
+		l.sb.WriteString("=UNHEX(@sorm_bin_" + strconv.Itoa(idx) + ")")
+	}
+}
+
+// isBinaryField 判断字段是不是 []byte 之类的二进制数据
+func isBinaryField(fd *model.Field) bool {
+	return fd.Type.Kind() == reflect.Slice && fd.Type.Elem().Kind() == reflect.Uint8
+}
+
+// init 解析模型和要导入的列
+func (l *BulkLoader[T]) init() error {
+	if l.model != nil {
+		return nil
+	}
+	m, err := l.r.Get(new(T))
+	if err != nil {
+		return err
+	}
+	if m.Partition != nil {
+		return errs.NewErrPartitionUnsupported("使用 BulkLoader 导入")
+	}
+	if len(l.columns) == 0 {
+		l.fields = slices.DeleteFunc(slices.Clone(m.Fields), func(fd *model.Field) bool {
+			return fd.AutoIncrement
+		})
+	} else {
+		l.fields = make([]*model.Field, 0, len(l.columns))
+		for _, c := range l.columns {
+			fd, ok := m.FieldMap[c]
+			if !ok {
+				return errs.NewErrUnknownField(c)
+			}
+			l.fields = append(l.fields, fd)
+		}
+	}
+	l.model = m
+	return nil
+}
+
+// Load 导入 rows 里面的全部数据，返回成功导入的行数
+func (l *BulkLoader[T]) Load(ctx context.Context, rows iter.Seq[*T]) (int64, error) {
+	if err := l.init(); err != nil {
+		return 0, err
+	}
+	if l.dialect.supportsLoadData() && l.readers != nil {
+		return l.loadData(ctx, rows)
+	}
+	return l.loadPrepared(ctx, rows)
+}
+
+// loadPrepared 在事务里面复用预编译语句逐行插入
+// 如果 sess 本身就是事务，那么直接复用，否则开启一个新事务，导入失败的时候整体回滚
+func (l *BulkLoader[T]) loadPrepared(ctx context.Context, rows iter.Seq[*T]) (int64, error) {
+	var loaded int64
+	err := runInTx(ctx, l.sess, func(ctx context.Context, sess Session) error {
+		qr := handle(ctx, l.core, &QueryContext{
+			Type:    TypeInsert,
+			Builder: l,
+			Model:   l.model,
+		}, func(ctx context.Context, qc *QueryContext) *QueryResult {
+			q, err := qc.Builder.Build()
+			if err != nil {
+				return &QueryResult{Err: err}
+			}
+			execRow, closeStmt, err := prepareContext(ctx, sess, q.SQL)
+			if err != nil {
+				return &QueryResult{Err: err}
+			}
+			defer closeStmt()
+			var idx int64 = -1
+			for val := range rows {
+				idx++
+				args, err := l.rowArgs(val)
+				if err == nil {
+					_, err = execRow(ctx, args...)
+				}
+				if err != nil {
+					if err = l.rowError(idx, err); err != nil {
+						return &QueryResult{Err: err}
+					}
+					continue
+				}
+				loaded++
+				l.progress(loaded, false)
+			}
+			l.progress(loaded, true)
+			return &QueryResult{Result: driver.RowsAffected(loaded)}
+		})
+		return qr.Err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return loaded, nil
+}
+
+// loadData 使用 LOAD DATA LOCAL INFILE 导入，数据通过管道一边编码一边发送
+func (l *BulkLoader[T]) loadData(ctx context.Context, rows iter.Seq[*T]) (int64, error) {
+	pr, pw := io.Pipe()
+	var writeErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writeErr = l.writeCSV(pw, rows)
+		_ = pw.CloseWithError(writeErr)
+	}()
+
+	l.readerName = fmt.Sprintf("sorm_bulk_load_%d", loadDataSeq.Add(1))
+	l.readers.register(l.readerName, func() io.Reader {
+		return pr
+	})
+	defer l.readers.deregister(l.readerName)
+
+	qr := handle(ctx, l.core, &QueryContext{
+		Type:    TypeInsert,
+		Builder: l,
+		Model:   l.model,
+	}, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Builder.Build()
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		res, err := l.sess.ExecContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Result: res, Err: err}
+	})
+	// 驱动没有读完数据的时候（例如出错了），需要关闭管道让编码的 goroutine 退出
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+
+	if writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		return 0, writeErr
+	}
+	if qr.Err != nil {
+		return 0, qr.Err
+	}
+	res, ok := qr.Result.(sql.Result)
+	if !ok {
+		return 0, nil
+	}
+	return res.RowsAffected()
+}
+
+// writeCSV 把 rows 编码成 LOAD DATA 能够识别的 CSV
+// 每个值都用双引号包裹，值里面的双引号写两次，NULL 不加引号，
+// 配合 LOAD DATA 语句里面空的 ESCAPED BY 使用，这样就不需要处理反斜杠转义
+func (l *BulkLoader[T]) writeCSV(w io.Writer, rows iter.Seq[*T]) error {
+	bw := bufio.NewWriter(w)
+	var written int64
+	var idx int64 = -1
+	var line strings.Builder
+	for val := range rows {
+		idx++
+		line.Reset()
+		err := l.encodeRow(&line, val)
+		if err != nil {
+			if err = l.rowError(idx, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err = bw.WriteString(line.String()); err != nil {
+			return err
+		}
+		written++
+		l.progress(written, false)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	l.progress(written, true)
+	return nil
+}
+
+// encodeRow 编码一行数据，以换行符结尾
+func (l *BulkLoader[T]) encodeRow(sb *strings.Builder, val *T) error {
+	args, err := l.rowArgs(val)
+	if err != nil {
+		return err
+	}
+	loc := l.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	for idx, arg := range args {
+		if idx > 0 {
+			sb.WriteByte(',')
+		}
+		if err = encodeLoadDataValue(sb, arg, loc, isBinaryField(l.fields[idx])); err != nil {
+			return err
+		}
+	}
+	sb.WriteByte('\n')
+	return nil
+}
+
+// encodeLoadDataValue 编码单个值，先按照 database/sql 的规则转换成驱动支持的类型
+// 时间转换到 loc 所在的时区；binary 为 true 的时候以十六进制编码，因为二进制数据不一定是合法的 utf8mb4，
+// 其它列里面的 []byte，例如 driver.Valuer 返回的 JSON，当成文本处理
+func encodeLoadDataValue(sb *strings.Builder, arg any, loc *time.Location, binary bool) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+	if err != nil {
+		return err
+	}
+	var str string
+	switch v := v.(type) {
+	case nil:
+		sb.WriteString("NULL")
+		return nil
+	case bool:
+		if v {
+			str = "1"
+		} else {
+			str = "0"
+		}
+	case int64:
+		str = strconv.FormatInt(v, 10)
+	case float64:
+		str = strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		// 和驱动保持一致，nil 切片是 NULL
+		if v == nil {
+			sb.WriteString("NULL")
+			return nil
+		}
+		str = string(v)
+	case string:
+		str = v
+	case time.Time:
+		str = v.In(loc).Format("2006-01-02 15:04:05.999999")
+	default:
+		return errs.NewErrUnsupportedExpressionType(v)
+	}
+	if binary {
+		str = hex.EncodeToString([]byte(str))
+	}
+	sb.WriteByte('"')
+	sb.WriteString(strings.ReplaceAll(str, `"`, `""`))
+	sb.WriteByte('"')
+	return nil
+}
+
+// rowArgs 读取一行数据里面要导入的字段的值
+func (l *BulkLoader[T]) rowArgs(val *T) ([]any, error) {
+	if val == nil {
+		return nil, errs.ErrPointerOnly
+	}
+	refVal := l.valCreator(val, l.model)
+	args := make([]any, 0, len(l.fields))
+	for _, fd := range l.fields {
+		arg, err := refVal.Field(fd.GoName)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// rowError 处理某一行的错误，返回 nil 说明可以跳过这一行
+func (l *BulkLoader[T]) rowError(idx int64, err error) error {
+	rowErr := &RowError{Index: idx, Err: err}
+	if l.onRowError == nil {
+		return rowErr
+	}
+	return l.onRowError(rowErr)
+}
+
+// progress 回调进度，final 为 true 说明导入结束了
+// 结束的时候如果刚好已经回调过了，那么不会重复回调
+func (l *BulkLoader[T]) progress(loaded int64, final bool) {
+	if l.onProgress == nil {
+		return
+	}
+	reached := l.progressEvery > 0 && loaded > 0 && loaded%l.progressEvery == 0
+	if final != reached {
+		l.onProgress(loaded)
+	}
+}
+
+// prepareContext 在 sess 上预编译 query，返回执行单行的方法和关闭语句的方法
+// 不支持预编译的会话，退化成每次都直接执行 query
+func prepareContext(ctx context.Context, sess Session,
+	query string) (func(ctx context.Context, args ...any) (sql.Result, error), func(), error) {
+	var stmt *sql.Stmt
+	var err error
+	switch s := sess.(type) {
+	case *Tx:
+		stmt, err = s.tx.PrepareContext(ctx, query)
+	case *DB:
+		stmt, err = s.db.PrepareContext(ctx, query)
+	default:
+		return func(ctx context.Context, args ...any) (sql.Result, error) {
+			return sess.ExecContext(ctx, query, args...)
+		}, func() {}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return stmt.ExecContext, func() {
+		_ = stmt.Close()
+	}, nil
+}
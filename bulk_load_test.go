@@ -0,0 +1,333 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"io"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testModelSeq 生成 n 个 TestModel
+func testModelSeq(n int) iter.Seq[*TestModel] {
+	return func(yield func(*TestModel) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(&TestModel{FirstName: "Tom", Age: int8(i % 100),
+				LastName: &sql.NullString{String: "Jerry", Valid: true}}) {
+				return
+			}
+		}
+	}
+}
+
+func TestBulkLoader_SQLite3(t *testing.T) {
+	testCases := []struct {
+		name       string
+		loader     func(db *DB) *BulkLoader[TestModel]
+		rows       iter.Seq[*TestModel]
+		wantLoaded int64
+		wantCount  int64
+		wantErr    error
+	}{
+		{
+			name: "load",
+			loader: func(db *DB) *BulkLoader[TestModel] {
+				return NewBulkLoader[TestModel](db)
+			},
+			rows:       testModelSeq(2000),
+			wantLoaded: 2000,
+			wantCount:  2000,
+		},
+		{
+			// 第 3 行和第 5 行违反了 NOT NULL 约束，跳过
+			name: "skip error rows",
+			loader: func(db *DB) *BulkLoader[TestModel] {
+				return NewBulkLoader[TestModel](db).OnRowError(func(err *RowError) error {
+					return nil
+				})
+			},
+			rows: func(yield func(*TestModel) bool) {
+				for i := 0; i < 6; i++ {
+					val := &TestModel{FirstName: "Tom", LastName: &sql.NullString{String: "Jerry", Valid: true}}
+					if i == 3 || i == 5 {
+						val.LastName = nil
+					}
+					if !yield(val) {
+						return
+					}
+				}
+			},
+			wantLoaded: 4,
+			wantCount:  4,
+		},
+		{
+			// 没有设置 OnRowError，整个事务回滚
+			name: "abort",
+			loader: func(db *DB) *BulkLoader[TestModel] {
+				return NewBulkLoader[TestModel](db)
+			},
+			rows: func(yield func(*TestModel) bool) {
+				_ = yield(&TestModel{FirstName: "Tom", LastName: &sql.NullString{String: "Jerry", Valid: true}}) &&
+					yield(&TestModel{FirstName: "Tom"})
+			},
+			wantErr: &RowError{Index: 1},
+		},
+		{
+			name: "abort by handler",
+			loader: func(db *DB) *BulkLoader[TestModel] {
+				return NewBulkLoader[TestModel](db).OnRowError(func(err *RowError) error {
+					return errors.New("mock error")
+				})
+			},
+			rows: func(yield func(*TestModel) bool) {
+				_ = yield(&TestModel{FirstName: "Tom"})
+			},
+			wantErr: errors.New("mock error"),
+		},
+		{
+			name: "columns",
+			loader: func(db *DB) *BulkLoader[TestModel] {
+				return NewBulkLoader[TestModel](db).Columns("Id", "FirstName", "LastName")
+			},
+			rows: func(yield func(*TestModel) bool) {
+				for i := 1; i <= 3; i++ {
+					if !yield(&TestModel{Id: int64(i * 10), FirstName: "Tom",
+						LastName: &sql.NullString{String: "Jerry", Valid: true}}) {
+						return
+					}
+				}
+			},
+			wantLoaded: 3,
+			wantCount:  3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := memoryDBWithDB("bulk_load_"+strings.ReplaceAll(tc.name, " ", "_"), t)
+			db.dialect = SQLite3
+			_, err := db.db.Exec(TestModel{}.CreateSQL())
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := tc.loader(db).Load(context.Background(), tc.rows)
+			if tc.wantErr != nil {
+				var rowErr *RowError
+				if errors.As(tc.wantErr, &rowErr) {
+					var gotErr *RowError
+					assert.True(t, errors.As(err, &gotErr))
+					assert.Equal(t, rowErr.Index, gotErr.Index)
+				} else {
+					assert.Equal(t, tc.wantErr, err)
+				}
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.wantLoaded, loaded)
+			var cnt int64
+			err = db.db.QueryRow("SELECT COUNT(*) FROM `test_model`").Scan(&cnt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.wantCount, cnt)
+		})
+	}
+}
+
+func TestBulkLoader_Progress(t *testing.T) {
+	db := memoryDBWithDB("bulk_load_progress", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		rows  int
+		every int64
+		want  []int64
+	}{
+		{
+			name:  "remainder",
+			rows:  25,
+			every: 10,
+			want:  []int64{10, 20, 25},
+		},
+		{
+			// 刚好整除的时候，结束时不重复回调
+			name:  "exact",
+			rows:  20,
+			every: 10,
+			want:  []int64{10, 20},
+		},
+		{
+			name: "final only",
+			rows: 5,
+			want: []int64{5},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int64
+			_, err := NewBulkLoader[TestModel](db).OnProgress(tc.every, func(loaded int64) {
+				got = append(got, loaded)
+			}).Load(context.Background(), testModelSeq(tc.rows))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBulkLoader_LoadData(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+
+	// 模拟驱动读取数据，真实的驱动是在执行 LOAD DATA 的时候读取的
+	var content string
+	var registered, deregistered string
+	register := func(name string, handler func() io.Reader) {
+		registered = name
+		data, err := io.ReadAll(handler())
+		assert.Nil(t, err)
+		content = string(data)
+	}
+	deregister := func(name string) {
+		deregistered = name
+	}
+	db, err := OpenDB(mockDB, DBWithLoadDataReader(register, deregister))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec("LOAD DATA LOCAL INFILE 'Reader::sorm_bulk_load_[0-9]+' INTO TABLE `test_model` " +
+		"CHARACTER SET utf8mb4 FIELDS TERMINATED BY ',' ENCLOSED BY '\"' ESCAPED BY '' " +
+		"LINES TERMINATED BY '\\\\n' \\(`first_name`,`age`,`last_name`\\);").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	var rowErrs []*RowError
+	rows := func(yield func(*TestModel) bool) {
+		_ = yield(&TestModel{FirstName: `Tom "Cat"`, Age: 18, LastName: &sql.NullString{String: "NULL", Valid: true}}) &&
+			yield(&TestModel{FirstName: "Jerry,\nMouse", Age: -1}) &&
+			yield(nil) &&
+			yield(&TestModel{FirstName: `C:\tmp`, Age: 0, LastName: &sql.NullString{}})
+	}
+	loaded, err := NewBulkLoader[TestModel](db).OnRowError(func(err *RowError) error {
+		rowErrs = append(rowErrs, err)
+		return nil
+	}).Load(context.Background(), rows)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), loaded)
+	assert.Equal(t, registered, deregistered)
+	assert.Equal(t, []*RowError{{Index: 2, Err: errs.ErrPointerOnly}}, rowErrs)
+	// 字符串 NULL 加引号，真正的 NULL 不加引号
+	assert.Equal(t, `"Tom ""Cat""","18","NULL"`+"\n"+
+		`"Jerry,`+"\n"+`Mouse","-1",NULL`+"\n"+
+		`"C:\tmp","0",NULL`+"\n", content)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestEncodeLoadDataValue(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	testCases := []struct {
+		name    string
+		val     any
+		loc     *time.Location
+		binary  bool
+		want    string
+		wantErr bool
+	}{
+		{name: "nil", val: nil, want: "NULL"},
+		{name: "bool", val: true, want: `"1"`},
+		{name: "float", val: 1.5, want: `"1.5"`},
+		// 非二进制的列，例如 Valuer 返回的 JSON，当成文本
+		{name: "bytes text", val: []byte(`a"b`), want: `"a""b"`},
+		{name: "bytes binary", val: []byte{'a', '"', 'b', 0, 0xff}, binary: true, want: `"61226200ff"`},
+		{name: "nil binary", val: []byte(nil), binary: true, want: "NULL"},
+		{name: "time", val: time.Date(2026, 9, 1, 8, 30, 0, 1000, time.UTC), want: `"2026-09-01 08:30:00.000001"`},
+		{
+			// 默认转换成 UTC，和驱动保持一致
+			name: "time utc",
+			val:  time.Date(2026, 9, 1, 8, 30, 0, 0, cst),
+			want: `"2026-09-01 00:30:00"`,
+		},
+		{
+			name: "time location",
+			val:  time.Date(2026, 9, 1, 8, 30, 0, 0, time.UTC),
+			loc:  cst,
+			want: `"2026-09-01 16:30:00"`,
+		},
+		{name: "nil pointer", val: (*int)(nil), want: "NULL"},
+		{name: "valuer", val: &sql.NullInt64{Int64: 12, Valid: true}, want: `"12"`},
+		{name: "unsupported", val: struct{}{}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loc := tc.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			var sb strings.Builder
+			err := encodeLoadDataValue(&sb, tc.val, loc, tc.binary)
+			if tc.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, sb.String())
+		})
+	}
+}
+
+func TestBulkLoader_LoadData_Binary(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+
+	var content string
+	register := func(name string, handler func() io.Reader) {
+		data, err := io.ReadAll(handler())
+		assert.Nil(t, err)
+		content = string(data)
+	}
+	db, err := OpenDB(mockDB, DBWithLoadDataReader(register, func(name string) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type BinaryModel struct {
+		Name    string
+		Payload []byte
+		Ctime   time.Time
+	}
+	// 二进制的列先读到用户变量里面，再用 UNHEX 解码
+	mock.ExpectExec("LOAD DATA LOCAL INFILE 'Reader::sorm_bulk_load_[0-9]+' INTO TABLE `binary_model` " +
+		"CHARACTER SET utf8mb4 FIELDS TERMINATED BY ',' ENCLOSED BY '\"' ESCAPED BY '' " +
+		"LINES TERMINATED BY '\\\\n' \\(`name`,@sorm_bin_1,`ctime`\\) " +
+		"SET `payload`=UNHEX\\(@sorm_bin_1\\);").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	cst := time.FixedZone("CST", 8*3600)
+	rows := slices.Values([]*BinaryModel{
+		{Name: "a", Payload: []byte{0, '"', '\n', 0xff}, Ctime: time.Date(2026, 9, 1, 8, 30, 0, 0, time.UTC)},
+		{Name: "b", Ctime: time.Date(2026, 9, 1, 8, 30, 0, 0, time.UTC)},
+	})
+	loaded, err := NewBulkLoader[BinaryModel](db).Location(cst).Load(context.Background(), rows)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), loaded)
+	assert.Equal(t, `"a","00220aff","2026-09-01 16:30:00"`+"\n"+
+		`"b",NULL,"2026-09-01 16:30:00"`+"\n", content)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
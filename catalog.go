@@ -0,0 +1,233 @@
+package sorm
+
+import (
+	"bufio"
+	"github.com/xzhHas/sorm/internal/errs"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// catalogSuffixes 文件后缀对应的数据库方言，没有方言后缀的 .sql 文件适用于所有的数据库
+var catalogSuffixes = map[string]Dialect{
+	".mysql.sql":  MySQL,
+	".sqlite.sql": SQLite3,
+}
+
+// catalogNamePrefix 查询名字的注释，例如 -- name: ActiveUsers
+const catalogNamePrefix = "-- name:"
+
+// Catalog 从 .sql 文件里面加载的具名查询
+// 每个查询以 -- name: 开头，直到下一个 -- name: 或者文件结束，例如：
+//
+//	-- name: ActiveUsers
+//	SELECT * FROM `user` WHERE `status` = :status
+//
+// 文件名以 .mysql.sql 或者 .sqlite.sql 结尾的时候只用于对应的数据库，并且会覆盖同名的通用查询
+type Catalog struct {
+	queries map[string]string
+}
+
+// catalogEntry 加载过程中的查询，用于检查重名和方言覆盖
+type catalogEntry struct {
+	sql      string
+	file     string
+	specific bool
+}
+
+// NewCatalog 从 fsys 里面加载所有的 .sql 文件，只保留适用于 dialect 的查询
+// fsys 可以是 embed.FS，也可以是 os.DirFS 返回的目录。
+// 加载的时候会校验查询：名字不能重复，不能为空，引号和注释要闭合，括号要配对，不能混用 ? 和命名参数
+func NewCatalog(fsys fs.FS, dialect Dialect) (*Catalog, error) {
+	entries := make(map[string]catalogEntry, 16)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".sql" {
+			return nil
+		}
+		specific := false
+		for suffix, dl := range catalogSuffixes {
+			if strings.HasSuffix(p, suffix) {
+				if dl != dialect {
+					return nil
+				}
+				specific = true
+			}
+		}
+		queries, err := parseCatalogFile(fsys, p, dialect)
+		if err != nil {
+			return err
+		}
+		for _, q := range queries {
+			if prev, ok := entries[q.name]; ok && prev.specific == specific {
+				return errs.NewErrDuplicateQuery(q.name, prev.file, p)
+			} else if ok && prev.specific {
+				// 已经有方言专用的版本了
+				continue
+			}
+			entries[q.name] = catalogEntry{sql: q.sql, file: p, specific: specific}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &Catalog{
+		queries: make(map[string]string, len(entries)),
+	}
+	for name, e := range entries {
+		res.queries[name] = e.sql
+	}
+	return res, nil
+}
+
+// namedSQL 文件里面的一个查询
+type namedSQL struct {
+	name string
+	sql  string
+}
+
+// parseCatalogFile 按照 dialect 的规则解析并且校验一个 .sql 文件
+func parseCatalogFile(fsys fs.FS, p string, dialect Dialect) ([]namedSQL, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var res []namedSQL
+	var body strings.Builder
+	name := ""
+	finish := func() error {
+		query := strings.TrimSpace(body.String())
+		body.Reset()
+		if name == "" {
+			if query != "" && !isCommentOnly(query) {
+				return errs.NewErrInvalidQuery(p, "", errs.ErrMissingQueryName)
+			}
+			return nil
+		}
+		if err := validateCatalogSQL(dialect, query); err != nil {
+			return errs.NewErrInvalidQuery(p, name, err)
+		}
+		res = append(res, namedSQL{name: name, sql: query})
+		return nil
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, catalogNamePrefix) {
+			if err = finish(); err != nil {
+				return nil, err
+			}
+			name = strings.TrimSpace(strings.TrimPrefix(trimmed, catalogNamePrefix))
+			if name == "" || !isIdentifier(name) {
+				return nil, errs.NewErrInvalidQuery(p, name, errs.ErrInvalidQueryName)
+			}
+			for _, q := range res {
+				if q.name == name {
+					return nil, errs.NewErrDuplicateQuery(name, p, p)
+				}
+			}
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err = finish(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// validateCatalogSQL 校验一个查询
+func validateCatalogSQL(dialect Dialect, query string) error {
+	if query == "" || isCommentOnly(query) {
+		return errs.ErrEmptyQuery
+	}
+	tokens, err := tokenizeSQL(dialect, query)
+	if err != nil {
+		return err
+	}
+	var positional, named, depth int
+	for _, tk := range tokens {
+		switch tk.kind {
+		case tokenPositional:
+			positional++
+		case tokenNamed:
+			named++
+		case tokenCode:
+			for _, ch := range tk.text {
+				switch ch {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				if depth < 0 {
+					return errs.ErrUnbalancedParentheses
+				}
+			}
+		}
+	}
+	if depth != 0 {
+		return errs.ErrUnbalancedParentheses
+	}
+	if positional > 0 && named > 0 {
+		return errs.ErrMixedPlaceholders
+	}
+	return nil
+}
+
+// isCommentOnly 判断 query 是否只有单行注释
+func isCommentOnly(query string) bool {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// SQL 返回名字为 name 的查询
+func (c *Catalog) SQL(name string) (string, bool) {
+	query, ok := c.queries[name]
+	return query, ok
+}
+
+// Names 返回所有查询的名字，按照字典序排列
+func (c *Catalog) Names() []string {
+	res := make([]string, 0, len(c.queries))
+	for name := range c.queries {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// QueryFactory 根据参数创建原生查询，参数的用法和 RawQuery 一样
+type QueryFactory[T any] func(sess Session, args ...any) *RawQuerier[T]
+
+// CatalogQuery 返回名字为 name 的查询对应的 QueryFactory，查询不存在的时候返回错误
+// 一般在启动的时候调用，这样查询名字写错了能够尽早发现，例如：
+//
+//	activeUsers, err := CatalogQuery[User](catalog, "ActiveUsers")
+//	users, err := activeUsers(db, Named{"status": 1}).GetMulti(ctx)
+func CatalogQuery[T any](c *Catalog, name string) (QueryFactory[T], error) {
+	query, ok := c.queries[name]
+	if !ok {
+		return nil, errs.NewErrUnknownQuery(name)
+	}
+	return func(sess Session, args ...any) *RawQuerier[T] {
+		return RawQuery[T](sess, query, args...)
+	}, nil
+}
@@ -0,0 +1,177 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewCatalog(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/user.sql": {Data: []byte(`-- 用户相关的查询
+-- name: ActiveUsers
+-- 有效的用户
+SELECT * FROM test_model WHERE age > :age;
+
+-- name: UserByID
+SELECT * FROM test_model WHERE id = ?
+`)},
+		"queries/report.mysql.sql": {Data: []byte(`-- name: ActiveUsers
+SELECT * FROM test_model WHERE age > :age LIMIT 10
+-- name: MonthlyReport
+SELECT DATE_FORMAT(NOW(), '%Y-%m')
+`)},
+		"queries/report.sqlite.sql": {Data: []byte(`-- name: MonthlyReport
+SELECT strftime('%Y-%m', 'now')
+`)},
+		"README.md": {Data: []byte("-- name: Ignored")},
+	}
+
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		want    map[string]string
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			want: map[string]string{
+				"ActiveUsers":   "SELECT * FROM test_model WHERE age > :age LIMIT 10",
+				"MonthlyReport": "SELECT DATE_FORMAT(NOW(), '%Y-%m')",
+				"UserByID":      "SELECT * FROM test_model WHERE id = ?",
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: SQLite3,
+			want: map[string]string{
+				"ActiveUsers":   "-- 有效的用户\nSELECT * FROM test_model WHERE age > :age;",
+				"MonthlyReport": "SELECT strftime('%Y-%m', 'now')",
+				"UserByID":      "SELECT * FROM test_model WHERE id = ?",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewCatalog(fsys, tc.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, []string{"ActiveUsers", "MonthlyReport", "UserByID"}, c.Names())
+			for name, want := range tc.want {
+				got, ok := c.SQL(name)
+				assert.True(t, ok)
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func TestNewCatalog_Invalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr error
+	}{
+		{
+			name: "duplicate in file",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("-- name: A\nSELECT 1\n-- name: A\nSELECT 2")},
+			},
+			wantErr: errs.NewErrDuplicateQuery("A", "a.sql", "a.sql"),
+		},
+		{
+			name: "duplicate in files",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("-- name: A\nSELECT 1")},
+				"b.sql": {Data: []byte("-- name: A\nSELECT 2")},
+			},
+			wantErr: errs.NewErrDuplicateQuery("A", "a.sql", "b.sql"),
+		},
+		{
+			name: "missing name",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("SELECT 1\n-- name: A\nSELECT 2")},
+			},
+			wantErr: errs.NewErrInvalidQuery("a.sql", "", errs.ErrMissingQueryName),
+		},
+		{
+			name: "invalid name",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("-- name: 1A\nSELECT 1")},
+			},
+			wantErr: errs.NewErrInvalidQuery("a.sql", "1A", errs.ErrInvalidQueryName),
+		},
+		{
+			name: "empty",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("-- name: A\n-- TODO\n-- name: B\nSELECT 1")},
+			},
+			wantErr: errs.NewErrInvalidQuery("a.sql", "A", errs.ErrEmptyQuery),
+		},
+		{
+			name: "mixed placeholders",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("-- name: A\nSELECT * FROM t WHERE id = ? AND age = :age")},
+			},
+			wantErr: errs.NewErrInvalidQuery("a.sql", "A", errs.ErrMixedPlaceholders),
+		},
+		{
+			// 字符串里面的括号不算
+			name: "unbalanced parentheses",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("-- name: A\nSELECT * FROM t WHERE id IN (SELECT id FROM b WHERE name = ')'")},
+			},
+			wantErr: errs.NewErrInvalidQuery("a.sql", "A", errs.ErrUnbalancedParentheses),
+		},
+		{
+			name: "unterminated",
+			files: fstest.MapFS{
+				"a.sql": {Data: []byte("-- name: A\nSELECT 'abc")},
+			},
+			wantErr: errs.NewErrInvalidQuery("a.sql", "A", errs.NewErrUnterminatedSQL("'abc")),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewCatalog(tc.files, MySQL)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestCatalogQuery(t *testing.T) {
+	db := memoryDBWithDB("catalog_query", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES " +
+		"(1, 'Tom', 18, 'Cat'), (2, 'Jerry', 20, 'Mouse')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCatalog(fstest.MapFS{
+		"user.sql": {Data: []byte("-- name: UsersByIDs\nSELECT * FROM `test_model` WHERE `id` IN (:ids)")},
+	}, SQLite3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = CatalogQuery[TestModel](c, "Invalid")
+	assert.Equal(t, errs.NewErrUnknownQuery("Invalid"), err)
+
+	usersByIDs, err := CatalogQuery[TestModel](c, "UsersByIDs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := usersByIDs(db, Named{"ids": []int{2}}).GetMulti(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 2, FirstName: "Jerry", Age: 20, LastName: &sql.NullString{String: "Mouse", Valid: true}},
+	}, got)
+}
@@ -51,22 +51,62 @@ func C(name string) Column {
 	return Column{name: name}
 }
 
-// Add 创建一个 MathExpr 对象，表示当前列加上一个整数增量
-func (c Column) Add(delta int) MathExpr {
-	return MathExpr{
-		left:  c,
-		op:    opAdd,
-		right: value{val: delta},
-	}
+// Add 创建一个 MathExpr 对象，表示当前列加上 delta
+// delta 可以是值、列、函数或者其它表达式，例如 C("Age").Add(1)、C("Age").Add(C("Delta"))
+func (c Column) Add(delta any) MathExpr {
+	return mathExprOf(c, opAdd, delta)
 }
 
-// Multi 创建一个 MathExpr 对象，表示当前列乘以一个整数增量
-func (c Column) Multi(delta int) MathExpr {
-	return MathExpr{
-		left:  c,
-		op:    opAdd,
-		right: value{val: delta},
-	}
+// Sub 创建一个 MathExpr 对象，表示当前列减去 delta
+func (c Column) Sub(delta any) MathExpr {
+	return mathExprOf(c, opSub, delta)
+}
+
+// Multi 创建一个 MathExpr 对象，表示当前列乘以 delta
+func (c Column) Multi(delta any) MathExpr {
+	return mathExprOf(c, opMulti, delta)
+}
+
+// Div 创建一个 MathExpr 对象，表示当前列除以 delta
+// 注意整数相除的结果类型依赖于数据库，例如 SQLite 是整除，而 MySQL 会返回小数
+func (c Column) Div(delta any) MathExpr {
+	return mathExprOf(c, opDiv, delta)
+}
+
+// Mod 创建一个 MathExpr 对象，表示当前列对 delta 取模
+func (c Column) Mod(delta any) MathExpr {
+	return mathExprOf(c, opMod, delta)
+}
+
+// BitAnd 创建一个 MathExpr 对象，表示当前列和 val 按位与
+// 例如 C("Flags").BitAnd(4).GT(0) 判断某个标记位是否被设置
+func (c Column) BitAnd(val any) MathExpr {
+	return mathExprOf(c, opBitAnd, val)
+}
+
+// BitOr 创建一个 MathExpr 对象，表示当前列和 val 按位或
+func (c Column) BitOr(val any) MathExpr {
+	return mathExprOf(c, opBitOr, val)
+}
+
+// BitXor 创建一个 MathExpr 对象，表示当前列和 val 按位异或
+func (c Column) BitXor(val any) MathExpr {
+	return mathExprOf(c, opBitXor, val)
+}
+
+// LeftShift 创建一个 MathExpr 对象，表示当前列左移 n 位
+func (c Column) LeftShift(n any) MathExpr {
+	return mathExprOf(c, opLeftShift, n)
+}
+
+// RightShift 创建一个 MathExpr 对象，表示当前列右移 n 位
+func (c Column) RightShift(n any) MathExpr {
+	return mathExprOf(c, opRightShift, n)
+}
+
+// Concat 创建一个 MathExpr 对象，表示当前列和 val 进行字符串拼接
+func (c Column) Concat(val any) MathExpr {
+	return mathExprOf(c, opConcat, val)
 }
 
 // EQ 创建一个 Predicate 对象，表示当前列等于某个值
@@ -97,6 +137,24 @@ func (c Column) GT(arg any) Predicate {
 	}
 }
 
+// LTE 创建一个 Predicate 对象，表示当前列小于等于某个值
+func (c Column) LTE(arg any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opLTE,
+		right: exprOf(arg),
+	}
+}
+
+// GTE 创建一个 Predicate 对象，表示当前列大于等于某个值
+func (c Column) GTE(arg any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opGTE,
+		right: exprOf(arg),
+	}
+}
+
 // In 创建一个 Predicate 对象，表示当前列的值在给定的多个值中
 // In 有两种输入，一种是 IN 子查询
 // 另外一种就是普通的值
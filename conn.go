@@ -0,0 +1,189 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/xzhHas/sorm/model"
+	"io"
+)
+
+var _ Session = &Conn{}
+
+// Conn 固定使用连接池里面的同一个连接的会话
+// DB 的每条语句都可能使用不同的连接，所以 SET time_zone 之类的会话变量、临时表、
+// LAST_INSERT_ID() 以及 SQLite 的 ATTACH 这种只对当前连接有效的操作，需要在 Conn 上执行。
+// Conn 上的查询总是在主库上执行，用完之后需要调用 Close 把连接还给连接池
+type Conn struct {
+	conn *sql.Conn
+	db   *DB
+}
+
+// Conn 从连接池里面取出一个连接，连接池已经满了的时候会等待，直到 ctx 被取消
+func (db *DB) Conn(ctx context.Context) (*Conn, error) {
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: conn, db: db}, nil
+}
+
+// getCore 返回创建这个连接的 DB 的 core
+func (c *Conn) getCore() core {
+	return c.db.core
+}
+
+// Dialect 返回数据库方言
+func (c *Conn) Dialect() Dialect {
+	return c.db.dialect
+}
+
+// Registry 返回模型的元数据注册中心
+func (c *Conn) Registry() model.Registry {
+	return c.db.r
+}
+
+// QueryContext 在这个连接上查询多行数据，不经过中间件
+func (c *Conn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.conn.QueryContext(ctx, query, args...)
+}
+
+// ExecContext 在这个连接上执行增删改之类的语句，不经过中间件
+func (c *Conn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.conn.ExecContext(ctx, query, args...)
+}
+
+// Exec 在这个连接上执行 SET time_zone = '+08:00'、CREATE TEMPORARY TABLE 之类的语句，
+// 和 RawQuery 一样会经过中间件，类型是 TypeRaw
+func (c *Conn) Exec(ctx context.Context, query string, args ...any) Result {
+	return RawQuery[any](c, query, args...).Exec(ctx)
+}
+
+// BeginTx 在这个连接上开启事务
+func (c *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	return c.db.beginTx(ctx, c.conn.BeginTx, opts)
+}
+
+// DoTx 在这个连接上开启事务执行 fn，其它的行为和 DB.DoTx 一样，包括重试策略
+func (c *Conn) DoTx(ctx context.Context, fn FN, opts *sql.TxOptions) error {
+	return c.db.runTx(ctx, c.BeginTx, fn, opts)
+}
+
+// Close 把连接还给连接池，连接上的会话变量和临时表会保留下来，
+// 所以如果不希望影响后面使用这个连接的语句，关闭之前需要先清理
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// OnConnectFunc 连接池创建新连接之后执行的回调，通常用来执行 SET 之类的初始化语句，参考 DBWithOnConnect
+// 返回错误的时候这个连接会被关闭，使用这个连接的语句会返回这个错误
+type OnConnectFunc func(ctx context.Context, conn DriverConn) error
+
+// DriverConn 连接池刚刚创建的驱动连接
+type DriverConn struct {
+	conn driver.Conn
+}
+
+// Raw 返回驱动的连接，用于执行驱动特有的操作
+func (c DriverConn) Raw() driver.Conn {
+	return c.conn
+}
+
+// Exec 在这个连接上执行语句，不经过中间件
+// 参数按照 database/sql 默认的规则转换，驱动特有的参数类型需要通过 Raw 自己处理
+func (c DriverConn) Exec(ctx context.Context, query string, args ...any) error {
+	named := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		val, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return err
+		}
+		named = append(named, driver.NamedValue{Ordinal: i + 1, Value: val})
+	}
+	if execer, ok := c.conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, named)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	if s, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = s.ExecContext(ctx, named)
+		return err
+	}
+	vals := make([]driver.Value, 0, len(named))
+	for _, nv := range named {
+		vals = append(vals, nv.Value)
+	}
+	_, err = stmt.Exec(vals)
+	return err
+}
+
+// DBWithOnConnect 连接池每次创建新连接的时候，都会在这个连接上依次执行 fns
+// 例如设置时区：
+//
+//	sorm.DBWithOnConnect(func(ctx context.Context, conn sorm.DriverConn) error {
+//		return conn.Exec(ctx, "SET time_zone = '+08:00'")
+//	})
+//
+// 只能和 Open 或者 OpenConnector 一起使用，因为 OpenDB 拿到的连接池已经创建好了；
+// 另外也不会作用于 DBWithReplicas 设置的从库
+func DBWithOnConnect(fns ...OnConnectFunc) DBOption {
+	return func(db *DB) {
+		db.onConnect = append(db.onConnect, fns...)
+	}
+}
+
+// hookConnector 在创建连接之后执行 OnConnectFunc
+type hookConnector struct {
+	driver.Connector
+	onConnect []OnConnectFunc
+}
+
+func (c *hookConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range c.onConnect {
+		if err = fn(ctx, DriverConn{conn: conn}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Close 关闭连接池的时候 database/sql 会调用 Connector 的 Close
+func (c *hookConnector) Close() error {
+	if closer, ok := c.Connector.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// dsnConnector 驱动没有实现 driver.DriverContext 的时候，通过 dsn 创建连接，和 sql.Open 的行为一样
+type dsnConnector struct {
+	dsn string
+	d   driver.Driver
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.d.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.d
+}
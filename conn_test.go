@@ -0,0 +1,156 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDB_Conn(t *testing.T) {
+	var types []string
+	db, err := Open("sqlite3", "file:conn_test.db?cache=shared&mode=memory", DBWithDialect(SQLite3),
+		DBWithMiddleware(func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				types = append(types, qc.Type)
+				return next(ctx, qc)
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	// 临时表只对当前连接有效
+	res := conn.Exec(ctx, "CREATE TEMP TABLE `test_model`(`id` INTEGER PRIMARY KEY, `first_name` TEXT, `age` INTEGER, `last_name` TEXT);")
+	assert.NoError(t, res.Err())
+	res = NewInserter[TestModel](conn).Values(&TestModel{Id: 1, FirstName: "Tom", Age: 18}).Exec(ctx)
+	assert.NoError(t, res.Err())
+
+	err = conn.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		res := NewInserter[TestModel](tx).Values(&TestModel{Id: 2, FirstName: "Jerry", Age: 20}).Exec(ctx)
+		return res.Err()
+	}, nil)
+	assert.NoError(t, err)
+	err = conn.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		res := NewInserter[TestModel](tx).Values(&TestModel{Id: 3, FirstName: "Cat", Age: 22}).Exec(ctx)
+		assert.NoError(t, res.Err())
+		return errs.ErrNoRows
+	}, nil)
+	assert.Equal(t, errs.ErrNoRows, err)
+
+	rows, err := NewSelector[TestModel](conn).OrderBy(Asc("Id")).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "Jerry", rows[1].FirstName)
+	assert.Equal(t, []string{TypeRaw, TypeInsert, TypeBegin, TypeInsert, TypeCommit,
+		TypeBegin, TypeInsert, TypeRollback, TypeSelect}, types)
+
+	// 连接池里面的其它连接看不到这张临时表
+	_, err = NewSelector[TestModel](db).GetMulti(ctx)
+	assert.Error(t, err)
+}
+
+func TestDB_OnConnect(t *testing.T) {
+	var connects atomic.Int32
+	db, err := Open("sqlite3", "file:on_connect_test.db?cache=shared&mode=memory", DBWithDialect(SQLite3),
+		DBWithOnConnect(func(ctx context.Context, conn DriverConn) error {
+			connects.Add(1)
+			return conn.Exec(ctx, "CREATE TEMP TABLE `test_model`(`id` INTEGER PRIMARY KEY, `first_name` TEXT, `age` INTEGER, `last_name` TEXT);")
+		}, func(ctx context.Context, conn DriverConn) error {
+			return conn.Exec(ctx, "INSERT INTO `test_model`(`id`, `first_name`, `age`) VALUES (?, ?, ?);", 1, "Tom", 18)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+
+	// 同时持有两个连接，每个连接都执行了初始化语句
+	conn1, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn1.Close() }()
+	conn2, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn2.Close() }()
+	for _, sess := range []Session{conn1, conn2} {
+		row, err := NewSelector[TestModel](sess).Get(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "Tom", row.FirstName)
+	}
+	assert.Equal(t, int32(2), connects.Load())
+
+	// 初始化失败的时候返回错误
+	bizErr := errors.New("init failed")
+	failed, err := Open("sqlite3", "file:on_connect_fail.db?cache=shared&mode=memory",
+		DBWithOnConnect(func(ctx context.Context, conn DriverConn) error {
+			return bizErr
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = failed.Close() }()
+	_, err = failed.Conn(ctx)
+	assert.Equal(t, bizErr, err)
+
+	sqlDB, err := sql.Open("sqlite3", "file:on_connect_open_db.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sqlDB.Close() }()
+	_, err = OpenDB(sqlDB, DBWithMaxOpenConns(3), DBWithOnConnect(func(ctx context.Context, conn DriverConn) error {
+		return nil
+	}))
+	assert.Equal(t, errs.ErrOnConnectWithOpenDB, err)
+	// 返回错误的时候不能修改连接池的设置
+	assert.Equal(t, 0, sqlDB.Stats().MaxOpenConnections)
+}
+
+func TestConn_InTx(t *testing.T) {
+	db, err := Open("sqlite3", "file:conn_in_tx_test.db?cache=shared&mode=memory", DBWithDialect(SQLite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	res := conn.Exec(ctx, "CREATE TEMP TABLE `test_model`(`id` INTEGER PRIMARY KEY, `first_name` TEXT, `age` INTEGER, `last_name` TEXT);")
+	assert.NoError(t, res.Err())
+	res = NewInserter[TestModel](conn).Values(&TestModel{Id: 3, FirstName: "Cat", Age: 22}).Exec(ctx)
+	assert.NoError(t, res.Err())
+
+	// 第二批的主键冲突，第一批也要回滚
+	res = NewInserter[TestModel](conn).Values(
+		&TestModel{Id: 1, FirstName: "Tom", Age: 18},
+		&TestModel{Id: 2, FirstName: "Jerry", Age: 20},
+		&TestModel{Id: 3, FirstName: "Cat", Age: 22},
+	).BatchSize(2).InTx().Exec(ctx)
+	assert.Error(t, res.Err())
+	rows, err := NewSelector[TestModel](conn).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rows))
+
+	// 无法开启事务的会话直接返回错误
+	sdb := &ShardingDB{}
+	err = runInTx(ctx, sdb, func(ctx context.Context, sess Session) error {
+		return nil
+	})
+	assert.Equal(t, errs.NewErrTxUnsupported(sdb), err)
+}
@@ -3,9 +3,12 @@ package sorm
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/xzhHas/sorm/internal/valuer"
 	"github.com/xzhHas/sorm/model"
+	"sync"
+	"sync/atomic"
 )
 
 // core 作为 orm 库的核心组件设计，封装一些基础服务和配置，以支持更高级别的数据库交互操作
@@ -27,22 +30,57 @@ type core struct {
 	// 中间件可以实现日志记录、事务管理、权限检查等功能。
 	// 通过将中间件注册到 core 结构体中，可以在执行数据库操作之前和之后插入自定义逻辑。
 	ms []Middleware
+	// readers 用于 MySQL 的 LOAD DATA LOCAL INFILE，没有设置的时候 BulkLoader 使用预编译语句导入
+	readers *readerHandler
+	// strictWhere 为 true 的时候，UPDATE 和 DELETE 的 WHERE 条件恒为真也会被当成没有 WHERE
+	strictWhere bool
+	// partitions 已经创建过的分表，避免每次插入都执行建表语句，参考 model.WithTimePartition
+	partitions *sync.Map
+	// returning 缓存 Dialect.insertReturning 的结果，0 代表还没有确认过，1 代表支持，2 代表不支持
+	returning *atomic.Int32
+	// sharding 是通过 ShardingDB 创建的 builder 使用的 ShardingDB，不为 nil 的时候按照分片规则路由到物理库和物理表
+	sharding *ShardingDB
+}
+
+// insertReturning 判断插入单行的时候能不能使用 RETURNING，结果缓存下来，每个 DB 只需要确认一次
+func (c core) insertReturning(ctx context.Context, sess Session) (bool, error) {
+	if c.returning != nil {
+		if v := c.returning.Load(); v != 0 {
+			return v == 1, nil
+		}
+	}
+	ok, err := c.dialect.insertReturning(ctx, sess)
+	if err != nil {
+		return false, err
+	}
+	if c.returning != nil {
+		var v int32 = 2
+		if ok {
+			v = 1
+		}
+		c.returning.Store(v)
+	}
+	return ok, nil
 }
 
 // getHandler 根据提供的查询上下文执行数据库查询，并将结果映射到指定的结构体类型 T
-func getHandler[T any](ctx context.Context, sess session, c core, qc *QueryContext) *QueryResult {
+func getHandler[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
 	q, err := qc.Builder.Build()
 	if err != nil {
 		return &QueryResult{
 			Err: err,
 		}
 	}
-	rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+	rows, err := queryRead(ctx, sess, qc, q)
 	if err != nil {
 		return &QueryResult{
 			Err: err,
 		}
 	}
+	// 不关闭的话连接不会被放回连接池
+	defer func() {
+		_ = rows.Close()
+	}()
 
 	if !rows.Next() {
 		return &QueryResult{
@@ -67,17 +105,12 @@ func getHandler[T any](ctx context.Context, sess session, c core, qc *QueryConte
 }
 
 // get 函数用于执行查询操作，它支持泛型参数 T，可以处理不同类型的查询请求
-func get[T any](ctx context.Context, c core, sess session, qc *QueryContext) *QueryResult {
-	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+func get[T any](ctx context.Context, c core, sess Session, qc *QueryContext) *QueryResult {
+	return handle(ctx, c, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
 		return getHandler[T](ctx, sess, c, qc)
-	}
-	ms := c.ms
-	for i := len(ms) - 1; i >= 0; i-- {
-		handler = ms[i](handler)
-	}
-	return handler(ctx, qc)
+	})
 }
-func getMultiHandler[T any](ctx context.Context, sess session, c core, qc *QueryContext) *QueryResult {
+func getMultiHandler[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
 	q, err := qc.Builder.Build()
 	if err != nil {
 		return &QueryResult{
@@ -85,7 +118,7 @@ func getMultiHandler[T any](ctx context.Context, sess session, c core, qc *Query
 		}
 	}
 
-	rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+	rows, err := queryRead(ctx, sess, qc, q)
 	if err != nil {
 		return &QueryResult{
 			Err: err,
@@ -128,41 +161,92 @@ func getMultiHandler[T any](ctx context.Context, sess session, c core, qc *Query
 	}
 }
 
-func getMulti[T any](ctx context.Context, c core, sess session, qc *QueryContext) *QueryResult {
-	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+func getMulti[T any](ctx context.Context, c core, sess Session, qc *QueryContext) *QueryResult {
+	return handle(ctx, c, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
 		return getMultiHandler[T](ctx, sess, c, qc)
-	}
-	ms := c.ms
-	for i := len(ms) - 1; i >= 0; i-- {
-		handler = ms[i](handler)
-	}
-	return handler(ctx, qc)
+	})
 }
 
 // exec 执行一个数据库查询操作。
 // 它接受一个上下文对象，一个数据库会话，一个核心处理对象以及一个查询上下文作为参数。
 // 返回值包含查询结果和可能的错误信息。
-func exec(ctx context.Context, sess session, c core, qc *QueryContext) Result {
-	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+func exec(ctx context.Context, sess Session, c core, qc *QueryContext) Result {
+	qr := handle(ctx, c, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
 		q, err := qc.Builder.Build()
 		if err != nil {
 			return &QueryResult{
 				Err: err,
 			}
 		}
-		res, err := sess.execContext(ctx, q.SQL, q.Args...)
+		res, err := sess.ExecContext(ctx, q.SQL, q.Args...)
 		return &QueryResult{Err: err, Result: res}
+	})
+	var res sql.Result
+	if qr.Result != nil {
+		res = qr.Result.(sql.Result)
 	}
-	// 获取中间件列表
+	return Result{err: qr.Err, res: res}
+}
+
+// handle 使用中间件把 handler 包装起来，然后执行
+// 中间件按照注册的顺序执行，即第一个中间件在最外层
+func handle(ctx context.Context, c core, qc *QueryContext, handler HandleFunc) *QueryResult {
 	ms := c.ms
-	// 通过中间件链反转的顺序将处理函数与中间件结合
 	for i := len(ms) - 1; i >= 0; i-- {
 		handler = ms[i](handler)
 	}
-	qr := handler(ctx, qc)
-	var res sql.Result
-	if qr.Result != nil {
-		res = qr.Result.(sql.Result)
+	return handler(ctx, qc)
+}
+
+// execFunc 在 sess 上执行一条语句
+type execFunc func(ctx context.Context, sess Session) Result
+
+// execBatch 依次执行多个语句，并且汇总结果
+// failFast 为 true 的时候，遇到第一个错误就停下来，一般用在事务里面；
+// 否则会继续执行剩下的批次，并且记录下每一批的错误
+func execBatch(ctx context.Context, sess Session, fns []execFunc, failFast bool) Result {
+	results := make(batchResult, 0, len(fns))
+	var chunkErrs []*ChunkError
+	for idx, fn := range fns {
+		res := fn(ctx, sess)
+		if res.err != nil {
+			chunkErrs = append(chunkErrs, &ChunkError{Index: idx, Err: res.err})
+			if failFast {
+				break
+			}
+			continue
+		}
+		results = append(results, res.res)
 	}
-	return Result{err: qr.Err, res: res}
+	res := Result{chunkErrs: chunkErrs}
+	if len(results) > 0 {
+		res.res = results
+	}
+	if len(chunkErrs) > 0 {
+		errList := make([]error, 0, len(chunkErrs))
+		for _, e := range chunkErrs {
+			errList = append(errList, e)
+		}
+		res.err = errors.Join(errList...)
+	}
+	return res
+}
+
+// execChunks 执行拆分出来的多条语句
+// inTx 为 false 的时候，某一批失败不会影响其它批次；
+// 否则全部批次在同一个事务里面执行，遇到第一个错误就停下来并且回滚
+func execChunks(ctx context.Context, sess Session, fns []execFunc, inTx bool) Result {
+	if !inTx {
+		return execBatch(ctx, sess, fns, false)
+	}
+	var res Result
+	err := runInTx(ctx, sess, func(ctx context.Context, sess Session) error {
+		res = execBatch(ctx, sess, fns, true)
+		return res.err
+	})
+	if res.err == nil && err != nil {
+		// 提交失败了
+		res.err = err
+	}
+	return res
 }
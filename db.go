@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"github.com/xzhHas/sorm/internal/errs"
 	"github.com/xzhHas/sorm/internal/valuer"
 	"github.com/xzhHas/sorm/model"
-	"log"
-	"time"
+	"io"
+	"sync"
+	"sync/atomic"
 )
 
 type DBOption func(*DB)
@@ -16,46 +18,82 @@ type DBOption func(*DB)
 type DB struct {
 	core
 	db *sql.DB
-}
-
-// Wait 会等待数据库连接
-// 注意只能用于测试
-func (db *DB) Wait() error {
-	err := db.db.Ping()
-	for err == driver.ErrBadConn {
-		log.Printf("等待数据库启动...")
-		err = db.db.Ping()
-		time.Sleep(time.Second)
-	}
-	return err
+	// retry 事务的重试策略，为 nil 的时候不重试
+	retry *RetryPolicy
+	// replicas 从库，为 nil 的时候所有的查询都在主库上执行
+	replicas *replicaSet
+	// onConnect 连接池创建新连接之后执行的回调，参考 DBWithOnConnect
+	onConnect []OnConnectFunc
+	// pool 连接池的设置，在所有的选项都应用之后再作用到 db 上，参考 DBWithMaxOpenConns
+	pool []func(db *sql.DB)
 }
 
 // Open 创建一个 DB 实例
-// driver 也就是驱动的名字，例如：mysql、sqlite3
+// driverName 也就是驱动的名字，例如：mysql、sqlite3
 // dsn 就是数据库的连接字符串
 // opts 自定义驱动，例如：分库分表
-func Open(driver string, dsn string, opts ...DBOption) (*DB, error) {
-	db, err := sql.Open(driver, dsn)
+func Open(driverName string, dsn string, opts ...DBOption) (*DB, error) {
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, err
 	}
-	return OpenDB(db, opts...)
+	// 这里只是为了拿到驱动，连接池由 OpenConnector 创建，这样才能在创建连接之后执行 DBWithOnConnect 的回调
+	d := db.Driver()
+	_ = db.Close()
+	var connector driver.Connector = dsnConnector{dsn: dsn, d: d}
+	if dc, ok := d.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return OpenConnector(connector, opts...)
+}
+
+// OpenConnector 使用 connector 创建连接池，例如 mysql.NewConnector 创建的 Connector
+func OpenConnector(connector driver.Connector, opts ...DBOption) (*DB, error) {
+	hc := &hookConnector{Connector: connector}
+	res := newDB(sql.OpenDB(hc), opts)
+	// 连接池是懒加载的，在第一次使用之前设置回调就可以
+	hc.onConnect = res.onConnect
+	res.startHealthCheck()
+	return res, nil
 }
 
 // OpenDB 用于初始化并返回一个配置好的*DB实例
+// 连接池已经创建好了，所以不能使用 DBWithOnConnect
 func OpenDB(db *sql.DB, opts ...DBOption) (*DB, error) {
+	// 先在空的 DB 上面检查，避免返回错误之前已经修改了 db 的连接池设置
+	probe := &DB{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	if len(probe.onConnect) > 0 {
+		return nil, errs.ErrOnConnectWithOpenDB
+	}
+	res := newDB(db, opts)
+	res.startHealthCheck()
+	return res, nil
+}
+
+// newDB 创建 DB 并且应用 opts
+func newDB(db *sql.DB, opts []DBOption) *DB {
 	res := &DB{
 		core: core{
 			dialect:    MySQL,
 			r:          model.NewRegistry(),
 			valCreator: valuer.NewUnsafeValue,
+			partitions: &sync.Map{},
+			returning:  &atomic.Int32{},
 		},
 		db: db,
 	}
 	for _, opt := range opts {
 		opt(res)
 	}
-	return res, nil
+	for _, fn := range res.pool {
+		fn(db)
+	}
+	return res
 }
 
 // DBWithDialect 用于指定数据库方言(Open默认设置为MySQL)
@@ -87,6 +125,29 @@ func DBWithMiddleware(ms ...Middleware) DBOption {
 	}
 }
 
+// DBWithLoadDataReader 允许 BulkLoader 在 MySQL 上使用 LOAD DATA LOCAL INFILE 导入数据
+// register 和 deregister 用于向驱动注册和注销数据来源，
+// 使用 github.com/go-sql-driver/mysql 的时候，分别传入 mysql.RegisterReaderHandler 和 mysql.DeregisterReaderHandler。
+// 注意 MySQL 服务端需要开启 local_infile
+func DBWithLoadDataReader(register func(name string, handler func() io.Reader),
+	deregister func(name string)) DBOption {
+	return func(db *DB) {
+		db.readers = &readerHandler{
+			register:   register,
+			deregister: deregister,
+		}
+	}
+}
+
+// DBWithStrictWhere 开启严格的 WHERE 检查
+// UPDATE 和 DELETE 默认要求有 WHERE 条件，开启之后恒为真的条件也会被拒绝，例如 Raw("1=1")，
+// 返回的错误同样可以用 errors.Is(err, ErrMissingWhere) 判断
+func DBWithStrictWhere() DBOption {
+	return func(db *DB) {
+		db.strictWhere = true
+	}
+}
+
 // MustNewDB 创建一个 DB，如果失败则会 panic
 // 我个人不太喜欢这种
 func MustNewDB(driver string, dsn string, opts ...DBOption) *DB {
@@ -98,23 +159,59 @@ func MustNewDB(driver string, dsn string, opts ...DBOption) *DB {
 }
 
 // BeginTx 开启事务
+// 开启、提交和回滚都会经过中间件，对应的 QueryContext.Type 是 TypeBegin、TypeCommit 和 TypeRollback
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
-	tx, err := db.db.BeginTx(ctx, opts)
-	if err != nil {
-		return nil, err
+	return db.beginTx(ctx, db.db.BeginTx, opts)
+}
+
+// beginTx 经过中间件调用 begin 开启事务，begin 可以是连接池的，也可以是某个连接的
+func (db *DB) beginTx(ctx context.Context,
+	begin func(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error), opts *sql.TxOptions) (*Tx, error) {
+	qr := handle(ctx, db.core, &QueryContext{
+		Type:    TypeBegin,
+		Builder: txStatement("BEGIN"),
+	}, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		tx, err := begin(ctx, opts)
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		return &QueryResult{Result: &Tx{tx: tx, db: db, ctx: ctx}}
+	})
+	tx, ok := qr.Result.(*Tx)
+	if qr.Err != nil || !ok {
+		return nil, qr.Err
 	}
-	return &Tx{tx: tx, db: db}, nil
+	return tx, nil
 }
 
 type FN func(ctx context.Context, tx *Tx) error
 
+// beginFunc 开启一个事务，例如 DB.BeginTx 和 Conn.BeginTx
+type beginFunc func(ctx context.Context, opts *sql.TxOptions) (*Tx, error)
+
 // DoTx 将会开启事务执行 fn。如果 fn 返回错误或者发生 panic，事务将会回滚，
-// 否则提交事务
-func (db *DB) DoTx(ctx context.Context, fn FN, opts *sql.TxOptions) (err error) {
+// 否则提交事务。
+// 传给 fn 的 context 带有这个事务，fn 里面可以通过 Session 拿到它。
+// 通过 DBWithRetryPolicy 设置了重试策略的时候，可以重试的错误会在新的事务里面重新执行 fn，参考 RetryPolicy
+func (db *DB) DoTx(ctx context.Context, fn FN, opts *sql.TxOptions) error {
+	return db.runTx(ctx, db.BeginTx, fn, opts)
+}
+
+// runTx 通过 begin 开启事务执行 fn，设置了重试策略的时候按照策略重试
+func (db *DB) runTx(ctx context.Context, begin beginFunc, fn FN, opts *sql.TxOptions) error {
+	if db.retry != nil {
+		return db.doTxWithRetry(ctx, begin, fn, opts)
+	}
+	_, err := db.doTx(ctx, begin, fn, opts)
+	return err
+}
+
+// doTx 开启事务执行一次 fn，commitFailed 表示 fn 执行成功了，但是提交的时候失败了
+func (db *DB) doTx(ctx context.Context, begin beginFunc, fn FN, opts *sql.TxOptions) (commitFailed bool, err error) {
 	var tx *Tx
-	tx, err = db.BeginTx(ctx, opts)
+	tx, err = begin(ctx, opts)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	panicked := true
@@ -126,17 +223,18 @@ func (db *DB) DoTx(ctx context.Context, fn FN, opts *sql.TxOptions) (err error)
 			}
 		} else {
 			err = tx.Commit()
+			commitFailed = err != nil
 		}
 	}()
 
-	err = fn(ctx, tx)
+	err = fn(contextWithTx(ctx, tx), tx)
 	panicked = false
-	return err
+	return false, err
 }
 
 // Close 关闭数据库连接
 func (db *DB) Close() error {
-	return db.db.Close()
+	return errors.Join(db.db.Close(), db.closeReplicas())
 }
 
 // getCore 获取 core
@@ -144,13 +242,23 @@ func (db *DB) getCore() core {
 	return db.core
 }
 
-// queryContext 查询多行数据
-func (db *DB) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+// Dialect 返回数据库方言
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+// Registry 返回模型的元数据注册中心
+func (db *DB) Registry() model.Registry {
+	return db.r
+}
+
+// QueryContext 查询多行数据，不经过中间件
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
 	return db.db.QueryContext(ctx, query, args...)
 }
 
-// execContext 增改删
+// ExecContext 增改删，不经过中间件
 // 它接收一个context.Context对象用于控制SQL执行的超时和取消
-func (db *DB) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	return db.db.ExecContext(ctx, query, args...)
 }
@@ -1,93 +1,235 @@
 package sorm
 
 import (
-	"fmt"
-	"reflect"
-	"strings"
+	"context"
+	"github.com/xzhHas/sorm/internal/errs"
 )
 
 // Deleter 结构体表示一个用于执行数据库删除操作的对象
 // 参数 T 表示要删除的数据类型
 type Deleter[T any] struct {
-	sb    strings.Builder
+	builder
+	// table 删除的表，没有指定的时候使用 T 对应的表，也可以是 JOIN
+	table TableReference
+	// where 删除操作的 WHERE 条件
 	where []Predicate
-	table string
-	args  []any
-	sess  session
+	// orderBy 和 limit 用于限制删除的行
+	orderBy []OrderBy
+	limit   int
+	// returning 为 true 的时候生成 RETURNING 子句，returningCols 为空代表 RETURNING *
+	returning     bool
+	returningCols []Selectable
+	// allowFullTable 允许没有 WHERE 条件，也就是删除整个表
+	allowFullTable bool
+	sess           Session
 }
 
-func NewDeleter[T any](sess session) *Deleter[T] {
-	return &Deleter[T]{}
+// NewDeleter 创建并返回一个新的 Deleter 实例
+func NewDeleter[T any](sess Session) *Deleter[T] {
+	c := coreOf(sess)
+	return &Deleter[T]{
+		builder: builder{
+			core:    c,
+			dialect: c.dialect,
+			quoter:  c.dialect.quoter(),
+		},
+		sess: sess,
+	}
+}
+
+// From 方法用于设置要删除的表，没有设置的时候使用 T 对应的表
+// 可以是 JOIN，此时删除的是最左边的表，其它的表用于筛选
+func (d *Deleter[T]) From(tbl TableReference) *Deleter[T] {
+	d.table = tbl
+	return d
+}
+
+// Where 方法用于设置删除操作的条件
+func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
+	d.where = ps
+	return d
+}
+
+// AllowFullTable 允许在没有 WHERE 条件的情况下删除整个表
+// 默认情况下没有 WHERE 的 DELETE 会返回 ErrMissingWhere，LIMIT 并不能代替 WHERE
+func (d *Deleter[T]) AllowFullTable() *Deleter[T] {
+	d.allowFullTable = true
+	return d
 }
 
+// OrderBy 设置删除的顺序，一般和 Limit 一起使用
+func (d *Deleter[T]) OrderBy(obs ...OrderBy) *Deleter[T] {
+	d.orderBy = obs
+	return d
+}
+
+// Limit 最多删除 limit 行
+// MySQL 直接使用 DELETE ... ORDER BY ... LIMIT；
+// SQLite 默认不支持这种语法，所以会改写成 DELETE ... WHERE rowid IN (SELECT rowid ...)，
+// 因此在 SQLite 里面不能用于 WITHOUT ROWID 的表
+func (d *Deleter[T]) Limit(limit int) *Deleter[T] {
+	d.limit = limit
+	return d
+}
+
+// Returning 返回被删除的行，cols 为空的时候返回所有列，只有支持 RETURNING 的数据库才能使用，例如 SQLite 3.35 以上
+// 被删除的行通过 GetMulti 读取
+func (d *Deleter[T]) Returning(cols ...Selectable) *Deleter[T] {
+	d.returning = true
+	d.returningCols = cols
+	return d
+}
+
+// Build 构建 DELETE 语句
+// 删除的目标可以是 JOIN，此时删除的是 JOIN 最左边的表：
+// MySQL 生成 DELETE t FROM t JOIN ...，不能和 ORDER BY、LIMIT 一起使用；
+// SQLite 改写成 DELETE FROM t WHERE rowid IN (SELECT t.rowid FROM t JOIN ...)
 func (d *Deleter[T]) Build() (*Query, error) {
-	d.sb.WriteString("DELETE FROM ")
-	if d.table == "" {
-		var t T
-		d.sb.WriteByte('`')
-		d.sb.WriteString(reflect.TypeOf(t).Name())
-		d.sb.WriteByte('`')
-	} else {
-		d.sb.WriteString(d.table)
+	var err error
+	d.model, err = d.r.Get(new(T))
+	if err != nil {
+		return nil, err
 	}
-
-	if len(d.where) > 0 {
-		d.sb.WriteString(" WHERE ")
-		for i, p := range d.where {
-			if i > 0 {
-				d.sb.WriteString(" AND ")
-			}
-			if err := d.buildExpression(p); err != nil {
+	if d.returning && !d.dialect.supportsReturning() {
+		return nil, errs.ErrUnsupportedReturning
+	}
+	target, err := targetTable(d.table)
+	if err != nil {
+		return nil, err
+	}
+	if !d.allowFullTable {
+		if err = d.checkWhere(d.table, d.where); err != nil {
+			return nil, err
+		}
+	}
+	_, isJoin := d.table.(Join)
+	limited := len(d.orderBy) > 0 || d.limit > 0
+	switch {
+	case isJoin && d.dialect.supportsJoinUpdate():
+		if limited {
+			return nil, errs.ErrJoinDeleteWithLimit
+		}
+		d.sb.WriteString("DELETE ")
+		if err = d.quoteTableName(target); err != nil {
+			return nil, err
+		}
+		d.sb.WriteString(" FROM ")
+		if err = d.buildTable(d.table); err != nil {
+			return nil, err
+		}
+		err = d.buildFilter()
+	case isJoin || (limited && !d.dialect.supportsDeleteLimit()):
+		d.sb.WriteString("DELETE FROM ")
+		if err = d.buildTable(target); err != nil {
+			return nil, err
+		}
+		d.sb.WriteString(" WHERE rowid IN (SELECT ")
+		if isJoin {
+			if err = d.quoteTableName(target); err != nil {
 				return nil, err
 			}
+			d.sb.WriteByte('.')
 		}
+		d.sb.WriteString("rowid FROM ")
+		if err = d.buildTable(d.table); err != nil {
+			return nil, err
+		}
+		if err = d.buildFilter(); err != nil {
+			return nil, err
+		}
+		d.sb.WriteByte(')')
+	default:
+		d.sb.WriteString("DELETE FROM ")
+		if err = d.buildTable(d.table); err != nil {
+			return nil, err
+		}
+		err = d.buildFilter()
+	}
+	if err != nil {
+		return nil, err
 	}
-	d.sb.WriteString(";")
+	if d.returning {
+		if err = d.buildReturning(); err != nil {
+			return nil, err
+		}
+	}
+	d.sb.WriteByte(';')
 	return &Query{
 		SQL:  d.sb.String(),
 		Args: d.args,
 	}, nil
 }
 
-func (d *Deleter[T]) buildExpression(e Expression) error {
-	if e == nil {
-		return nil
-	}
-	switch exp := e.(type) {
-	case Column:
-		d.sb.WriteByte('`')
-		d.sb.WriteString(exp.name)
-		d.sb.WriteByte('`')
-	case value:
-		d.sb.WriteByte('?')
-		d.args = append(d.args, exp.val)
-	case Predicate:
-		// 处理左侧表达式
-		if err := d.buildExpression(exp.left); err != nil {
+// buildFilter 构造 WHERE、ORDER BY 和 LIMIT 部分
+func (d *Deleter[T]) buildFilter() error {
+	if len(d.where) > 0 {
+		d.sb.WriteString(" WHERE ")
+		if err := d.buildPredicates(d.where); err != nil {
 			return err
 		}
-		// 处理操作符
-		d.sb.WriteString(" ")
-		d.sb.WriteString(string(exp.op))
-		d.sb.WriteString(" ")
-		// 处理右侧表达式
-		if err := d.buildExpression(exp.right); err != nil {
+	}
+	if len(d.orderBy) > 0 {
+		if err := d.buildOrderBy(d.orderBy); err != nil {
 			return err
 		}
-	default:
-		return fmt.Errorf("unsupported expression type: %T", e)
+	}
+	if d.limit > 0 {
+		d.sb.WriteString(" LIMIT ?")
+		d.addArgs(d.limit)
 	}
 	return nil
 }
 
-// From 方法用于设置要操作的表名或表引用
-func (d *Deleter[T]) From(tbl string) *Deleter[T] {
-	d.table = tbl
-	return d
+// buildReturning 构造 RETURNING 子句
+func (d *Deleter[T]) buildReturning() error {
+	d.sb.WriteString(" RETURNING ")
+	if len(d.returningCols) == 0 {
+		d.sb.WriteByte('*')
+		return nil
+	}
+	for i, c := range d.returningCols {
+		if i > 0 {
+			d.sb.WriteByte(',')
+		}
+		switch col := c.(type) {
+		case Column:
+			if err := d.buildColumn(col.table, col.name); err != nil {
+				return err
+			}
+			d.buildAs(col.alias)
+		case RawExpr:
+			d.raw(col)
+		default:
+			return errs.NewErrUnsupportedSelectable(c)
+		}
+	}
+	return nil
 }
 
-// Where 方法用于设置删除操作的条件
-func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
-	d.where = ps
-	return d
+// Exec 执行删除操作，和其它语句一样会经过中间件
+func (d *Deleter[T]) Exec(ctx context.Context) Result {
+	if sdb := d.sharding; sdb != nil {
+		return d.shardingExec(ctx, sdb)
+	}
+	if m := d.partitionModel(new(T), d.table); m != nil {
+		return d.partitionExec(ctx, m)
+	}
+	return exec(ctx, d.sess, d.core, newQueryContext[T](d.core, TypeDelete, d))
+}
+
+// GetMulti 执行删除操作，并且通过 RETURNING 读取被删除的行
+// 如果没有调用 Returning，那么返回所有列
+func (d *Deleter[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	if sdb := d.sharding; sdb != nil {
+		return d.shardingGetMulti(ctx, sdb)
+	}
+	if m := d.partitionModel(new(T), d.table); m != nil {
+		return d.partitionGetMulti(ctx, m)
+	}
+	d.returning = true
+	res := getMulti[T](ctx, d.core, d.sess, newQueryContext[T](d.core, TypeDelete, d))
+	if res.Result != nil {
+		return res.Result.([]*T), res.Err
+	}
+	return nil, res.Err
 }
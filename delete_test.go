@@ -1,13 +1,16 @@
 package sorm
 
 import (
-	"testing"
-
+	"context"
+	"database/sql"
 	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
 )
 
 func TestDeleter_Build(t *testing.T) {
 	db := MemoryDB(t)
+	sub := NewSelector[TestModel](db).AsSubquery("sub")
 	testCases := []struct {
 		name      string
 		builder   QueryBuilder
@@ -16,27 +19,76 @@ func TestDeleter_Build(t *testing.T) {
 	}{
 		{
 			name:    "no where",
-			builder: NewDeleter[TestModel](db).From("`test_model`"),
+			builder: NewDeleter[TestModel](db).AllowFullTable(),
 			wantQuery: &Query{
 				SQL: "DELETE FROM `test_model`;",
 			},
 		},
 		{
 			name:    "where",
-			builder: (&Deleter[TestModel]{}).Where(C("Id").EQ(16)),
+			builder: NewDeleter[TestModel](db).Where(C("Id").EQ(16)),
 			wantQuery: &Query{
-				SQL:  "DELETE FROM `TestModel` WHERE `Id` = ?;",
+				SQL:  "DELETE FROM `test_model` WHERE `id` = ?;",
 				Args: []any{16},
 			},
 		},
 		{
 			name:    "from",
-			builder: (&Deleter[TestModel]{}).From("`test_model`").Where(C("Id").EQ(16)),
+			builder: NewDeleter[TestModel](db).From(TableOf(&testArchiveModel{})).Where(C("Id").EQ(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_archive_model` WHERE `id` = ?;",
+				Args: []any{16},
+			},
+		},
+		{
+			name: "alias",
+			builder: NewDeleter[TestModel](db).From(TableOf(&TestModel{}).As("t")).
+				Where(TableOf(&TestModel{}).As("t").C("Id").EQ(16)),
 			wantQuery: &Query{
-				SQL:  "DELETE FROM `test_model` WHERE `Id` = ?;",
+				SQL:  "DELETE FROM `test_model` AS `t` WHERE `t`.`id` = ?;",
 				Args: []any{16},
 			},
 		},
+		{
+			name: "and or not",
+			builder: NewDeleter[TestModel](db).Where(C("Age").GT(18).
+				Or(Not(C("FirstName").EQ("Tom"))), C("Id").LT(100)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE ((`age` > ?) OR ( NOT (`first_name` = ?))) AND (`id` < ?);",
+				Args: []any{18, "Tom", 100},
+			},
+		},
+		{
+			name: "subquery",
+			builder: NewDeleter[TestModel](db).Where(C("Id").InQuery(NewSelector[testArchiveModel](db).
+				Select(C("Id")).AsSubquery("sub"))),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model` WHERE `id` IN (SELECT `id` FROM `test_archive_model`);",
+			},
+		},
+		{
+			name:    "order by and limit",
+			builder: NewDeleter[TestModel](db).Where(C("Age").GT(18)).OrderBy(Desc("Age"), C("Id").Asc()).Limit(10),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `age` > ? ORDER BY `age` DESC,`id` ASC LIMIT ?;",
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name:    "unknown field",
+			builder: NewDeleter[TestModel](db).Where(C("Invalid").EQ(1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "unsupported table",
+			builder: NewDeleter[TestModel](db).From(sub),
+			wantErr: errs.NewErrUnsupportedTableType(sub),
+		},
+		{
+			name:    "returning",
+			builder: NewDeleter[TestModel](db).Returning(),
+			wantErr: errs.ErrUnsupportedReturning,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -51,3 +103,155 @@ func TestDeleter_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleter_SQLite3_Build(t *testing.T) {
+	db := MemoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		builder   QueryBuilder
+		wantErr   error
+		wantQuery *Query
+	}{
+		{
+			// SQLite 默认不支持 DELETE ... LIMIT，改写成 rowid 子查询
+			name:    "order by and limit",
+			builder: NewDeleter[TestModel](db).Where(C("Age").GT(18)).OrderBy(Desc("Age")).Limit(10),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model` WHERE rowid IN (SELECT rowid FROM `test_model` " +
+					"WHERE `age` > ? ORDER BY `age` DESC LIMIT ?);",
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name:    "returning all",
+			builder: NewDeleter[TestModel](db).Where(C("Id").EQ(1)).Returning(),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `id` = ? RETURNING *;",
+				Args: []any{1},
+			},
+		},
+		{
+			name:    "returning columns",
+			builder: NewDeleter[TestModel](db).Where(C("Id").EQ(1)).Returning(C("Id"), C("FirstName")),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `id` = ? RETURNING `id`,`first_name`;",
+				Args: []any{1},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.builder.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestDeleter_Join_Build(t *testing.T) {
+	o := TableOf(&TestModel{}).As("o")
+	a := TableOf(&testArchiveModel{}).As("a")
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		builder   func(db *DB) QueryBuilder
+		wantErr   error
+		wantQuery *Query
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			builder: func(db *DB) QueryBuilder {
+				return NewDeleter[TestModel](db).From(o.Join(a).On(o.C("Id").EQ(a.C("Id")))).Where(a.C("Age").GT(18))
+			},
+			wantQuery: &Query{
+				SQL: "DELETE `o` FROM (`test_model` AS `o` JOIN `test_archive_model` AS `a` ON `o`.`id` = `a`.`id`) " +
+					"WHERE `a`.`age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name:    "mysql without alias",
+			dialect: MySQL,
+			builder: func(db *DB) QueryBuilder {
+				return NewDeleter[TestModel](db).From(TableOf(&TestModel{}).Join(TableOf(&testArchiveModel{})).Using("Id"))
+			},
+			wantQuery: &Query{
+				SQL: "DELETE `test_model` FROM (`test_model` JOIN `test_archive_model` USING (`id`));",
+			},
+		},
+		{
+			name:    "mysql limit",
+			dialect: MySQL,
+			builder: func(db *DB) QueryBuilder {
+				return NewDeleter[TestModel](db).From(o.Join(a).On(o.C("Id").EQ(a.C("Id")))).Limit(1)
+			},
+			wantErr: errs.ErrJoinDeleteWithLimit,
+		},
+		{
+			name:    "sqlite",
+			dialect: SQLite3,
+			builder: func(db *DB) QueryBuilder {
+				return NewDeleter[TestModel](db).From(o.LeftJoin(a).On(o.C("Id").EQ(a.C("Id")))).
+					Where(a.C("Age").LT(18)).Limit(10)
+			},
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model` AS `o` WHERE rowid IN (SELECT `o`.rowid FROM " +
+					"(`test_model` AS `o` LEFT JOIN `test_archive_model` AS `a` ON `o`.`id` = `a`.`id`) " +
+					"WHERE `a`.`age` < ? LIMIT ?);",
+				Args: []any{18, 10},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := MemoryDB(t, DBWithDialect(tc.dialect))
+			query, err := tc.builder(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestDeleter_Exec(t *testing.T) {
+	db := memoryDBWithDB("delete_exec", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES " +
+		"(1, 'Tom', 18, 'Cat'), (2, 'Jerry', 20, 'Mouse'), (3, 'Deng', 30, 'Ming'), (4, 'Ming', 40, 'Deng')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	// 删除年龄最大的一个
+	res := NewDeleter[TestModel](db).OrderBy(Desc("Age")).Limit(1).AllowFullTable().Exec(ctx)
+	assert.Nil(t, res.Err())
+	affected, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	deleted, err := NewDeleter[TestModel](db).Where(C("Age").LT(25)).OrderBy(Asc("Id")).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 1, FirstName: "Tom", Age: 18, LastName: &sql.NullString{String: "Cat", Valid: true}},
+		{Id: 2, FirstName: "Jerry", Age: 20, LastName: &sql.NullString{String: "Mouse", Valid: true}},
+	}, deleted)
+
+	left, err := NewSelector[TestModel](db).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 3, FirstName: "Deng", Age: 30, LastName: &sql.NullString{String: "Ming", Valid: true}},
+	}, left)
+}
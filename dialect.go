@@ -1,7 +1,11 @@
 package sorm
 
 import (
+	"context"
+	"fmt"
 	"github.com/xzhHas/sorm/internal/errs"
+	"regexp"
+	"strings"
 )
 
 var (
@@ -15,6 +19,40 @@ type Dialect interface {
 	quoter() byte
 	// buildUpsert 构造插入冲突部分
 	buildUpsert(b *builder, odk *Upsert) error
+	// buildConcat 构造字符串拼接
+	buildConcat(b *builder, e MathExpr) error
+	// buildBitXor 构造按位异或
+	buildBitXor(b *builder, e MathExpr) error
+	// paramLimit 单条语句最多能够使用的参数（占位符）数量
+	paramLimit() int
+	// supportsReturning 是否支持 RETURNING 语句，用于 Deleter.Returning 这种用户显式要求返回数据的场景
+	supportsReturning() bool
+	// insertReturning 插入单行的时候能不能通过 RETURNING 读取自增主键，
+	// 和 supportsReturning 不同，这里需要确认数据库的版本确实支持，必要的时候通过 sess 查询
+	insertReturning(ctx context.Context, sess Session) (bool, error)
+	// firstInsertId 根据一条语句插入了 rows 行之后的 LastInsertId，计算第一行的自增主键，
+	// 返回 false 代表数据库不保证同一条语句插入的行的主键是连续的，无法推算
+	firstInsertId(lastInsertId int64, rows int) (int64, bool)
+	// insertIgnore 返回忽略冲突的 INSERT 语句开头，例如 INSERT IGNORE INTO
+	insertIgnore() string
+	// buildInsertSelect 构造 INSERT ... SELECT 中的 SELECT 部分，q 是 SELECT 语句本身
+	buildInsertSelect(b *builder, q *Query, upsert bool)
+	// supportsLoadData 是否支持 LOAD DATA LOCAL INFILE 批量导入
+	supportsLoadData() bool
+	// supportsDeleteLimit 是否支持 DELETE ... ORDER BY ... LIMIT 语句
+	supportsDeleteLimit() bool
+	// placeholder 返回第 n 个参数的占位符，n 从 1 开始，MySQL 和 SQLite 都是 ?
+	placeholder(n int) string
+	// supportsJoinUpdate 是否支持 UPDATE ... JOIN 和 DELETE ... FROM ... JOIN，并且 SET 里面的列可以带上表名。
+	// 不支持的时候 UPDATE 改写成 UPDATE ... FROM，DELETE 改写成 rowid 子查询
+	supportsJoinUpdate() bool
+	// retryable 判断事务失败的原因是不是死锁、锁等待超时之类的临时错误，这种情况下重新执行整个事务可能会成功
+	retryable(err error) bool
+	// createTableLike 返回以 base 为模板创建 table 的语句，用于自动创建按照时间分表的表，
+	// 需要查询 base 的结构的时候通过 sess 查询
+	createTableLike(ctx context.Context, sess Session, table, base string) (string, error)
+	// backslashEscapes 字符串里面的反斜杠是不是转义字符，解析原生 SQL 的时候用于判断引号是否结束
+	backslashEscapes() bool
 }
 
 type standardSQL struct {
@@ -29,6 +67,69 @@ func (s *standardSQL) buildUpsert(b *builder, odk *Upsert) error {
 	panic("implement me")
 }
 
+func (s *standardSQL) supportsReturning() bool {
+	return false
+}
+
+func (s *standardSQL) insertReturning(ctx context.Context, sess Session) (bool, error) {
+	return false, nil
+}
+
+// firstInsertId MySQL 的 LastInsertId 是第一行的主键，但是其它行的主键不一定连续，
+// 例如 innodb_autoinc_lock_mode=2 的时候并发插入的语句会交错分配主键，
+// auto_increment_increment 大于 1 的时候主键之间也有间隔，所以只有插入一行的时候才能确定
+func (s *standardSQL) firstInsertId(lastInsertId int64, rows int) (int64, bool) {
+	return lastInsertId, rows == 1
+}
+
+func (s *standardSQL) supportsLoadData() bool {
+	return false
+}
+
+func (s *standardSQL) supportsDeleteLimit() bool {
+	return false
+}
+
+func (s *standardSQL) placeholder(n int) string {
+	return "?"
+}
+
+// backslashEscapes 标准 SQL 里面反斜杠是普通字符，引号通过两个连续的引号转义
+func (s *standardSQL) backslashEscapes() bool {
+	return false
+}
+
+func (s *standardSQL) supportsJoinUpdate() bool {
+	return false
+}
+
+func (s *standardSQL) retryable(err error) bool {
+	return false
+}
+
+func (s *standardSQL) createTableLike(ctx context.Context, sess Session, table, base string) (string, error) {
+	return "", errs.NewErrPartitionUnsupported("在当前数据库上自动创建分表")
+}
+
+// buildInsertSelect 直接把 SELECT 语句拼接在后面
+func (s *standardSQL) buildInsertSelect(b *builder, q *Query, upsert bool) {
+	b.sb.WriteByte(' ')
+	b.sb.WriteString(strings.TrimSuffix(q.SQL, ";"))
+	if len(q.Args) > 0 {
+		b.addArgs(q.Args...)
+	}
+}
+
+// buildConcat 使用标准 SQL 的 || 拼接字符串
+func (s *standardSQL) buildConcat(b *builder, e MathExpr) error {
+	return b.buildBinaryExpr(binaryExpr(e))
+}
+
+// buildBitXor 大多数数据库都使用 ^ 作为按位异或
+func (s *standardSQL) buildBitXor(b *builder, e MathExpr) error {
+	return b.buildBinaryExpr(binaryExpr(e))
+}
+
 type mysqlDialect struct {
 	standardSQL
 }
@@ -37,6 +138,51 @@ func (m *mysqlDialect) quoter() byte {
 	return '`'
 }
 
+// paramLimit MySQL 的预编译语句最多支持 65535 个占位符
+// 注意语句本身还受到 max_allowed_packet 的限制，必要的时候可以用 Inserter.BatchSize 调小
+func (m *mysqlDialect) paramLimit() int {
+	return 65535
+}
+
+// supportsLoadData MySQL 可以通过 LOAD DATA LOCAL INFILE 从客户端读取数据
+func (m *mysqlDialect) supportsLoadData() bool {
+	return true
+}
+
+// supportsDeleteLimit MySQL 的单表 DELETE 支持 ORDER BY 和 LIMIT
+func (m *mysqlDialect) supportsDeleteLimit() bool {
+	return true
+}
+
+// backslashEscapes MySQL 默认没有开启 NO_BACKSLASH_ESCAPES，单引号和双引号里面的反斜杠会转义下一个字符
+func (m *mysqlDialect) backslashEscapes() bool {
+	return true
+}
+
+// supportsJoinUpdate MySQL 支持多表 UPDATE 和 DELETE
+func (m *mysqlDialect) supportsJoinUpdate() bool {
+	return true
+}
+
+// mysqlRetryableErr 匹配死锁（1213）和锁等待超时（1205）
+// 驱动返回的错误信息形如 Error 1213 (40001): Deadlock found when trying to get lock，
+// 为了不依赖具体的驱动，这里直接匹配错误信息
+var mysqlRetryableErr = regexp.MustCompile(`Error (1213|1205)\b`)
+
+// retryable MySQL 的死锁和锁等待超时
+func (m *mysqlDialect) retryable(err error) bool {
+	return mysqlRetryableErr.MatchString(err.Error())
+}
+
+// createTableLike MySQL 的 CREATE TABLE ... LIKE 会复制列、索引和表选项
+func (m *mysqlDialect) createTableLike(ctx context.Context, sess Session, table, base string) (string, error) {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` LIKE `%s`;", table, base), nil
+}
+
+func (m *mysqlDialect) insertIgnore() string {
+	return "INSERT IGNORE INTO "
+}
+
 // buildUpsert 构建MySQL方言中的ON DUPLICATE KEY UPDATE部分
 // 该方法用于处理在UPSERT操作中，当记录重复时如何更新现有记录的逻辑
 // *builder类型，用于构造SQL语句的辅助对象， *Upsert类型，包含执行UPSERT操作所需的信息，特别是重复键更新的规则
@@ -72,6 +218,20 @@ func (m *mysqlDialect) buildUpsert(b *builder, odk *Upsert) error {
 	return nil
 }
 
+// buildConcat MySQL 默认情况下 || 是逻辑或，所以要用 CONCAT 函数
+func (m *mysqlDialect) buildConcat(b *builder, e MathExpr) error {
+	b.sb.WriteString("CONCAT(")
+	if err := b.buildSubExpr(e.left); err != nil {
+		return err
+	}
+	b.sb.WriteByte(',')
+	if err := b.buildSubExpr(e.right); err != nil {
+		return err
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
 type sqlite3Dialect struct {
 	standardSQL
 }
@@ -80,6 +240,106 @@ func (s *sqlite3Dialect) quoter() byte {
 	return '`'
 }
 
+// paramLimit SQLite 3.32 之前默认最多 999 个参数，之后是 32766 个
+// 这里我们按照保守的 999 来算，因为用户可能链接了系统里面比较老的 SQLite
+func (s *sqlite3Dialect) paramLimit() int {
+	return 999
+}
+
+// supportsReturning SQLite 从 3.35 开始支持 RETURNING
+// 这里只用于用户显式调用的 Deleter.Returning，插入的时候是否使用 RETURNING 由 insertReturning 根据版本决定
+func (s *sqlite3Dialect) supportsReturning() bool {
+	return true
+}
+
+// insertReturning 通过 sqlite_version() 确认 SQLite 的版本不低于 3.35
+// 和 paramLimit 一样，这里考虑到用户可能链接了系统里面比较老的 SQLite
+func (s *sqlite3Dialect) insertReturning(ctx context.Context, sess Session) (bool, error) {
+	rows, err := sess.QueryContext(ctx, "SELECT sqlite_version();")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var version string
+	if rows.Next() {
+		if err = rows.Scan(&version); err != nil {
+			return false, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+	var major, minor int
+	if _, err = fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return false, nil
+	}
+	return major > 3 || major == 3 && minor >= 35, nil
+}
+
+// firstInsertId SQLite 的 LastInsertId 是最后一行的 rowid，
+// 写操作是串行的，同一条语句插入的行的 rowid 都是当时最大的 rowid 加一，所以是连续递增的
+func (s *sqlite3Dialect) firstInsertId(lastInsertId int64, rows int) (int64, bool) {
+	return lastInsertId - int64(rows) + 1, true
+}
+
+// retryable SQLite 的 SQLITE_BUSY 和 SQLITE_LOCKED，错误信息分别是 database is locked 和 database table is locked
+func (s *sqlite3Dialect) retryable(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// sqliteCreateTable 匹配建表语句里面表名以及它前面的部分
+var sqliteCreateTable = regexp.MustCompile("(?is)^\\s*CREATE\\s+TABLE\\s+(IF\\s+NOT\\s+EXISTS\\s+)?(\"[^\"]*\"|`[^`]*`|\\[[^\\]]*\\]|[^\\s(]+)")
+
+// createTableLike SQLite 没有 CREATE TABLE ... LIKE，这里从 sqlite_master 读取模板表的建表语句，然后替换表名。
+// 注意模板表上的索引不会被复制
+func (s *sqlite3Dialect) createTableLike(ctx context.Context, sess Session, table, base string) (string, error) {
+	rows, err := sess.QueryContext(ctx, "SELECT `sql` FROM `sqlite_master` WHERE `type` = 'table' AND `name` = ?;", base)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return "", err
+		}
+		return "", errs.NewErrPartitionTemplate(base)
+	}
+	var ddl string
+	if err = rows.Scan(&ddl); err != nil {
+		return "", err
+	}
+	loc := sqliteCreateTable.FindStringIndex(ddl)
+	if loc == nil {
+		return "", errs.NewErrPartitionTemplate(base)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`%s;", table, ddl[loc[1]:]), nil
+}
+
+func (s *sqlite3Dialect) insertIgnore() string {
+	return "INSERT OR IGNORE INTO "
+}
+
+// buildInsertSelect SQLite 在解析 INSERT ... SELECT ... ON CONFLICT 的时候，
+// 可能会把 ON CONFLICT 里面的 ON 当成 JOIN 的 ON，所以有 UPSERT 的时候把 SELECT 包装一层，
+// 并且加上 WHERE true，参考 https://www.sqlite.org/lang_upsert.html 里面的 Parsing Ambiguity
+func (s *sqlite3Dialect) buildInsertSelect(b *builder, q *Query, upsert bool) {
+	if !upsert {
+		s.standardSQL.buildInsertSelect(b, q, upsert)
+		return
+	}
+	b.sb.WriteString(" SELECT * FROM (")
+	b.sb.WriteString(strings.TrimSuffix(q.SQL, ";"))
+	b.sb.WriteString(") WHERE true")
+	if len(q.Args) > 0 {
+		b.addArgs(q.Args...)
+	}
+}
+
 // buildUpsert 构建SQLite3方言中的ON CONFLICT DO UPDATE部分
 func (s *sqlite3Dialect) buildUpsert(b *builder,
 	odk *Upsert) error {
@@ -125,3 +385,18 @@ func (s *sqlite3Dialect) buildUpsert(b *builder,
 	}
 	return nil
 }
+
+// buildBitXor SQLite 没有按位异或的操作符，
+// 所以改写成 (a | b) - (a & b)，注意这会导致参数出现两次
+func (s *sqlite3Dialect) buildBitXor(b *builder, e MathExpr) error {
+	b.sb.WriteByte('(')
+	if err := b.buildBinaryExpr(binaryExpr{left: e.left, op: opBitOr, right: e.right}); err != nil {
+		return err
+	}
+	b.sb.WriteString(") - (")
+	if err := b.buildBinaryExpr(binaryExpr{left: e.left, op: opBitAnd, right: e.right}); err != nil {
+		return err
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
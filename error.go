@@ -6,4 +6,9 @@ import "github.com/xzhHas/sorm/internal/errs"
 var (
 	// ErrNoRows 代表没有找到数据
 	ErrNoRows = errs.ErrNoRows
+	// ErrMissingWhere 代表 UPDATE 或者 DELETE 没有 WHERE 条件，或者在严格模式下条件恒为真
+	// 使用 errors.Is 判断
+	ErrMissingWhere = errs.ErrMissingWhere
+	// ErrTxExists 代表使用 PropagationNever 的时候已经在事务中了
+	ErrTxExists = errs.ErrTxExists
 )
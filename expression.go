@@ -43,6 +43,47 @@ func Raw(expr string, args ...any) RawExpr {
 	}
 }
 
+// FuncExpr 代表一个 SQL 函数调用，例如 NOW()、COALESCE(`age`,?)
+// 函数名不会被 ORM 框架处理，直接按原样使用
+type FuncExpr struct {
+	name string
+	args []Expression
+}
+
+func (FuncExpr) expr() {}
+
+// Func 创建一个 FuncExpr 对象
+// args 可以是列、值或者其它表达式，不是 Expression 的会被当成值处理
+func Func(name string, args ...any) FuncExpr {
+	exprs := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		exprs = append(exprs, exprOf(arg))
+	}
+	return FuncExpr{
+		name: name,
+		args: exprs,
+	}
+}
+
+// Add 创建一个 MathExpr 对象，表示函数的结果加上一个值
+func (f FuncExpr) Add(val any) MathExpr {
+	return mathExprOf(f, opAdd, val)
+}
+
+// Sub 创建一个 MathExpr 对象，表示函数的结果减去一个值
+func (f FuncExpr) Sub(val any) MathExpr {
+	return mathExprOf(f, opSub, val)
+}
+
+// EQ 创建一个 Predicate 对象，表示函数的结果等于某个值
+func (f FuncExpr) EQ(arg any) Predicate {
+	return Predicate{
+		left:  f,
+		op:    opEQ,
+		right: exprOf(arg),
+	}
+}
+
 // binaryExpr 代表一个二元表达式
 // 包括两个操作数和一个操作符
 type binaryExpr struct {
@@ -54,23 +95,106 @@ type binaryExpr struct {
 func (binaryExpr) expr() {}
 
 // MathExpr 代表一个数学表达式，它是 binaryExpr 的别名
+// 包括算术运算、位运算和字符串拼接。left 为 nil 的时候代表一元运算，例如 Neg
 type MathExpr binaryExpr
 
-// Add 创建一个 MathExpr 对象，表示当前表达式加上一个值
-func (m MathExpr) Add(val interface{}) MathExpr {
+// mathExprOf 创建一个 MathExpr 对象
+// right 可以是列、值、函数或者其它表达式，不是 Expression 的会被当成值处理
+func mathExprOf(left Expression, o op, right any) MathExpr {
 	return MathExpr{
-		left:  m,
-		op:    opAdd,
-		right: valueOf(val),
+		left:  left,
+		op:    o,
+		right: exprOf(right),
 	}
 }
 
-// Multi 创建一个 MathExpr 对象，表示当前表达式乘以一个值
-func (m MathExpr) Multi(val interface{}) MathExpr {
+// Neg 创建一个 MathExpr 对象，表示对 val 取负，例如 Neg(C("Balance"))
+func Neg(val any) MathExpr {
 	return MathExpr{
+		op:    opSub,
+		right: exprOf(val),
+	}
+}
+
+// Add 创建一个 MathExpr 对象，表示当前表达式加上一个值
+func (m MathExpr) Add(val any) MathExpr {
+	return mathExprOf(m, opAdd, val)
+}
+
+// Sub 创建一个 MathExpr 对象，表示当前表达式减去一个值
+func (m MathExpr) Sub(val any) MathExpr {
+	return mathExprOf(m, opSub, val)
+}
+
+// Multi 创建一个 MathExpr 对象，表示当前表达式乘以一个值
+func (m MathExpr) Multi(val any) MathExpr {
+	return mathExprOf(m, opMulti, val)
+}
+
+// Div 创建一个 MathExpr 对象，表示当前表达式除以一个值
+func (m MathExpr) Div(val any) MathExpr {
+	return mathExprOf(m, opDiv, val)
+}
+
+// Mod 创建一个 MathExpr 对象，表示当前表达式对一个值取模
+func (m MathExpr) Mod(val any) MathExpr {
+	return mathExprOf(m, opMod, val)
+}
+
+// BitAnd 创建一个 MathExpr 对象，表示当前表达式和一个值按位与
+func (m MathExpr) BitAnd(val any) MathExpr {
+	return mathExprOf(m, opBitAnd, val)
+}
+
+// BitOr 创建一个 MathExpr 对象，表示当前表达式和一个值按位或
+func (m MathExpr) BitOr(val any) MathExpr {
+	return mathExprOf(m, opBitOr, val)
+}
+
+// BitXor 创建一个 MathExpr 对象，表示当前表达式和一个值按位异或
+func (m MathExpr) BitXor(val any) MathExpr {
+	return mathExprOf(m, opBitXor, val)
+}
+
+// LeftShift 创建一个 MathExpr 对象，表示当前表达式左移 n 位
+func (m MathExpr) LeftShift(n any) MathExpr {
+	return mathExprOf(m, opLeftShift, n)
+}
+
+// RightShift 创建一个 MathExpr 对象，表示当前表达式右移 n 位
+func (m MathExpr) RightShift(n any) MathExpr {
+	return mathExprOf(m, opRightShift, n)
+}
+
+// Concat 创建一个 MathExpr 对象，表示当前表达式和一个值进行字符串拼接
+func (m MathExpr) Concat(val any) MathExpr {
+	return mathExprOf(m, opConcat, val)
+}
+
+// EQ 创建一个 Predicate 对象，表示当前表达式等于某个值
+func (m MathExpr) EQ(arg any) Predicate {
+	return Predicate{
+		left:  m,
+		op:    opEQ,
+		right: exprOf(arg),
+	}
+}
+
+// LT 创建一个 Predicate 对象，表示当前表达式小于某个值
+func (m MathExpr) LT(arg any) Predicate {
+	return Predicate{
+		left:  m,
+		op:    opLT,
+		right: exprOf(arg),
+	}
+}
+
+// GT 创建一个 Predicate 对象，表示当前表达式大于某个值
+func (m MathExpr) GT(arg any) Predicate {
+	return Predicate{
 		left:  m,
-		op:    opMulti,
-		right: valueOf(val),
+		op:    opGT,
+		right: exprOf(arg),
 	}
 }
 
@@ -0,0 +1,178 @@
+package sorm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+)
+
+func TestMathExpr_Build(t *testing.T) {
+	db := MemoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "sub",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Sub(1))).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` - ? WHERE `id` = ?;",
+				Args: []any{1, 1},
+			},
+		},
+		{
+			// 之前 Multi 错误地使用了加法
+			name: "multi",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Multi(2))).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` * ? WHERE `id` = ?;",
+				Args: []any{2, 1},
+			},
+		},
+		{
+			// 操作数是另外一列
+			name: "column operand",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Add(C("Id")))).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` + `id` WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			// 嵌套的表达式需要加括号
+			name: "nested",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Add(1).Multi(C("Id").Mod(3)).Div(2))).
+				Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=((`age` + ?) * (`id` % ?)) / ? WHERE `id` = ?;",
+				Args: []any{1, 3, 2, 1},
+			},
+		},
+		{
+			name: "neg",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", Neg(C("Age")))).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=-`age` WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "neg expression",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Sub(Neg(C("Id").Add(1))))).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` - (-(`id` + ?)) WHERE `id` = ?;",
+				Args: []any{1, 1},
+			},
+		},
+		{
+			name: "aggregate operand",
+			q:    NewSelector[TestModel](db).Having(Max("Age").GT(C("Age").Add(Avg("Age")))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` HAVING MAX(`age`) > (`age` + AVG(`age`));",
+			},
+		},
+		{
+			name: "bit",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").BitAnd(4).GT(0), C("Age").BitOr(1).LeftShift(2).RightShift(1).EQ(C("Id"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`age` & ?) > ?) AND ((((`age` | ?) << ?) >> ?) = `id`);",
+				Args: []any{4, 0, 1, 2, 1},
+			},
+		},
+		{
+			name: "bit xor",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").BitXor(8))).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` ^ ? WHERE `id` = ?;",
+				Args: []any{8, 1},
+			},
+		},
+		{
+			name: "concat",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("FirstName", C("FirstName").Concat("-").Concat(C("LastName")))).
+				Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=CONCAT((CONCAT(`first_name`,?)),`last_name`) WHERE `id` = ?;",
+				Args: []any{"-", 1},
+			},
+		},
+		{
+			name: "func",
+			q: NewSelector[TestModel](db).
+				Where(Func("COALESCE", C("Age"), Func("LENGTH", C("FirstName")), 0).EQ(3)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE COALESCE(`age`,LENGTH(`first_name`),?) = ?;",
+				Args: []any{0, 3},
+			},
+		},
+		{
+			name: "invalid column",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Add(C("Invalid")))).Where(C("Id").EQ(1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestMathExpr_SQLite3_Build(t *testing.T) {
+	db := MemoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "bit xor",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").BitXor(8))).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=(`age` | ?) - (`age` & ?) WHERE `id` = ?;",
+				Args: []any{8, 8, 1},
+			},
+		},
+		{
+			name: "concat",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("FirstName", C("FirstName").Concat("-").Concat(C("LastName")))).
+				Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=(`first_name` || ?) || `last_name` WHERE `id` = ?;",
+				Args: []any{"-", 1},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package sorm
+
+import (
+	"github.com/xzhHas/sorm/internal/errs"
+	"reflect"
+	"strings"
+)
+
+// checkWhere 防止 UPDATE 和 DELETE 在没有条件的情况下修改整个表
+// 目标是每一层都带有连接条件（ON 或者 USING）的内连接的时候，连接条件本身就限定了要修改的行，所以允许没有 WHERE；
+// LEFT JOIN、RIGHT JOIN 以及没有连接条件的 JOIN 仍然会修改整个表，所以需要 WHERE。
+// 开启了 DBWithStrictWhere 的时候，恒为真的条件也会被拒绝，例如 Raw("1=1")
+func (b *builder) checkWhere(table TableReference, ps []Predicate) error {
+	if len(ps) == 0 {
+		if _, ok := table.(Join); ok && b.innerJoinRestricts(table) {
+			return nil
+		}
+		return errs.ErrMissingWhere
+	}
+	if b.strictWhere && alwaysTrue(ps...) {
+		return errs.ErrAlwaysTrueWhere
+	}
+	return nil
+}
+
+// innerJoinRestricts 判断 table 里面的每一个 JOIN 是不是都是带有连接条件的内连接，
+// 开启了 DBWithStrictWhere 的时候，恒为真的 ON 条件不算
+func (b *builder) innerJoinRestricts(table TableReference) bool {
+	j, ok := table.(Join)
+	if !ok {
+		return true
+	}
+	if j.typ != "JOIN" {
+		return false
+	}
+	if len(j.using) == 0 && (len(j.on) == 0 || b.strictWhere && alwaysTrue(j.on...)) {
+		return false
+	}
+	return b.innerJoinRestricts(j.left) && b.innerJoinRestricts(j.right)
+}
+
+// alwaysTrue 判断用 AND 连接起来的 ps 是否恒为真
+// 这只是一个保守的判断，只能识别常见的写法，例如 1=1、true、`id` = `id`
+func alwaysTrue(ps ...Predicate) bool {
+	for _, p := range ps {
+		if !exprAlwaysTrue(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func exprAlwaysTrue(e Expression) bool {
+	switch exp := e.(type) {
+	case Predicate:
+		switch exp.op {
+		case opAND:
+			return exprAlwaysTrue(exp.left) && exprAlwaysTrue(exp.right)
+		case opOR:
+			return exprAlwaysTrue(exp.left) || exprAlwaysTrue(exp.right)
+		case opEQ:
+			return sameOperand(exp.left, exp.right)
+		case "":
+			// Raw(...).AsPredicate()
+			return exp.right == nil && exprAlwaysTrue(exp.left)
+		}
+	case RawExpr:
+		return rawAlwaysTrue(exp.raw)
+	}
+	return false
+}
+
+// sameOperand 判断 = 两边是不是同一个列、同一个值或者同一个原生表达式
+func sameOperand(left, right Expression) bool {
+	switch l := left.(type) {
+	case Column:
+		r, ok := right.(Column)
+		return ok && l.name == r.name && l.table == r.table
+	case value:
+		r, ok := right.(value)
+		return ok && reflect.DeepEqual(l.val, r.val)
+	case RawExpr:
+		r, ok := right.(RawExpr)
+		return ok && len(l.args) == 0 && len(r.args) == 0 && normalizeRaw(l.raw) == normalizeRaw(r.raw)
+	}
+	return false
+}
+
+// rawAlwaysTrue 识别原生表达式里面恒为真的条件，例如 1=1、'a' = 'a'、TRUE，以及用 OR 和 AND 组合的情况
+func rawAlwaysTrue(raw string) bool {
+	lower := strings.ToLower(raw)
+	if parts := strings.Split(lower, " or "); len(parts) > 1 {
+		for _, part := range parts {
+			if rawAlwaysTrue(part) {
+				return true
+			}
+		}
+		return false
+	}
+	if parts := strings.Split(lower, " and "); len(parts) > 1 {
+		for _, part := range parts {
+			if !rawAlwaysTrue(part) {
+				return false
+			}
+		}
+		return true
+	}
+	s := normalizeRaw(lower)
+	if s == "true" || s == "1" {
+		return true
+	}
+	if strings.Count(s, "=") != 1 {
+		return false
+	}
+	left, right, _ := strings.Cut(s, "=")
+	if left == "" || strings.ContainsAny(left[len(left)-1:], "<>!") {
+		return false
+	}
+	return left == right
+}
+
+// normalizeRaw 去掉空白字符和最外层的括号，并且转为小写
+func normalizeRaw(raw string) string {
+	s := strings.ToLower(strings.Join(strings.Fields(raw), ""))
+	for len(s) > 1 && s[0] == '(' && s[len(s)-1] == ')' {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package sorm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+)
+
+func TestCheckWhere(t *testing.T) {
+	db := MemoryDB(t)
+	strictDB := MemoryDB(t, DBWithStrictWhere())
+	o := TableOf(&TestModel{}).As("o")
+	a := TableOf(&testArchiveModel{}).As("a")
+	testCases := []struct {
+		name    string
+		q       QueryBuilder
+		wantErr error
+	}{
+		{
+			name:    "update without where",
+			q:       NewUpdater[TestModel](db).Set(Assign("Age", 18)),
+			wantErr: errs.ErrMissingWhere,
+		},
+		{
+			name:    "delete without where",
+			q:       NewDeleter[TestModel](db),
+			wantErr: errs.ErrMissingWhere,
+		},
+		{
+			// LIMIT 不能代替 WHERE
+			name:    "delete with limit",
+			q:       NewDeleter[TestModel](db).Limit(1),
+			wantErr: errs.ErrMissingWhere,
+		},
+		{
+			name: "update allow full table",
+			q:    NewUpdater[TestModel](db).Set(Assign("Age", 18)).AllowFullTable(),
+		},
+		{
+			// 默认情况下只检查有没有 WHERE
+			name: "raw always true",
+			q:    NewDeleter[TestModel](db).Where(Raw("1=1").AsPredicate()),
+		},
+		{
+			// JOIN 的连接条件限定了修改的行
+			name: "join",
+			q:    NewDeleter[TestModel](db).From(o.Join(a).On(o.C("Id").EQ(a.C("Id")))),
+		},
+		{
+			// LEFT JOIN 会修改左边的每一行
+			name:    "left join",
+			q:       NewUpdater[TestModel](db).Table(o.LeftJoin(a).On(o.C("Id").EQ(a.C("Id")))).Set(Assign("Age", 18)),
+			wantErr: errs.ErrMissingWhere,
+		},
+		{
+			name:    "join without on",
+			q:       NewDeleter[TestModel](db).From(o.Join(a).On()),
+			wantErr: errs.ErrMissingWhere,
+		},
+		{
+			name:    "nested left join",
+			q:       NewDeleter[TestModel](db).From(o.Join(a).On(o.C("Id").EQ(a.C("Id"))).LeftJoin(TableOf(&TestModel{}).As("t")).On(o.C("Id").EQ(C("Id")))),
+			wantErr: errs.ErrMissingWhere,
+		},
+		{
+			name: "left join with where",
+			q:    NewDeleter[TestModel](db).From(o.LeftJoin(a).On(o.C("Id").EQ(a.C("Id")))).Where(a.C("Id").GT(0)),
+		},
+		{
+			name:    "strict join on always true",
+			q:       NewDeleter[TestModel](strictDB).From(o.Join(a).On(Raw("1=1").AsPredicate())),
+			wantErr: errs.ErrMissingWhere,
+		},
+		{
+			name:    "strict raw",
+			q:       NewDeleter[TestModel](strictDB).Where(Raw("1 = 1").AsPredicate()),
+			wantErr: errs.ErrAlwaysTrueWhere,
+		},
+		{
+			name:    "strict or",
+			q:       NewUpdater[TestModel](strictDB).Set(Assign("Age", 18)).Where(C("Id").EQ(1).Or(Raw("TRUE").AsPredicate())),
+			wantErr: errs.ErrAlwaysTrueWhere,
+		},
+		{
+			name:    "strict same column",
+			q:       NewUpdater[TestModel](strictDB).Set(Assign("Age", 18)).Where(C("Id").EQ(C("Id"))),
+			wantErr: errs.ErrAlwaysTrueWhere,
+		},
+		{
+			name:    "strict same value",
+			q:       NewDeleter[TestModel](strictDB).Where(Predicate{left: valueOf(1), op: opEQ, right: valueOf(1)}),
+			wantErr: errs.ErrAlwaysTrueWhere,
+		},
+		{
+			name: "strict normal",
+			q:    NewDeleter[TestModel](strictDB).Where(C("Id").EQ(1), Raw("1=1").AsPredicate()),
+		},
+		{
+			name: "strict allow full table",
+			q:    NewDeleter[TestModel](strictDB).Where(Raw("1=1").AsPredicate()).AllowFullTable(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, ErrMissingWhere))
+			}
+		})
+	}
+}
+
+func TestRawAlwaysTrue(t *testing.T) {
+	testCases := []struct {
+		raw  string
+		want bool
+	}{
+		{raw: "1=1", want: true},
+		{raw: " ( 1 = 1 ) ", want: true},
+		{raw: "'a'='a'", want: true},
+		{raw: "TRUE", want: true},
+		{raw: "1", want: true},
+		{raw: "`id` = ? OR 1=1", want: true},
+		{raw: "1=1 AND 2=2", want: true},
+		{raw: "1=1 AND `id` = ?", want: false},
+		{raw: "`id` = ?", want: false},
+		{raw: "1>=1", want: false},
+		{raw: "1=2", want: false},
+		{raw: "", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.raw, func(t *testing.T) {
+			assert.Equal(t, tc.want, rawAlwaysTrue(tc.raw))
+		})
+	}
+}
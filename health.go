@@ -0,0 +1,141 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/xzhHas/sorm/internal/errs"
+	"log"
+	"time"
+)
+
+// DBWithMaxOpenConns 设置主库连接池最多打开多少个连接，小于等于 0 的时候不限制
+// 这几个连接池的选项都只作用于主库，DBWithReplicas 的从库需要自己设置
+func DBWithMaxOpenConns(n int) DBOption {
+	return func(db *DB) {
+		db.pool = append(db.pool, func(sdb *sql.DB) {
+			sdb.SetMaxOpenConns(n)
+		})
+	}
+}
+
+// DBWithMaxIdleConns 设置主库连接池最多保留多少个空闲连接，小于等于 0 的时候不保留
+func DBWithMaxIdleConns(n int) DBOption {
+	return func(db *DB) {
+		db.pool = append(db.pool, func(sdb *sql.DB) {
+			sdb.SetMaxIdleConns(n)
+		})
+	}
+}
+
+// DBWithConnMaxLifetime 设置连接最多可以使用多久，到期之后不再复用，小于等于 0 的时候不限制
+func DBWithConnMaxLifetime(d time.Duration) DBOption {
+	return func(db *DB) {
+		db.pool = append(db.pool, func(sdb *sql.DB) {
+			sdb.SetConnMaxLifetime(d)
+		})
+	}
+}
+
+// DBWithConnMaxIdleTime 设置连接最多可以空闲多久，到期之后会被关闭，小于等于 0 的时候不限制
+func DBWithConnMaxIdleTime(d time.Duration) DBOption {
+	return func(db *DB) {
+		db.pool = append(db.pool, func(sdb *sql.DB) {
+			sdb.SetConnMaxIdleTime(d)
+		})
+	}
+}
+
+// Wait 会等待数据库连接，数据库还没有启动的时候每秒重试一次，没有超时时间
+// 注意只能用于测试
+//
+// Deprecated: 使用可以通过 ctx 控制超时时间的 WaitReady
+func (db *DB) Wait() error {
+	return db.waitReady(context.Background(), func(int) time.Duration {
+		return time.Second
+	}, func(err error) bool {
+		if !errors.Is(err, driver.ErrBadConn) {
+			return false
+		}
+		log.Printf("等待数据库启动...")
+		return true
+	})
+}
+
+// WaitReady 等待主库可用，例如服务启动的时候数据库还没有启动。
+// Ping 失败之后按照 backoff 等待再重试，直到成功或者 ctx 结束，
+// backoff 为 nil 的时候使用 ExponentialBackoff(100ms, 5s)。
+// ctx 结束的时候返回的错误里面带有 Ping 的次数和最后一次的错误
+func (db *DB) WaitReady(ctx context.Context, backoff Backoff) error {
+	if backoff == nil {
+		backoff = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+	}
+	return db.waitReady(ctx, backoff, func(err error) bool {
+		return true
+	})
+}
+
+// waitReady Ping 主库，遇到 retryable 的错误的时候按照 backoff 等待再重试
+func (db *DB) waitReady(ctx context.Context, backoff Backoff, retryable func(err error) bool) error {
+	for attempt := 1; ; attempt++ {
+		err := db.db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return errs.NewErrNotReady(attempt, err)
+		}
+		if !retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errs.NewErrNotReady(attempt, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// PoolHealth 一个连接池的检查结果
+type PoolHealth struct {
+	// Latency Ping 的耗时
+	Latency time.Duration
+	// Stats 连接池的统计信息，例如正在使用和空闲的连接数、等待连接的次数和时间
+	Stats sql.DBStats
+	// Err Ping 的错误，为 nil 代表可用
+	Err error
+}
+
+// Health DB.HealthCheck 的结果，嵌入的 PoolHealth 是主库的
+type Health struct {
+	PoolHealth
+	// Replicas 从库的检查结果，顺序和 DB.Replicas 一样
+	Replicas []PoolHealth
+}
+
+// HealthCheck 检查主库和从库，可以用于就绪探针，超时时间由 ctx 控制。
+// 返回的错误就是主库 Ping 的错误，这个时候 Health 仍然带有耗时和连接池的统计信息；
+// 从库不可用不会返回错误，但是和 CheckReplicas 一样会更新从库的状态
+func (db *DB) HealthCheck(ctx context.Context) (Health, error) {
+	res := Health{PoolHealth: checkPool(ctx, db.db)}
+	for _, r := range db.Replicas() {
+		h := checkPool(ctx, r.db)
+		r.setHealthy(h.Err == nil)
+		res.Replicas = append(res.Replicas, h)
+	}
+	return res, res.Err
+}
+
+// checkPool Ping 连接池并且记录耗时
+func checkPool(ctx context.Context, db *sql.DB) PoolHealth {
+	start := time.Now()
+	err := db.PingContext(ctx)
+	return PoolHealth{
+		Latency: time.Since(start),
+		Stats:   db.Stats(),
+		Err:     err,
+	}
+}
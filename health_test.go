@@ -0,0 +1,123 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+	"time"
+)
+
+func TestDB_PoolOptions(t *testing.T) {
+	db := MemoryDB(t, DBWithMaxOpenConns(3), DBWithMaxIdleConns(1),
+		DBWithConnMaxLifetime(time.Minute), DBWithConnMaxIdleTime(time.Second))
+	defer func() { _ = db.Close() }()
+	assert.Equal(t, 3, db.db.Stats().MaxOpenConnections)
+}
+
+func TestDB_WaitReady(t *testing.T) {
+	pingErr := errors.New("connection refused")
+	testCases := []struct {
+		name     string
+		mock     func(mock sqlmock.Sqlmock)
+		timeout  time.Duration
+		wantErr  error
+		wantWait []int
+	}{
+		{
+			name: "ready",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+			},
+		},
+		{
+			name: "retry",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing().WillReturnError(pingErr)
+				mock.ExpectPing().WillReturnError(pingErr)
+				mock.ExpectPing()
+			},
+			wantWait: []int{1, 2},
+		},
+		{
+			name: "timeout",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing().WillReturnError(pingErr)
+			},
+			timeout:  50 * time.Millisecond,
+			wantErr:  errs.NewErrNotReady(1, pingErr),
+			wantWait: []int{1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			db, err := OpenDB(mockDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = db.Close() }()
+			tc.mock(mock)
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			var waits []int
+			err = db.WaitReady(ctx, func(attempt int) time.Duration {
+				waits = append(waits, attempt)
+				if tc.timeout > 0 {
+					return time.Minute
+				}
+				return 0
+			})
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantWait, waits)
+			if err != nil {
+				assert.True(t, errors.Is(err, pingErr))
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDB_HealthCheck(t *testing.T) {
+	ctx := context.Background()
+	down, err := sql.Open("sqlite3", "file:health_down.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = down.Close()
+	up, err := sql.Open("sqlite3", "file:health_up.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := MemoryDB(t, DBWithMaxOpenConns(2), DBWithReplicas(nil, up, down))
+	defer func() { _ = db.Close() }()
+
+	h, err := db.HealthCheck(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, h.Err)
+	assert.True(t, h.Latency > 0)
+	assert.Equal(t, 2, h.Stats.MaxOpenConnections)
+	assert.Equal(t, 1, h.Stats.OpenConnections)
+	assert.Equal(t, 2, len(h.Replicas))
+	assert.NoError(t, h.Replicas[0].Err)
+	assert.Error(t, h.Replicas[1].Err)
+	// 从库的状态也会更新
+	assert.True(t, db.Replicas()[0].Healthy())
+	assert.False(t, db.Replicas()[1].Healthy())
+
+	_ = db.db.Close()
+	h, err = db.HealthCheck(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, err, h.Err)
+	assert.Equal(t, 2, h.Stats.MaxOpenConnections)
+}
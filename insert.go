@@ -2,8 +2,11 @@ package sorm
 
 import (
 	"context"
+	"database/sql"
 	"github.com/xzhHas/sorm/internal/errs"
 	"github.com/xzhHas/sorm/model"
+	"reflect"
+	"slices"
 )
 
 // UpsertBuilder (加载拦截器)定义了一个用于构建 upsert 操作的对象
@@ -45,12 +48,34 @@ type Inserter[T any] struct {
 	values  []*T     // values 存储了待插入的数据记录
 	columns []string // columns 存储了待插入数据的列名
 	upsert  *Upsert  // upsert 存储了 upsert 操作的详细信息
-	sess    session  // sess 是与数据库交互的会话对象
+	sess    Session  // sess 是与数据库交互的会话对象
+	// batchSize 分批插入的时候，每一批最多多少行，0 表示只受数据库参数数量的限制
+	batchSize int
+	// inTx 分批插入的时候，是否在同一个事务里面执行全部批次
+	inTx bool
+	// sel INSERT ... SELECT 的数据来源
+	sel insertSource
+	// ignore 是否忽略冲突的行
+	ignore bool
+	// omits 不插入的列，交给数据库使用默认值
+	omits []string
+	// sets 使用表达式而不是结构体的字段值来插入的列
+	sets []Assignment
+	// maps 以 map 形式给出的数据，key 是字段名或者列名
+	maps []map[string]any
+	// returning 是否通过 RETURNING 读取自增主键，由 exec 在确认数据库支持之后设置
+	returning bool
+}
+
+// insertSource INSERT ... SELECT 的数据来源，目前就是 Selector
+type insertSource interface {
+	QueryBuilder
+	selectedFields() ([]string, error)
 }
 
 // NewInserter 创建一个新的 Inserter 实例
-func NewInserter[T any](sess session) *Inserter[T] {
-	c := sess.getCore()
+func NewInserter[T any](sess Session) *Inserter[T] {
+	c := coreOf(sess)
 	return &Inserter[T]{
 		sess: sess,
 		builder: builder{
@@ -62,11 +87,53 @@ func NewInserter[T any](sess session) *Inserter[T] {
 }
 
 // Values 设置要插入的数据
+// 模型通过 auto_increment 标签或者 model.WithAutoIncrement 指定了自增列，并且所有行的自增列都是零值的时候，
+// 自增列交给数据库生成，执行之后回填到 vals 里面；数据库不保证多行的主键连续的时候，例如 MySQL，插入多行不会回填
 func (i *Inserter[T]) Values(vals ...*T) *Inserter[T] {
 	i.values = vals
 	return i
 }
 
+// Maps 设置要插入的数据，适用于列不固定，没有办法用结构体表达的场景
+// key 可以是字段名，也可以是列名，value 可以是普通的值，也可以是 Raw 之类的表达式。
+// 所有行的 key 必须一致，插入的列按照字段在模型里面定义的顺序排列。
+// 因为没有结构体，所以不会回填自增主键。不能和 Values、FromSelect、Columns 一起使用
+func (i *Inserter[T]) Maps(rows ...map[string]any) *Inserter[T] {
+	i.maps = rows
+	return i
+}
+
+// BatchSize 指定分批插入的时候，每一批最多插入多少行
+// 在大批量导入的时候，可以用它来控制单条语句的大小，例如避免超过 MySQL 的 max_allowed_packet
+// 不管设置多大，每一批的参数数量都不会超过数据库方言的限制
+func (i *Inserter[T]) BatchSize(n int) *Inserter[T] {
+	i.batchSize = n
+	return i
+}
+
+// InTx 指定分批插入的时候，全部批次在同一个事务里面执行
+// 任何一批失败都会导致整个事务回滚。如果 Inserter 本身就是在事务里面创建的，那么直接复用该事务
+func (i *Inserter[T]) InTx() *Inserter[T] {
+	i.inTx = true
+	return i
+}
+
+// FromSelect 插入 sel 查询出来的数据，也就是 INSERT INTO ... SELECT ...
+// 数据完全在数据库里面复制，常用于归档之类的场景。
+// SELECT 的列数必须和插入的列（参考 Columns）数量一致，并且按照位置一一对应。
+// 不能和 Values 一起使用
+func (i *Inserter[T]) FromSelect(sel insertSource) *Inserter[T] {
+	i.sel = sel
+	return i
+}
+
+// Ignore 忽略冲突的行，也就是 MySQL 的 INSERT IGNORE 和 SQLite 的 INSERT OR IGNORE
+// 因为无法知道哪些行被忽略了，所以不会回填自增主键
+func (i *Inserter[T]) Ignore() *Inserter[T] {
+	i.ignore = true
+	return i
+}
+
 // OnDuplicateKey 处理主键冲突的情况（即当发生主键冲突时的行为）
 func (i *Inserter[T]) OnDuplicateKey() *UpsertBuilder[T] {
 	return &UpsertBuilder[T]{
@@ -74,50 +141,187 @@ func (i *Inserter[T]) OnDuplicateKey() *UpsertBuilder[T] {
 	}
 }
 
-// Fields 指定要插入的列
-// TODO 目前我们只支持指定具体的列，但是不支持复杂的表达式
-// 例如不支持 VALUES(..., now(), now()) 这种在 MySQL 里面常用的
+// Columns 指定要插入的列
+// 需要插入表达式的时候，例如 VALUES(..., NOW())，使用 Set
 func (i *Inserter[T]) Columns(cols ...string) *Inserter[T] {
 	i.columns = cols
 	return i
 }
 
+// Omit 指定不插入的列，这些列会使用数据库的默认值
+// 省略了自增主键的时候，依旧会回填数据库生成的主键
+func (i *Inserter[T]) Omit(fields ...string) *Inserter[T] {
+	i.omits = fields
+	return i
+}
+
+// Set 指定 field 使用 val 插入，而不是结构体里面的字段值，所有行都一样
+// val 可以是 Raw("NOW()")、Func("UNIX_TIMESTAMP") 之类的表达式，也可以是普通的值。
+// 如果 field 不在 Columns 里面，那么会追加到最后。不能和 FromSelect 一起使用
+func (i *Inserter[T]) Set(field string, val any) *Inserter[T] {
+	i.sets = append(i.sets, Assign(field, val))
+	return i
+}
+
 // Build 构建 SQL 插入语句
 func (i *Inserter[T]) Build() (*Query, error) {
-	if len(i.values) == 0 {
+	if i.sel != nil && len(i.values)+len(i.maps) > 0 || len(i.values) > 0 && len(i.maps) > 0 {
+		return nil, errs.ErrInsertMixedSource
+	}
+	if i.sel != nil && len(i.sets) > 0 {
+		return nil, errs.ErrInsertSetWithSelect
+	}
+	if len(i.maps) > 0 && len(i.columns) > 0 {
+		return nil, errs.ErrInsertMapsWithColumns
+	}
+	if i.sel == nil && len(i.values) == 0 && len(i.maps) == 0 {
 		return nil, errs.ErrInsertZeroRow
 	}
-	m, err := i.r.Get(i.values[0])
-	i.model = m
+	var err error
+	i.model, err = i.r.Get(new(T))
 	if err != nil {
 		return nil, err
 	}
-	i.sb.WriteString("INSERT INTO ")
-	i.quote(m.TableName)
+	m := i.model
+	if i.ignore {
+		i.sb.WriteString(i.dialect.insertIgnore())
+	} else {
+		i.sb.WriteString("INSERT INTO ")
+	}
+	i.quote(i.modelTable(m))
 	i.sb.WriteString("(")
 
+	fields, autoInc, err := i.insertFields(m)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, fd := range fields {
+		if idx > 0 {
+			i.sb.WriteByte(',')
+		}
+		i.quote(fd.ColName)
+	}
+	i.sb.WriteByte(')')
+
+	switch {
+	case i.sel != nil:
+		err = i.buildSelect(fields)
+	case len(i.maps) > 0:
+		err = i.buildMaps(fields)
+	default:
+		err = i.buildValues(m, fields)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if i.upsert != nil {
+		err = i.core.dialect.buildUpsert(&i.builder, i.upsert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if autoInc != nil && !i.ignore && i.returning {
+		i.sb.WriteString(" RETURNING ")
+		i.quote(autoInc.ColName)
+	}
+
+	i.sb.WriteString(";")
+	return &Query{
+		SQL:  i.sb.String(),
+		Args: i.args,
+	}, nil
+}
+
+// insertFields 计算真正插入的列，以及需要由数据库生成并且回填的自增主键
+// 先是 Columns 指定的列，没有指定就是全部列，使用 Maps 的时候则是 map 的 key，
+// 然后去掉 Omit 的列、由数据库生成的自增主键，以及标记了 default 并且所有行都是零值的列，
+// 最后追加 Set 里面还没有包含的列
+func (i *Inserter[T]) insertFields(m *model.Model) ([]*model.Field, *model.Field, error) {
 	fields := m.Fields
-	if len(i.columns) != 0 {
+	if len(i.maps) > 0 {
+		var err error
+		fields, err = i.mapFields(i.maps[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		for idx := 1; idx < len(i.maps); idx++ {
+			fds, err := i.mapFields(i.maps[idx])
+			if err != nil {
+				return nil, nil, err
+			}
+			if !slices.Equal(fields, fds) {
+				return nil, nil, errs.NewErrInconsistentMapKeys(idx)
+			}
+		}
+	} else if len(i.columns) != 0 {
 		fields = make([]*model.Field, 0, len(i.columns))
 		for _, c := range i.columns {
 			field, ok := m.FieldMap[c]
 			if !ok {
-				return nil, errs.NewErrUnknownField(c)
+				return nil, nil, errs.NewErrUnknownField(c)
 			}
 			fields = append(fields, field)
 		}
 	}
+	for _, fd := range i.omits {
+		if _, ok := m.FieldMap[fd]; !ok {
+			return nil, nil, errs.NewErrUnknownField(fd)
+		}
+	}
+	sets := make(map[string]bool, len(i.sets))
+	for _, a := range i.sets {
+		if _, ok := m.FieldMap[a.column]; !ok {
+			return nil, nil, errs.NewErrUnknownField(a.column)
+		}
+		sets[a.column] = true
+	}
 
-	// (len(i.values) + 1) 中 +1 是考虑到 UPSERT 语句会传递额外的参数
-	i.args = make([]any, 0, len(fields)*(len(i.values)+1))
-	for idx, fd := range fields {
-		if idx > 0 {
-			i.sb.WriteByte(',')
+	// 自增主键交给数据库生成
+	var autoInc *model.Field
+	if len(i.values) > 0 {
+		var err error
+		autoInc, err = i.autoIncrementField(m)
+		if err != nil {
+			return nil, nil, err
 		}
-		i.quote(fd.ColName)
 	}
+	res := make([]*model.Field, 0, len(fields)+len(i.sets))
+	for _, fd := range fields {
+		if sets[fd.GoName] {
+			res = append(res, fd)
+			continue
+		}
+		if fd == autoInc || slices.Contains(i.omits, fd.GoName) {
+			continue
+		}
+		if fd.Default && len(i.values) > 0 {
+			zero, err := i.zeroInAllRows(m, fd)
+			if err != nil {
+				return nil, nil, err
+			}
+			if zero {
+				continue
+			}
+		}
+		res = append(res, fd)
+	}
+	for _, a := range i.sets {
+		fd := m.FieldMap[a.column]
+		if !slices.Contains(res, fd) {
+			res = append(res, fd)
+		}
+	}
+	return res, autoInc, nil
+}
 
-	i.sb.WriteString(") VALUES")
+// buildValues 构造 VALUES 部分
+func (i *Inserter[T]) buildValues(m *model.Model, fields []*model.Field) error {
+	// (len(i.values) + 1) 中 +1 是考虑到 UPSERT 语句会传递额外的参数
+	i.args = make([]any, 0, len(fields)*(len(i.values)+1))
+	i.sb.WriteString(" VALUES")
 	for vIdx, val := range i.values {
 		if vIdx > 0 {
 			i.sb.WriteByte(',')
@@ -128,34 +332,364 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			if fIdx > 0 {
 				i.sb.WriteByte(',')
 			}
+			if idx := slices.IndexFunc(i.sets, func(a Assignment) bool {
+				return a.column == field.GoName
+			}); idx >= 0 {
+				if err := i.buildExpression(i.sets[idx].val); err != nil {
+					return err
+				}
+				continue
+			}
 			i.sb.WriteByte('?')
 			fdVal, err := refVal.Field(field.GoName)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			i.addArgs(fdVal)
 		}
 		i.sb.WriteByte(')')
 	}
+	return nil
+}
 
-	if i.upsert != nil {
-		err = i.core.dialect.buildUpsert(&i.builder, i.upsert)
-		if err != nil {
-			return nil, err
+// buildMaps 使用 maps 构造 VALUES 部分
+func (i *Inserter[T]) buildMaps(fields []*model.Field) error {
+	i.args = make([]any, 0, len(fields)*(len(i.maps)+1))
+	i.sb.WriteString(" VALUES")
+	for rIdx, row := range i.maps {
+		if rIdx > 0 {
+			i.sb.WriteByte(',')
+		}
+		i.sb.WriteByte('(')
+		for fIdx, field := range fields {
+			if fIdx > 0 {
+				i.sb.WriteByte(',')
+			}
+			val, ok := row[field.GoName]
+			if !ok {
+				val = row[field.ColName]
+			}
+			if idx := slices.IndexFunc(i.sets, func(a Assignment) bool {
+				return a.column == field.GoName
+			}); idx >= 0 {
+				val = i.sets[idx].val
+			}
+			if err := i.buildExpression(exprOf(val)); err != nil {
+				return err
+			}
 		}
+		i.sb.WriteByte(')')
 	}
+	return nil
+}
 
-	i.sb.WriteString(";")
-	return &Query{
-		SQL:  i.sb.String(),
-		Args: i.args,
-	}, nil
+// buildSelect 构造 INSERT ... SELECT 的 SELECT 部分
+// SELECT 的列数必须和 fields 一致。对于能够确定名字的列，如果名字和对应位置的字段不一致，
+// 却和其它位置的字段一致，那么多半是顺序写错了，返回错误；
+// 名字和所有字段都对不上的，认为用户是有意为之，例如从别的表复制数据
+func (i *Inserter[T]) buildSelect(fields []*model.Field) error {
+	names, err := i.sel.selectedFields()
+	if err != nil {
+		return err
+	}
+	if names != nil {
+		if len(names) != len(fields) {
+			return errs.NewErrInsertSelectColumnCount(len(fields), len(names))
+		}
+		for idx, name := range names {
+			if name == "" || name == fields[idx].GoName || name == fields[idx].ColName {
+				continue
+			}
+			if slices.ContainsFunc(fields, func(fd *model.Field) bool {
+				return name == fd.GoName || name == fd.ColName
+			}) {
+				return errs.NewErrInsertSelectColumnOrder(idx, fields[idx].GoName, name)
+			}
+		}
+	}
+	q, err := i.sel.Build()
+	if err != nil {
+		return err
+	}
+	i.dialect.buildInsertSelect(&i.builder, q, i.upsert != nil)
+	return nil
 }
 
 // Exec 执行插入操作
+// 如果插入的行数超过了一条语句所能容纳的数量（参考 BatchSize 和数据库方言的参数限制），
+// 那么会自动拆分成多条语句执行，并且汇总结果。
+// 默认情况下，某一批失败并不会影响其它批次，失败的批次可以通过 Result.ChunkErrors 获得；
+// 如果调用了 InTx，那么全部批次在同一个事务里面执行
 func (i *Inserter[T]) Exec(ctx context.Context) Result {
-	return exec(ctx, i.sess, i.core, &QueryContext{
-		Builder: i,
-		Type:    "INSERT",
+	if sdb := i.sharding; sdb != nil {
+		return i.shardingExec(ctx, sdb)
+	}
+	if m := i.partitionModel(new(T), nil); m != nil {
+		return i.partitionExec(ctx, m)
+	}
+	chunks, err := i.chunks()
+	if err != nil {
+		return Result{err: err}
+	}
+	if len(chunks) <= 1 {
+		return i.exec(ctx, i.sess)
+	}
+	fns := make([]execFunc, 0, len(chunks))
+	for _, chunk := range chunks {
+		fns = append(fns, chunk.exec)
+	}
+	return execChunks(ctx, i.sess, fns, i.inTx)
+}
+
+// exec 在 sess 上执行插入语句，并且把数据库生成的自增主键回填到 values 里面
+// 只插入一行并且数据库支持 RETURNING 的时候直接读取返回的主键，
+// 插入多行的时候不使用 RETURNING，因为 SQLite 返回的行的顺序是不确定的，无法和 values 对应起来；
+// 否则利用 LastInsertId 推算，这要求同一条语句插入的行的主键是连续的，参考 Dialect.firstInsertId，
+// 数据库不保证的时候，例如 MySQL 插入多行，不会回填，主键保持零值。
+// 存在 UPSERT 的时候，有些行可能是更新而不是插入，所以无法推算，此时也不会回填
+func (i *Inserter[T]) exec(ctx context.Context, sess Session) Result {
+	qc := newQueryContext[T](i.core, TypeInsert, i)
+	if len(i.values) == 0 {
+		return exec(ctx, sess, i.core, qc)
+	}
+	m, err := i.r.Get(i.values[0])
+	if err != nil {
+		return Result{err: err}
+	}
+	fd, err := i.autoIncrementField(m)
+	if err != nil {
+		return Result{err: err}
+	}
+	if fd == nil || i.ignore {
+		return exec(ctx, sess, i.core, qc)
+	}
+	if len(i.values) == 1 {
+		returning, err := i.core.insertReturning(ctx, sess)
+		if err != nil {
+			return Result{err: err}
+		}
+		if returning {
+			i.returning = true
+			return i.execReturning(ctx, sess, qc, m, fd)
+		}
+	}
+	res := exec(ctx, sess, i.core, qc)
+	if res.err != nil || i.upsert != nil {
+		return res
+	}
+	last, err := res.LastInsertId()
+	if err != nil {
+		res.err = err
+		return res
+	}
+	first, ok := i.dialect.firstInsertId(last, len(i.values))
+	if !ok {
+		return res
+	}
+	for idx, val := range i.values {
+		if err = i.valCreator(val, m).SetField(fd.GoName, first+int64(idx)); err != nil {
+			res.err = err
+			return res
+		}
+	}
+	return res
+}
+
+// execReturning 执行只插入一行的 INSERT ... RETURNING 语句，并且回填自增主键
+func (i *Inserter[T]) execReturning(ctx context.Context, sess Session, qc *QueryContext,
+	m *model.Model, fd *model.Field) Result {
+	qr := handle(ctx, i.core, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Builder.Build()
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		rows, err := sess.QueryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		var res returningResult
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				return &QueryResult{Err: err}
+			}
+			if res.rowsAffected < int64(len(i.values)) {
+				err = i.valCreator(i.values[res.rowsAffected], m).SetField(fd.GoName, id)
+				if err != nil {
+					return &QueryResult{Err: err}
+				}
+			}
+			res.rowsAffected++
+			res.lastInsertId = id
+		}
+		if err = rows.Err(); err != nil {
+			return &QueryResult{Err: err}
+		}
+		return &QueryResult{Result: res}
 	})
+	var res sql.Result
+	if qr.Result != nil {
+		res = qr.Result.(sql.Result)
+	}
+	return Result{err: qr.Err, res: res}
+}
+
+// autoIncrementField 返回需要由数据库生成，并且在插入之后回填的自增主键
+// 如果用户通过 Columns 或者 Omit 排除了自增列，或者所有行的自增列都是零值，那么就由数据库生成；
+// 否则用户自己指定了主键，或者通过 Set 指定了表达式，返回 nil
+func (i *Inserter[T]) autoIncrementField(m *model.Model) (*model.Field, error) {
+	fd := m.AutoIncrementField()
+	if fd == nil {
+		return nil, nil
+	}
+	if slices.ContainsFunc(i.sets, func(a Assignment) bool {
+		return a.column == fd.GoName
+	}) {
+		return nil, nil
+	}
+	if slices.Contains(i.omits, fd.GoName) ||
+		len(i.columns) > 0 && !slices.Contains(i.columns, fd.GoName) {
+		return fd, nil
+	}
+	zero, err := i.zeroInAllRows(m, fd)
+	if err != nil || !zero {
+		return nil, err
+	}
+	return fd, nil
+}
+
+// zeroInAllRows 判断 fd 在所有行里面是不是都是零值
+func (i *Inserter[T]) zeroInAllRows(m *model.Model, fd *model.Field) (bool, error) {
+	for _, val := range i.values {
+		fdVal, err := i.valCreator(val, m).Field(fd.GoName)
+		if err != nil {
+			return false, err
+		}
+		if !reflect.ValueOf(fdVal).IsZero() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// chunks 按照每一批最多能够插入的行数，把 values 或者 maps 拆分成多个 Inserter
+// 不需要拆分的时候返回 nil
+func (i *Inserter[T]) chunks() ([]*Inserter[T], error) {
+	rows := len(i.values) + len(i.maps)
+	if rows <= 1 {
+		return nil, nil
+	}
+	m, err := i.r.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	// cols 是每一行的参数数量
+	cols := len(m.Fields)
+	if len(i.columns) > 0 {
+		cols = len(i.columns)
+	}
+	if len(i.maps) > 0 {
+		cols = 0
+		for _, row := range i.maps {
+			n := 0
+			for _, val := range row {
+				n += argsOf(val)
+			}
+			cols = max(cols, n)
+		}
+	}
+	// Set 的表达式每一行都会出现一次，UPSERT 部分整条语句只出现一次
+	for _, a := range i.sets {
+		cols += exprArgs(a.val)
+	}
+	limit := i.dialect.paramLimit()
+	if i.upsert != nil {
+		for _, a := range i.upsert.assigns {
+			if assign, ok := a.(Assignment); ok {
+				limit -= exprArgs(assign.val)
+			}
+		}
+	}
+	size := limit
+	if cols > 0 {
+		size = limit / cols
+	}
+	if i.batchSize > 0 && i.batchSize < size {
+		size = i.batchSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	if rows <= size {
+		return nil, nil
+	}
+	res := make([]*Inserter[T], 0, (rows+size-1)/size)
+	for start := 0; start < rows; start += size {
+		end := start + size
+		if end > rows {
+			end = rows
+		}
+		res = append(res, i.withRows(start, end))
+	}
+	return res, nil
+}
+
+// argsOf 返回 Maps 里面的一个值在语句里面占用的参数数量，不是表达式的值占用一个参数
+func argsOf(val any) int {
+	if e, ok := val.(Expression); ok {
+		return exprArgs(e)
+	}
+	return 1
+}
+
+// exprArgs 返回表达式构造出来之后的参数数量，例如 Raw("? + ?", 1, 2) 有两个参数
+// 子查询的参数要构造之后才知道，这里不计算
+func exprArgs(e Expression) int {
+	switch exp := e.(type) {
+	case value:
+		return 1
+	case RawExpr:
+		return len(exp.args)
+	case FuncExpr:
+		n := 0
+		for _, arg := range exp.args {
+			n += exprArgs(arg)
+		}
+		return n
+	case MathExpr:
+		return exprArgs(binaryExpr(exp))
+	case Predicate:
+		return exprArgs(binaryExpr(exp))
+	case binaryExpr:
+		if val, ok := exp.right.(value); ok && exp.op == opIN {
+			if vals, ok := val.val.([]any); ok {
+				return exprArgs(exp.left) + len(vals)
+			}
+		}
+		return exprArgs(exp.left) + exprArgs(exp.right)
+	default:
+		return 0
+	}
+}
+
+// withRows 复制一个 Inserter，但是只插入 [start, end) 之间的行
+func (i *Inserter[T]) withRows(start, end int) *Inserter[T] {
+	res := *i
+	// 不能复用已经写入过数据的 builder
+	res.builder = builder{
+		core:      i.core,
+		dialect:   i.dialect,
+		quoter:    i.quoter,
+		tableName: i.tableName,
+	}
+	if len(i.values) > 0 {
+		res.values = i.values[start:end]
+	}
+	if len(i.maps) > 0 {
+		res.maps = i.maps[start:end]
+	}
+	return &res
 }
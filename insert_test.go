@@ -1,7 +1,10 @@
 package sorm
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/xzhHas/sorm/internal/errs"
 	"testing"
@@ -237,3 +240,801 @@ func TestUpsert_SQLite3_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestInserter_Exec_Chunk(t *testing.T) {
+	newValues := func(n int) []*TestModel {
+		res := make([]*TestModel, 0, n)
+		for i := 1; i <= n; i++ {
+			res = append(res, &TestModel{Id: int64(i), FirstName: "Deng", Age: 18})
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name string
+		// 构造 Inserter，并且设置 mock 的预期
+		exec         func(db *DB, mock sqlmock.Sqlmock) Result
+		wantAffected int64
+		wantErr      error
+		wantChunkErr []int
+	}{
+		{
+			name: "batch size",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				mock.ExpectExec("INSERT INTO `test_model`\\(`id`,`first_name`,`age`,`last_name`\\) VALUES\\(\\?,\\?,\\?,\\?\\),\\(\\?,\\?,\\?,\\?\\);").
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectExec("INSERT INTO `test_model`\\(`id`,`first_name`,`age`,`last_name`\\) VALUES\\(\\?,\\?,\\?,\\?\\);").
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				return NewInserter[TestModel](db).Values(newValues(3)...).BatchSize(2).Exec(context.Background())
+			},
+			wantAffected: 3,
+		},
+		{
+			// SQLite 最多 999 个参数，每行 4 个参数，所以每一批 249 行
+			name: "dialect limit",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				db.dialect = SQLite3
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(249, 249))
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(250, 1))
+				return NewInserter[TestModel](db).Values(newValues(250)...).Exec(context.Background())
+			},
+			wantAffected: 250,
+		},
+		{
+			// Set 的表达式每行带两个参数，每行一共 5 个参数，所以每一批 199 行
+			name: "raw args",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				db.dialect = SQLite3
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(199, 199))
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(200, 1))
+				return NewInserter[TestModel](db).Values(newValues(200)...).Columns("Id", "FirstName", "LastName").
+					Set("Age", Raw("? + ?", 1, 2)).Exec(context.Background())
+			},
+			wantAffected: 200,
+		},
+		{
+			// UPSERT 部分的表达式带 4 个参数，剩下 995 个参数，所以每一批 248 行
+			name: "upsert raw args",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				db.dialect = SQLite3
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(248, 248))
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(249, 1))
+				return NewInserter[TestModel](db).Values(newValues(249)...).OnDuplicateKey().ConflictColumns("Id").
+					Update(Assign("Age", Raw("? + ? + ? + ?", 1, 2, 3, 4))).Exec(context.Background())
+			},
+			wantAffected: 249,
+		},
+		{
+			// 一批失败不影响其它批次
+			name: "chunk error",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				mock.ExpectExec("INSERT INTO .*").WillReturnError(errors.New("mock error"))
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(3, 1))
+				return NewInserter[TestModel](db).Values(newValues(3)...).BatchSize(2).Exec(context.Background())
+			},
+			// 出错的时候 RowsAffected 返回错误，执行成功的批次只能通过 ChunkErrors 推断
+			wantErr:      errors.Join(&ChunkError{Index: 0, Err: errors.New("mock error")}),
+			wantChunkErr: []int{0},
+		},
+		{
+			name: "in tx",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+				return NewInserter[TestModel](db).Values(newValues(3)...).BatchSize(2).InTx().Exec(context.Background())
+			},
+			wantAffected: 3,
+		},
+		{
+			// 事务里面遇到错误就停下来，并且回滚
+			name: "in tx rollback",
+			exec: func(db *DB, mock sqlmock.Sqlmock) Result {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectExec("INSERT INTO .*").WillReturnError(errors.New("mock error"))
+				mock.ExpectRollback()
+				return NewInserter[TestModel](db).Values(newValues(5)...).BatchSize(2).InTx().Exec(context.Background())
+			},
+			wantErr:      errors.Join(&ChunkError{Index: 1, Err: errors.New("mock error")}),
+			wantChunkErr: []int{1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := tc.exec(db, mock)
+			assert.Equal(t, tc.wantErr, res.Err())
+			affected, err := res.RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantAffected, affected)
+			var chunkErrs []int
+			for _, e := range res.ChunkErrors() {
+				chunkErrs = append(chunkErrs, e.Index)
+			}
+			assert.Equal(t, tc.wantChunkErr, chunkErrs)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestInserter_AutoIncrement_Build(t *testing.T) {
+	testCases := []struct {
+		name      string
+		q         func(db *DB) QueryBuilder
+		dialect   Dialect
+		wantQuery *Query
+	}{
+		{
+			// 主键是零值，交给数据库生成
+			name:    "zero primary key",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(&TestModel{FirstName: "Deng", Age: 18},
+					&TestModel{FirstName: "Da", Age: 19})
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`age`,`last_name`) VALUES(?,?,?),(?,?,?);",
+				Args: []any{"Deng", int8(18), (*sql.NullString)(nil), "Da", int8(19), (*sql.NullString)(nil)},
+			},
+		},
+		{
+			// 没有指定自增列的时候，即便 id 是整数类型，也当成普通的列插入
+			name:    "without auto increment",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[testPlainIdModel](db).Values(&testPlainIdModel{Name: "Deng"})
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_plain_id_model`(`id`,`name`) VALUES(?,?);",
+				Args: []any{int64(0), "Deng"},
+			},
+		},
+		{
+			// 只要有一行指定了主键，就不能省略
+			name:    "partial primary key",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(&TestModel{FirstName: "Deng"},
+					&TestModel{Id: 2, FirstName: "Da"}).Columns("Id", "FirstName")
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`) VALUES(?,?),(?,?);",
+				Args: []any{int64(0), "Deng", int64(2), "Da"},
+			},
+		},
+		{
+			// 执行的时候确认了 SQLite 的版本才会使用 RETURNING
+			name:    "sqlite",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(&TestModel{FirstName: "Deng", Age: 18}).
+					Columns("FirstName", "Age")
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`age`) VALUES(?,?);",
+				Args: []any{"Deng", int8(18)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := MemoryDB(t, DBWithDialect(tc.dialect))
+			query, err := tc.q(db).Build()
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestInserter_AutoIncrement_Exec(t *testing.T) {
+	t.Run("last insert id", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = mockDB.Close() }()
+		db, err := OpenDB(mockDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// MySQL 的 LastInsertId 是第一行的主键
+		mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(10, 1))
+		// 插入多行的时候主键不一定连续，不回填
+		mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(11, 3))
+		val := &TestModel{FirstName: "Deng"}
+		res := NewInserter[TestModel](db).Values(val).Exec(context.Background())
+		assert.Nil(t, res.Err())
+		assert.Equal(t, int64(10), val.Id)
+		vals := []*TestModel{{FirstName: "Deng"}, {FirstName: "Da"}, {FirstName: "Ming"}}
+		res = NewInserter[TestModel](db).Values(vals...).Exec(context.Background())
+		assert.Nil(t, res.Err())
+		assert.Equal(t, []int64{0, 0, 0}, []int64{vals[0].Id, vals[1].Id, vals[2].Id})
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = mockDB.Close() }()
+		db, err := OpenDB(mockDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 有 UPSERT 的时候无法推算主键
+		mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(10, 1))
+		val := &TestModel{FirstName: "Deng"}
+		res := NewInserter[TestModel](db).Values(val).
+			OnDuplicateKey().Update(C("FirstName")).Exec(context.Background())
+		assert.Nil(t, res.Err())
+		assert.Equal(t, int64(0), val.Id)
+	})
+
+	t.Run("old sqlite", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = mockDB.Close() }()
+		db, err := OpenDB(mockDB, DBWithDialect(SQLite3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 3.35 之前不支持 RETURNING，只查询一次版本
+		mock.ExpectQuery("SELECT sqlite_version\\(\\);").
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("3.31.1"))
+		mock.ExpectExec("INSERT INTO `test_model`\\(`first_name`,`age`,`last_name`\\) VALUES\\(\\?,\\?,\\?\\);").
+			WillReturnResult(sqlmock.NewResult(7, 1))
+		// SQLite 的 LastInsertId 是最后一行的主键
+		mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(10, 2))
+		val := &TestModel{FirstName: "Deng"}
+		res := NewInserter[TestModel](db).Values(val).Exec(context.Background())
+		assert.Nil(t, res.Err())
+		assert.Equal(t, int64(7), val.Id)
+		vals := []*TestModel{{FirstName: "Da"}, {FirstName: "Ming"}}
+		res = NewInserter[TestModel](db).Values(vals...).Exec(context.Background())
+		assert.Nil(t, res.Err())
+		assert.Equal(t, []int64{9, 10}, []int64{vals[0].Id, vals[1].Id})
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("returning", func(t *testing.T) {
+		db := memoryDBWithDB("insert_auto_increment", t)
+		db.dialect = SQLite3
+		var returning []bool
+		db.ms = []Middleware{func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				if i, ok := qc.Builder.(*Inserter[TestModel]); ok {
+					returning = append(returning, i.returning)
+				}
+				return next(ctx, qc)
+			}
+		}}
+		_, err := db.db.Exec(TestModel{}.CreateSQL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES (100, 'Tom', 18, 'Jerry')")
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := &sql.NullString{String: "Ming", Valid: true}
+		vals := []*TestModel{
+			{FirstName: "Deng", LastName: last},
+			{FirstName: "Da", LastName: last},
+			{FirstName: "Xiao", LastName: last},
+		}
+		res := NewInserter[TestModel](db).Values(vals...).BatchSize(2).Exec(context.Background())
+		assert.Nil(t, res.Err())
+		affected, err := res.RowsAffected()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), affected)
+		assert.Equal(t, []int64{101, 102, 103}, []int64{vals[0].Id, vals[1].Id, vals[2].Id})
+		// 多行的时候 RETURNING 的顺序不确定，只有单行才使用 RETURNING
+		assert.Equal(t, []bool{false, true}, returning)
+
+		got, err := NewSelector[TestModel](db).Where(C("Id").EQ(102)).Get(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "Da", got.FirstName)
+	})
+}
+
+func TestInserter_FromSelect_Build(t *testing.T) {
+	testCases := []struct {
+		name      string
+		q         func(db *DB) QueryBuilder
+		dialect   Dialect
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "columns",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "FirstName").
+					FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").GT(18)))
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`) SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name:    "all columns",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).FromSelect(NewSelector[TestModel](db))
+			},
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			// 聚合函数没有别名，只校验数量
+			name:    "aggregate",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Age").
+					FromSelect(NewSelector[TestModel](db).Select(Max("Age")))
+			},
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`age`) SELECT MAX(`age`) FROM `test_model`;",
+			},
+		},
+		{
+			name:    "alias",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "Age").
+					FromSelect(NewSelector[TestModel](db).Select(C("Id"), Max("Age").As("age")).GroupBy(C("Id")))
+			},
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`age`) SELECT `id`,MAX(`age`) AS `age` FROM `test_model` GROUP BY `id`;",
+			},
+		},
+		{
+			name:    "column count",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "FirstName").
+					FromSelect(NewSelector[TestModel](db))
+			},
+			wantErr: errs.NewErrInsertSelectColumnCount(2, 4),
+		},
+		{
+			name:    "column order",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "FirstName").
+					FromSelect(NewSelector[TestModel](db).Select(C("FirstName"), C("Id")))
+			},
+			wantErr: errs.NewErrInsertSelectColumnOrder(0, "Id", "FirstName"),
+		},
+		{
+			name:    "mixed source",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(&TestModel{Id: 1}).
+					FromSelect(NewSelector[TestModel](db))
+			},
+			wantErr: errs.ErrInsertMixedSource,
+		},
+		{
+			name:    "upsert",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "Age").
+					FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("Age"))).
+					OnDuplicateKey().Update(C("Age"))
+			},
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`age`) SELECT `id`,`age` FROM `test_model` ON DUPLICATE KEY UPDATE `age`=VALUES(`age`);",
+			},
+		},
+		{
+			name:    "ignore",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "Age").
+					FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("Age"))).Ignore()
+			},
+			wantQuery: &Query{
+				SQL: "INSERT IGNORE INTO `test_model`(`id`,`age`) SELECT `id`,`age` FROM `test_model`;",
+			},
+		},
+		{
+			name:    "sqlite ignore",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "Age").
+					FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("Age"))).Ignore()
+			},
+			wantQuery: &Query{
+				SQL: "INSERT OR IGNORE INTO `test_model`(`id`,`age`) SELECT `id`,`age` FROM `test_model`;",
+			},
+		},
+		{
+			// 避免 SQLite 把 ON CONFLICT 当成 JOIN 的 ON
+			name:    "sqlite upsert",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("Id", "Age").
+					FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("Age")).Where(C("Age").GT(18))).
+					OnDuplicateKey().ConflictColumns("Id").Update(C("Age"))
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`age`) SELECT * FROM (SELECT `id`,`age` FROM `test_model` WHERE `age` > ?) WHERE true ON CONFLICT(`id`) DO UPDATE SET `age`=excluded.`age`;",
+				Args: []any{18},
+			},
+		},
+		{
+			// 忽略冲突的时候不知道哪些行插入成功了，所以不用 RETURNING
+			name:    "sqlite ignore values",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Columns("FirstName").
+					Values(&TestModel{FirstName: "Deng"}).Ignore()
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT OR IGNORE INTO `test_model`(`first_name`) VALUES(?);",
+				Args: []any{"Deng"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := MemoryDB(t, DBWithDialect(tc.dialect))
+			query, err := tc.q(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+type testArchiveModel struct {
+	Id        int64
+	FirstName string
+	Age       int8
+}
+
+func TestInserter_FromSelect_Exec(t *testing.T) {
+	db := memoryDBWithDB("insert_from_select", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("CREATE TABLE IF NOT EXISTS `test_archive_model`(" +
+		"id INTEGER PRIMARY KEY, first_name TEXT NOT NULL, age INTEGER)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES " +
+		"(1, 'Tom', 18, ''), (2, 'Jerry', 20, ''), (3, 'Deng', 30, '')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_archive_model`(`id`,`first_name`,`age`) VALUES (1, 'Tom', 10)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	res := NewInserter[testArchiveModel](db).Columns("Id", "FirstName", "Age").
+		FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("FirstName"), C("Age")).
+			Where(C("Age").LT(25))).Ignore().Exec(ctx)
+	assert.Nil(t, res.Err())
+	affected, err := res.RowsAffected()
+	assert.Nil(t, err)
+	// id = 1 已经存在，被忽略了
+	assert.Equal(t, int64(1), affected)
+
+	res = NewInserter[testArchiveModel](db).Columns("Id", "FirstName", "Age").
+		FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("FirstName"), C("Age"))).
+		OnDuplicateKey().ConflictColumns("Id").Update(C("Age")).Exec(ctx)
+	assert.Nil(t, res.Err())
+
+	got, err := RawQuery[testArchiveModel](db, "SELECT * FROM `test_archive_model` WHERE `id` = ?", 1).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &testArchiveModel{Id: 1, FirstName: "Tom", Age: 18}, got)
+	got, err = RawQuery[testArchiveModel](db, "SELECT * FROM `test_archive_model` WHERE `id` = ?", 3).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &testArchiveModel{Id: 3, FirstName: "Deng", Age: 30}, got)
+}
+
+// testPlainIdModel 的 id 没有标记为自增列
+type testPlainIdModel struct {
+	Id   int64
+	Name string
+}
+
+type testDefaultModel struct {
+	Id        int64 `orm:"auto_increment=true"`
+	Name      string
+	CreatedAt int64 `orm:"default=true"`
+}
+
+func TestInserter_Expression_Build(t *testing.T) {
+	db := MemoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "raw",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Deng"}, &TestModel{Id: 2, FirstName: "Da"}).
+				Set("Age", Raw("?+1", 17)),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`,`age`) VALUES(?,?,?+1),(?,?,?+1);",
+				Args: []any{int64(1), "Deng", 17, int64(2), "Da", 17},
+			},
+		},
+		{
+			// 覆盖 Columns 里面已有的列，保持原本的位置
+			name: "override",
+			q: NewInserter[TestModel](db).Columns("Id", "Age", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Deng", Age: 18}).
+				Set("Age", Func("ABS", C("Id").Sub(20))),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`age`,`first_name`) VALUES(?,ABS((`id` - ?)),?);",
+				Args: []any{int64(1), 20, "Deng"},
+			},
+		},
+		{
+			name: "value",
+			q: NewInserter[TestModel](db).Columns("Id").
+				Values(&TestModel{Id: 1}).Set("FirstName", "Tom"),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`) VALUES(?,?);",
+				Args: []any{int64(1), "Tom"},
+			},
+		},
+		{
+			// 通过表达式指定了自增主键，就不需要数据库生成
+			name: "set auto increment",
+			q: NewInserter[TestModel](db).Columns("FirstName").
+				Values(&TestModel{FirstName: "Tom"}).Set("Id", Func("UUID_SHORT")),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`id`) VALUES(?,UUID_SHORT());",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "omit",
+			q: NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "Deng"}).
+				Omit("Id", "LastName"),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`age`) VALUES(?,?);",
+				Args: []any{"Deng", int8(0)},
+			},
+		},
+		{
+			name: "default zero",
+			q: NewInserter[testDefaultModel](db).Values(&testDefaultModel{Id: 1, Name: "Tom"},
+				&testDefaultModel{Id: 2, Name: "Jerry"}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_default_model`(`id`,`name`) VALUES(?,?),(?,?);",
+				Args: []any{int64(1), "Tom", int64(2), "Jerry"},
+			},
+		},
+		{
+			// 只要有一行不是零值，就要插入这一列
+			name: "default non-zero",
+			q: NewInserter[testDefaultModel](db).Values(&testDefaultModel{Id: 1, Name: "Tom"},
+				&testDefaultModel{Id: 2, Name: "Jerry", CreatedAt: 100}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_default_model`(`id`,`name`,`created_at`) VALUES(?,?,?),(?,?,?);",
+				Args: []any{int64(1), "Tom", int64(0), int64(2), "Jerry", int64(100)},
+			},
+		},
+		{
+			name: "default set",
+			q: NewInserter[testDefaultModel](db).Values(&testDefaultModel{Id: 1, Name: "Tom"}).
+				Set("CreatedAt", Raw("UNIX_TIMESTAMP()")),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_default_model`(`id`,`name`,`created_at`) VALUES(?,?,UNIX_TIMESTAMP());",
+				Args: []any{int64(1), "Tom"},
+			},
+		},
+		{
+			name:    "invalid omit",
+			q:       NewInserter[TestModel](db).Values(&TestModel{Id: 1}).Omit("Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "invalid set",
+			q:       NewInserter[TestModel](db).Values(&TestModel{Id: 1}).Set("Invalid", 1),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "set with select",
+			q: NewInserter[TestModel](db).FromSelect(NewSelector[TestModel](db)).
+				Set("Age", 1),
+			wantErr: errs.ErrInsertSetWithSelect,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestInserter_Expression_Exec(t *testing.T) {
+	db := memoryDBWithDB("insert_expression", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec("CREATE TABLE IF NOT EXISTS `test_default_model`(" +
+		"id INTEGER PRIMARY KEY, name TEXT NOT NULL, created_at INTEGER NOT NULL DEFAULT 100)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	vals := []*testDefaultModel{{Name: "Tom"}, {Name: "Jerry"}}
+	res := NewInserter[testDefaultModel](db).Values(vals...).Exec(ctx)
+	assert.Nil(t, res.Err())
+	assert.Equal(t, int64(1), vals[0].Id)
+	assert.Equal(t, int64(2), vals[1].Id)
+
+	val := &testDefaultModel{Name: "Deng"}
+	res = NewInserter[testDefaultModel](db).Values(val).
+		Set("CreatedAt", Func("LENGTH", "Deng").Add(2)).Exec(ctx)
+	assert.Nil(t, res.Err())
+
+	got, err := RawQuery[testDefaultModel](db, "SELECT * FROM `test_default_model` WHERE `id` = ?", 2).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &testDefaultModel{Id: 2, Name: "Jerry", CreatedAt: 100}, got)
+	got, err = RawQuery[testDefaultModel](db, "SELECT * FROM `test_default_model` WHERE `id` = ?", val.Id).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), val.Id)
+	assert.Equal(t, int64(6), got.CreatedAt)
+}
+
+func TestInserter_Maps_Build(t *testing.T) {
+	db := MemoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// 按照字段定义的顺序排列，key 可以是列名
+			name: "multiple rows",
+			q: NewInserter[TestModel](db).Maps(
+				map[string]any{"age": 18, "FirstName": "Tom"},
+				map[string]any{"FirstName": "Jerry", "age": 20},
+			),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`age`) VALUES(?,?),(?,?);",
+				Args: []any{"Tom", 18, "Jerry", 20},
+			},
+		},
+		{
+			name: "expression",
+			q: NewInserter[TestModel](db).Maps(map[string]any{"Id": 1, "Age": Raw("18+1")}).
+				Set("FirstName", Func("UPPER", "tom")),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`age`,`first_name`) VALUES(?,18+1,UPPER(?));",
+				Args: []any{1, "tom"},
+			},
+		},
+		{
+			name: "omit",
+			q: NewInserter[TestModel](db).Maps(map[string]any{"Id": 1, "Age": 18}).
+				Omit("Id"),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`age`) VALUES(?);",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "upsert",
+			q: NewInserter[TestModel](db).Maps(map[string]any{"Id": 1, "Age": 18}).
+				OnDuplicateKey().Update(C("Age")),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`age`) VALUES(?,?) ON DUPLICATE KEY UPDATE `age`=VALUES(`age`);",
+				Args: []any{1, 18},
+			},
+		},
+		{
+			name:    "unknown key",
+			q:       NewInserter[TestModel](db).Maps(map[string]any{"Invalid": 1}),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "duplicate key",
+			q:       NewInserter[TestModel](db).Maps(map[string]any{"Age": 1, "age": 2}),
+			wantErr: errs.NewErrDuplicateField("Age"),
+		},
+		{
+			name: "inconsistent keys",
+			q: NewInserter[TestModel](db).Maps(
+				map[string]any{"Id": 1, "Age": 18},
+				map[string]any{"Id": 2, "FirstName": "Tom"},
+			),
+			wantErr: errs.NewErrInconsistentMapKeys(1),
+		},
+		{
+			name: "less keys",
+			q: NewInserter[TestModel](db).Maps(
+				map[string]any{"Id": 1, "Age": 18},
+				map[string]any{"Id": 2},
+			),
+			wantErr: errs.NewErrInconsistentMapKeys(1),
+		},
+		{
+			name: "with values",
+			q: NewInserter[TestModel](db).Maps(map[string]any{"Id": 1}).
+				Values(&TestModel{Id: 2}),
+			wantErr: errs.ErrInsertMixedSource,
+		},
+		{
+			name: "with columns",
+			q: NewInserter[TestModel](db).Maps(map[string]any{"Id": 1}).
+				Columns("Id"),
+			wantErr: errs.ErrInsertMapsWithColumns,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestInserter_Maps_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithDialect(SQLite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SQLite 最多 999 个参数，每一行两列，所以每一批 499 行
+	mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(499, 499))
+	mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(600, 101))
+	rows := make([]map[string]any, 0, 600)
+	for idx := 0; idx < 600; idx++ {
+		rows = append(rows, map[string]any{"FirstName": "Tom", "Age": idx % 100})
+	}
+	res := NewInserter[TestModel](db).Maps(rows...).Exec(context.Background())
+	assert.Nil(t, res.Err())
+	affected, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(600), affected)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -18,6 +18,28 @@ var (
 	ErrUnknownColumn             = errors.New("orm: 未知列")
 	ErrUnknownField              = errors.New("orm: 未知字段")
 	ErrUnsupportedAssignableType = errors.New("orm: 不支持的赋值类型")
+	ErrInsertMixedSource         = errors.New("orm: Values、Maps 和 FromSelect 只能使用一个")
+	ErrInsertMapsWithColumns     = errors.New("orm: 使用 Maps 的时候列由 map 的 key 决定，不能使用 Columns")
+	ErrInsertSetWithSelect       = errors.New("orm: 不能同时使用 Set 和 FromSelect")
+	ErrUnsupportedReturning      = errors.New("orm: 当前数据库不支持 RETURNING")
+	ErrNoPrimaryKey              = errors.New("orm: 模型没有主键")
+	ErrJoinDeleteWithLimit       = errors.New("orm: 多表 DELETE 不支持 ORDER BY 和 LIMIT")
+	ErrUpdateZeroRow             = errors.New("orm: 批量更新 0 行")
+	ErrBatchUpdateNoKey          = errors.New("orm: 批量更新需要通过 Key 指定定位行的字段，或者模型有且只有一个主键")
+	ErrEmptyQuery                = errors.New("orm: 查询为空")
+	ErrUnbalancedParentheses     = errors.New("orm: 括号不配对")
+	ErrMixedPlaceholders         = errors.New("orm: 不能同时使用 ? 和命名参数")
+	ErrMissingQueryName          = errors.New("orm: SQL 前面缺少 -- name:")
+	ErrInvalidQueryName          = errors.New("orm: 查询的名字只能包含字母、数字和下划线，并且不能以数字开头")
+	ErrEmptyShardingDBs          = errors.New("orm: 分库分表至少需要一个数据库")
+	ErrShardingRawSQL            = errors.New("orm: 分库分表的时候不能直接执行 SQL，需要在具体的库上执行")
+	ErrOnConnectWithOpenDB       = errors.New("orm: OpenDB 的连接池已经创建好了，DBWithOnConnect 需要使用 Open 或者 OpenConnector")
+	// ErrTxExists 使用 PropagationNever 的时候 context 里面已经有事务了
+	ErrTxExists = errors.New("orm: 当前已经在事务中")
+	// ErrMissingWhere UPDATE 和 DELETE 没有 WHERE 条件，确实需要修改整个表的时候使用 AllowFullTable
+	ErrMissingWhere = errors.New("orm: UPDATE 和 DELETE 缺少 WHERE 条件")
+	// ErrAlwaysTrueWhere 严格模式下 WHERE 条件恒为真，等同于没有 WHERE
+	ErrAlwaysTrueWhere = fmt.Errorf("%w: 条件恒为真", ErrMissingWhere)
 )
 
 // NewErrUnknownField 创建并返回一个错误，用于指示传入的字段是一个未知字段
@@ -30,6 +52,64 @@ func NewErrUnknownColumn(col string) error {
 	return fmt.Errorf("orm: 未知列 %s", col)
 }
 
+// NewErrInsertSelectColumnCount 创建并返回一个错误，用于指示 INSERT ... SELECT 两边的列数不一致
+func NewErrInsertSelectColumnCount(insertCnt, selectCnt int) error {
+	return fmt.Errorf("orm: INSERT 的列数 %d 和 SELECT 的列数 %d 不一致", insertCnt, selectCnt)
+}
+
+// NewErrInsertSelectColumnOrder 创建并返回一个错误，用于指示 INSERT ... SELECT 两边的列顺序不一致
+func NewErrInsertSelectColumnOrder(idx int, col string, selected string) error {
+	return fmt.Errorf("orm: INSERT 的第 %d 列是 %s，但是 SELECT 的第 %d 列是 %s", idx, col, idx, selected)
+}
+
+// NewErrInconsistentMapKeys 创建并返回一个错误，用于指示第 idx 行 map 的 key 和第一行不一致
+func NewErrInconsistentMapKeys(idx int) error {
+	return fmt.Errorf("orm: 第 %d 行的列和第一行不一致", idx)
+}
+
+// NewErrDuplicateField 创建并返回一个错误，用于指示同一个字段出现了多次，例如同时使用了字段名和列名
+func NewErrDuplicateField(fd string) error {
+	return fmt.Errorf("orm: 重复的字段 %s", fd)
+}
+
+// NewErrZeroPrimaryKey 创建并返回一个错误，用于指示根据主键更新的时候主键 fd 是零值
+func NewErrZeroPrimaryKey(fd string) error {
+	return fmt.Errorf("orm: 主键 %s 是零值", fd)
+}
+
+// NewErrMissingNamedArg 创建并返回一个错误，用于指示原生查询里面的命名参数 name 没有对应的值
+func NewErrMissingNamedArg(name string) error {
+	return fmt.Errorf("orm: 缺少命名参数 %s", name)
+}
+
+// NewErrEmptySliceArg 创建并返回一个错误，用于指示命名参数 name 是空切片，展开之后会生成错误的 IN ()
+func NewErrEmptySliceArg(name string) error {
+	return fmt.Errorf("orm: 命名参数 %s 是空切片", name)
+}
+
+// NewErrUnterminatedSQL 创建并返回一个错误，用于指示 SQL 里面从 rest 开始的引号或者注释没有闭合
+func NewErrUnterminatedSQL(rest string) error {
+	if len(rest) > 20 {
+		rest = rest[:20] + "..."
+	}
+	return fmt.Errorf("orm: SQL 里面有未闭合的引号或者注释 %s", rest)
+}
+
+// NewErrInvalidQuery 创建并返回一个错误，用于指示文件 file 里面的查询 name 不合法
+func NewErrInvalidQuery(file string, name string, err error) error {
+	return fmt.Errorf("orm: 文件 %s 里面的查询 %s 不合法: %w", file, name, err)
+}
+
+// NewErrDuplicateQuery 创建并返回一个错误，用于指示查询 name 在 file1 和 file2 里面重复定义
+func NewErrDuplicateQuery(name string, file1 string, file2 string) error {
+	return fmt.Errorf("orm: 查询 %s 重复定义，分别在 %s 和 %s", name, file1, file2)
+}
+
+// NewErrUnknownQuery 创建并返回一个错误，用于指示查询 name 不存在
+func NewErrUnknownQuery(name string) error {
+	return fmt.Errorf("orm: 未知查询 %s", name)
+}
+
 // NewErrUnsupportedAssignableType 创建一个错误，用于表示不支持的可分配类型
 func NewErrUnsupportedAssignableType(exp any) error {
 	return fmt.Errorf("orm: 不支持的 Assignable 表达式 %v", exp)
@@ -45,6 +125,11 @@ func NewErrUnsupportedTableType(exp any) error {
 	return fmt.Errorf("orm: 不支持的 TableReference %v", exp)
 }
 
+// NewErrUnsupportedJoin 创建一个错误，用于指示当前数据库的 UPDATE 和 DELETE 不支持这种 JOIN
+func NewErrUnsupportedJoin(typ string) error {
+	return fmt.Errorf("orm: UPDATE 和 DELETE 只支持使用 ON 的内连接，不支持 %s", typ)
+}
+
 // NewErrUnsupportedSelectable 创建并返回一个错误，用于表示不支持的可选择项
 // 这个函数通常用于处理 ORM (对象关系映射) 操作中不被支持的目标列情况
 func NewErrUnsupportedSelectable(exp any) error {
@@ -66,6 +151,16 @@ func NewErrInvalidTagContent(tag string) error {
 	return fmt.Errorf("orm: 错误的标签设置: %s", tag)
 }
 
+// NewErrInvalidFieldValue 创建并返回一个错误，用于指示 val 不能赋值给字段 fd
+func NewErrInvalidFieldValue(fd string, val any) error {
+	return fmt.Errorf("orm: 字段 %s 不能设置为 %T 类型的值", fd, val)
+}
+
+// NewErrInvalidAutoIncrement 创建并返回一个错误，用于指示自增列不是整数类型
+func NewErrInvalidAutoIncrement(fd string) error {
+	return fmt.Errorf("orm: 自增列必须是整数类型 %s", fd)
+}
+
 // NewErrFailToRollbackTx 创建一个新的错误，用于表示事务回滚失败的情况
 // 参数:
 // - bizErr: 业务操作中发生的错误。这个错误会被包装在返回的错误中，用于追溯原始错误
@@ -76,3 +171,98 @@ func NewErrInvalidTagContent(tag string) error {
 func NewErrFailToRollbackTx(bizErr error, rbErr error, panicked bool) error {
 	return fmt.Errorf("orm: 回滚事务失败, 业务错误 %w, 回滚错误 %s, panic: %t", bizErr, rbErr.Error(), panicked)
 }
+
+// NewErrFailToRollbackSavepoint 回滚到保存点失败，参数的含义和 NewErrFailToRollbackTx 一样
+func NewErrFailToRollbackSavepoint(name string, bizErr error, rbErr error, panicked bool) error {
+	return fmt.Errorf("orm: 回滚到保存点 %s 失败, 业务错误 %w, 回滚错误 %s, panic: %t", name, bizErr, rbErr.Error(), panicked)
+}
+
+// NewErrInvalidSavepoint 保存点的名字不合法
+func NewErrInvalidSavepoint(name string) error {
+	return fmt.Errorf("orm: 非法的保存点名字 %q，只能包含字母、数字和下划线，并且不能以数字开头", name)
+}
+
+// NewErrUnknownPropagation 不支持的事务传播方式
+func NewErrUnknownPropagation(p int) error {
+	return fmt.Errorf("orm: 未知的事务传播方式 %d", p)
+}
+
+// NewErrTxRetryExhausted 事务重试了 attempts 次之后仍然失败，err 是最后一次的错误
+func NewErrTxRetryExhausted(attempts int, err error) error {
+	return fmt.Errorf("orm: 事务执行了 %d 次仍然失败: %w", attempts, err)
+}
+
+// NewErrNoShardingRule 模型没有设置分片规则
+func NewErrNoShardingRule(entity any) error {
+	return fmt.Errorf("orm: %T 没有设置分片规则", entity)
+}
+
+// NewErrUnknownShardingDB 分片规则里面的库不存在
+func NewErrUnknownShardingDB(name string) error {
+	return fmt.Errorf("orm: 未知的分库 %s", name)
+}
+
+// NewErrShardingKeyType 分片键的类型不符合分片算法的要求
+func NewErrShardingKeyType(key string, val any) error {
+	return fmt.Errorf("orm: 分片键 %s 的值 %v 的类型 %T 不支持", key, val, val)
+}
+
+// NewErrShardingOutOfRange 分片键的值不在任何一个区间里面
+func NewErrShardingOutOfRange(key string, val any) error {
+	return fmt.Errorf("orm: 分片键 %s 的值 %v 超出了范围", key, val)
+}
+
+// NewErrShardingUnsupported 分库分表的时候不支持的用法
+func NewErrShardingUnsupported(feature string) error {
+	return fmt.Errorf("orm: 分库分表的时候不支持%s", feature)
+}
+
+// NewErrShardingMergeField 合并多个分片的结果的时候，需要 fd 出现在查询的列里面
+func NewErrShardingMergeField(fd string) error {
+	return fmt.Errorf("orm: 合并分片的结果需要查询 %s，聚合函数需要使用和字段列名相同的别名", fd)
+}
+
+// NewErrInvalidPartitionField 分表字段的类型不是时间
+func NewErrInvalidPartitionField(field string) error {
+	return fmt.Errorf("orm: 分表字段 %s 的类型必须是 time.Time、*time.Time 或者 sql.NullTime", field)
+}
+
+// NewErrPartitionKeyType 分表字段的值不是时间，或者是 NULL
+func NewErrPartitionKeyType(field string, val any) error {
+	return fmt.Errorf("orm: 分表字段 %s 的值 %v 的类型 %T 不支持", field, val, val)
+}
+
+// NewErrPartitionUnbounded WHERE 条件没有限定分表字段的上下限，无法确定需要查询哪些表
+func NewErrPartitionUnbounded(field string) error {
+	return fmt.Errorf("orm: 无法确定需要查询的分表，WHERE 需要通过 =、IN 或者范围条件限定 %s 的上下限", field)
+}
+
+// NewErrNoPartition WHERE 条件没有命中任何一张分表
+func NewErrNoPartition(field string) error {
+	return fmt.Errorf("orm: %s 的条件没有命中任何一张分表", field)
+}
+
+// NewErrPartitionUnsupported 按照时间分表的时候不支持的用法
+func NewErrPartitionUnsupported(feature string) error {
+	return fmt.Errorf("orm: 按照时间分表的时候不支持%s", feature)
+}
+
+// NewErrPartitionTemplate 自动创建分表的时候找不到作为模板的表
+func NewErrPartitionTemplate(table string) error {
+	return fmt.Errorf("orm: 自动创建分表需要模板表 %s", table)
+}
+
+// NewErrNotPartitioned 模型没有设置按照时间分表
+func NewErrNotPartitioned(entity any) error {
+	return fmt.Errorf("orm: %T 没有设置按照时间分表", entity)
+}
+
+// NewErrNotReady 等待数据库可用的时候 ctx 结束了，err 是最后一次 Ping 的错误
+func NewErrNotReady(attempts int, err error) error {
+	return fmt.Errorf("orm: Ping 了 %d 次数据库仍然不可用: %w", attempts, err)
+}
+
+// NewErrTxUnsupported 会话 sess 无法开启事务，例如分库分表的 ShardingDB
+func NewErrTxUnsupported(sess any) error {
+	return fmt.Errorf("orm: %T 不支持在事务中执行", sess)
+}
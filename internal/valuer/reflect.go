@@ -32,6 +32,19 @@ func (r reflectValue) Field(name string) (any, error) {
 	return res.Interface(), nil
 }
 
+func (r reflectValue) SetField(name string, val any) error {
+	fd := r.val.FieldByName(name)
+	if fd == (reflect.Value{}) {
+		return errs.NewErrUnknownField(name)
+	}
+	v, err := convert(name, val, fd.Type())
+	if err != nil {
+		return err
+	}
+	fd.Set(v)
+	return nil
+}
+
 func (r reflectValue) SetColumns(rows *sql.Rows) error {
 	cs, err := rows.Columns()
 	if err != nil {
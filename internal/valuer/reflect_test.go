@@ -361,3 +361,67 @@ type valueFieldTestCase struct {
 	wantVal   interface{}
 	wantError error
 }
+
+func TestValue_SetField(t *testing.T) {
+	r := model.NewRegistry()
+	meta, err := r.Get(&test.SimpleStruct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name    string
+		field   string
+		val     any
+		wantVal *test.SimpleStruct
+		wantErr error
+	}{
+		{
+			name:    "same type",
+			field:   "String",
+			val:     "hello",
+			wantVal: &test.SimpleStruct{String: "hello"},
+		},
+		{
+			// 自增主键回填的时候，拿到的都是 int64
+			name:    "number",
+			field:   "Id",
+			val:     int64(12),
+			wantVal: &test.SimpleStruct{Id: 12},
+		},
+		{
+			name:    "nil",
+			field:   "IntPtr",
+			val:     nil,
+			wantVal: &test.SimpleStruct{},
+		},
+		{
+			name:    "invalid type",
+			field:   "String",
+			val:     12,
+			wantErr: errs.NewErrInvalidFieldValue("String", 12),
+		},
+		{
+			name:    "invalid field",
+			field:   "Invalid",
+			val:     12,
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	creators := map[string]Creator{
+		"reflect": NewReflectValue,
+		"unsafe":  NewUnsafeValue,
+	}
+	for name, creator := range creators {
+		for _, tc := range testCases {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				entity := &test.SimpleStruct{}
+				err := creator(entity, meta).SetField(tc.field, tc.val)
+				assert.Equal(t, tc.wantErr, err)
+				if err != nil {
+					return
+				}
+				assert.Equal(t, tc.wantVal, entity)
+			})
+		}
+	}
+}
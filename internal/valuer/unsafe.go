@@ -32,6 +32,20 @@ func (u unsafeValue) Field(name string) (interface{}, error) {
 	return val.Interface(), nil
 }
 
+func (u unsafeValue) SetField(name string, val any) error {
+	fd, ok := u.meta.FieldMap[name]
+	if !ok {
+		return errs.NewErrUnknownField(name)
+	}
+	v, err := convert(name, val, fd.Type)
+	if err != nil {
+		return err
+	}
+	ptr := unsafe.Pointer(uintptr(u.addr) + fd.Offset)
+	reflect.NewAt(fd.Type, ptr).Elem().Set(v)
+	return nil
+}
+
 func (u unsafeValue) SetColumns(rows *sql.Rows) error {
 	cs, err := rows.Columns()
 	if err != nil {
@@ -2,19 +2,50 @@ package valuer
 
 import (
 	"database/sql"
+	"github.com/xzhHas/sorm/internal/errs"
 	"github.com/xzhHas/sorm/model"
+	"reflect"
 )
 
 // Value 是对结构体实例的内部抽象
 type Value interface {
 	// Field 返回字段对应的值
 	Field(name string) (any, error)
+	// SetField 设置字段的值，val 的类型必须能够转换为字段的类型
+	SetField(name string, val any) error
 	// SetColumns 设置新值
 	SetColumns(rows *sql.Rows) error
 }
 
 type Creator func(val any, meta *model.Model) Value
 
+// convert 把 val 转换为字段的类型 typ
+// 数字之间可以相互转换，其它类型要求 val 能够直接赋值给字段
+func convert(name string, val any, typ reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(val)
+	if !v.IsValid() {
+		return reflect.Zero(typ), nil
+	}
+	if v.Type().AssignableTo(typ) {
+		return v, nil
+	}
+	if isNumber(v.Kind()) && isNumber(typ.Kind()) {
+		return v.Convert(typ), nil
+	}
+	return reflect.Value{}, errs.NewErrInvalidFieldValue(name, val)
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
 // ResultSetHandler 这是另外一种可行的设计方案
 // type ResultSetHandler interface {
 // 	// SetColumns 设置新值，column 是列名
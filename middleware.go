@@ -5,16 +5,58 @@ import (
 	"github.com/xzhHas/sorm/model"
 )
 
+// QueryContext.Type 的取值，中间件可以根据它区分不同的语句
+const (
+	TypeSelect = "SELECT"
+	TypeInsert = "INSERT"
+	TypeUpdate = "UPDATE"
+	TypeDelete = "DELETE"
+	// TypeRaw 原生 SQL，即 RawQuerier
+	TypeRaw = "RAW"
+	// TypeDDL 建表之类的语句
+	TypeDDL = "DDL"
+	// TypeBegin、TypeCommit 和 TypeRollback 是事务的开启、提交和回滚，
+	// 此时 Model 为 nil，Builder 构造出来的 SQL 就是 BEGIN、COMMIT 和 ROLLBACK
+	TypeBegin    = "BEGIN"
+	TypeCommit   = "COMMIT"
+	TypeRollback = "ROLLBACK"
+	// TypeSavepoint、TypeReleaseSavepoint 和 TypeRollbackTo 是事务里面保存点的创建、释放和回滚，
+	// Builder 构造出来的 SQL 带有保存点的名字，例如 SAVEPOINT `sp_1`
+	TypeSavepoint        = "SAVEPOINT"
+	TypeReleaseSavepoint = "RELEASE SAVEPOINT"
+	TypeRollbackTo       = "ROLLBACK TO SAVEPOINT"
+)
+
 // QueryContext 代表执行数据库查询时的上下文信息
 type QueryContext struct {
-	// Type 声明查询类型。即 SELECT, UPDATE, DELETE 和 INSERT
+	// Type 声明查询类型，参考 TypeSelect 等常量
 	Type string
 	// builder 使用的时候，大多数情况下你需要转换到具体的类型，才能修改查询
 	Builder QueryBuilder
-	// Model 存储与查询相关的模型信息
+	// Model 存储与查询相关的模型信息，原生查询的结果不是结构体的时候为 nil
 	Model *model.Model
 }
 
+// newQueryContext 创建 QueryContext，Model 是 T 对应的元数据
+// T 不是结构体的时候（例如 RawQuery[int]），Model 为 nil
+func newQueryContext[T any](c core, typ string, b QueryBuilder) *QueryContext {
+	qc := &QueryContext{
+		Type:    typ,
+		Builder: b,
+	}
+	if m, err := c.r.Get(new(T)); err == nil {
+		qc.Model = m
+	}
+	return qc
+}
+
+// txStatement 事务的开启、提交、回滚、保存点以及自动建表之类不需要参数的语句，Build 返回对应的 SQL，方便中间件统一处理
+type txStatement string
+
+func (s txStatement) Build() (*Query, error) {
+	return &Query{SQL: string(s)}, nil
+}
+
 // QueryResult 代表数据库查询操作的结果
 type QueryResult struct {
 	// Result 在不同的查询里面，类型是不同的
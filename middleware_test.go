@@ -0,0 +1,113 @@
+package sorm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// queryEvent 记录经过中间件的语句
+type queryEvent struct {
+	typ   string
+	table string
+	sql   string
+}
+
+func TestMiddleware_Chain(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+
+	var events []queryEvent
+	db, err := OpenDB(mockDB, DBWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			evt := queryEvent{typ: qc.Type}
+			if qc.Model != nil {
+				evt.table = qc.Model.TableName
+			}
+			q, err := qc.Builder.Build()
+			if err == nil {
+				evt.sql = q.SQL
+			}
+			events = append(events, evt)
+			return next(ctx, qc)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("CREATE .*").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	ctx := context.Background()
+	_, err = NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+	assert.Nil(t, err)
+	res := NewInserter[TestModel](db).Values(&TestModel{Id: 1}).Exec(ctx)
+	assert.Nil(t, res.Err())
+	res = NewUpdater[TestModel](db).Set(Assign("Age", 18)).Where(C("Id").EQ(1)).Exec(ctx)
+	assert.Nil(t, res.Err())
+	res = NewDeleter[TestModel](db).Where(C("Id").EQ(1)).Exec(ctx)
+	assert.Nil(t, res.Err())
+	res = RawQuery[any](db, "CREATE TABLE t(id INT)").Exec(ctx)
+	assert.Nil(t, res.Err())
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		return NewUpdater[TestModel](tx).Set(Assign("Age", 18)).Where(C("Id").EQ(1)).Exec(ctx).Err()
+	}, nil)
+	assert.Nil(t, err)
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		return errors.New("mock error")
+	}, nil)
+	assert.Equal(t, errors.New("mock error"), err)
+
+	assert.Equal(t, []queryEvent{
+		{typ: TypeSelect, table: "test_model", sql: "SELECT * FROM `test_model` WHERE `id` = ?;"},
+		{typ: TypeInsert, table: "test_model", sql: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?);"},
+		{typ: TypeUpdate, table: "test_model", sql: "UPDATE `test_model` SET `age`=? WHERE `id` = ?;"},
+		{typ: TypeDelete, table: "test_model", sql: "DELETE FROM `test_model` WHERE `id` = ?;"},
+		{typ: TypeRaw, sql: "CREATE TABLE t(id INT)"},
+		{typ: TypeBegin, sql: "BEGIN"},
+		{typ: TypeUpdate, table: "test_model", sql: "UPDATE `test_model` SET `age`=? WHERE `id` = ?;"},
+		{typ: TypeCommit, sql: "COMMIT"},
+		{typ: TypeBegin, sql: "BEGIN"},
+		{typ: TypeRollback, sql: "ROLLBACK"},
+	}, events)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddleware_Tx(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+
+	// 中间件可以拒绝开启事务
+	db, err := OpenDB(mockDB, DBWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			if qc.Type == TypeBegin {
+				return &QueryResult{Err: errors.New("mock error")}
+			}
+			return next(ctx, qc)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, tx)
+	assert.Equal(t, errors.New("mock error"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
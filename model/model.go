@@ -1,6 +1,9 @@
 package model
 
-import "reflect"
+import (
+	"reflect"
+	"time"
+)
 
 // Model 代表一个数据库表的模型，包含了表名及所有字段的描述信息
 type Model struct {
@@ -12,6 +15,11 @@ type Model struct {
 	FieldMap map[string]*Field
 	// ColumnMap 是一个由数据库列名映射到Field结构体的map，便于快速查找字段
 	ColumnMap map[string]*Field
+	// PrimaryKeys 主键字段，按照字段定义的顺序排列，联合主键的时候会有多个
+	// 没有通过标签或者选项指定的时候，列名为 id 的字段会被当成主键
+	PrimaryKeys []*Field
+	// Partition 按照时间分表的配置，没有分表的时候为 nil，参考 WithTimePartition
+	Partition *Partition
 }
 
 // Field 结构体，描述一个字段与其对应的数据库列之间的映射关系
@@ -26,13 +34,83 @@ type Field struct {
 	Index int
 	// Offset 相对于对象起始地址的偏移量，单位为字节，用于反射操作时定位字段内存位置
 	Offset uintptr
+	// PrimaryKey 是否是主键
+	PrimaryKey bool
+	// AutoIncrement 是否是自增列，自增列只能是整数类型，需要通过 auto_increment 标签或者 WithAutoIncrement 指定
+	AutoIncrement bool
+	// Default 数据库里面这一列有默认值，插入的时候如果所有行都是零值，那么就不插入这一列
+	Default bool
+}
+
+// PartitionUnit 按照时间分表的粒度
+type PartitionUnit int
+
+const (
+	// PartitionMonthly 按月分表，表名后缀形如 _202609
+	PartitionMonthly PartitionUnit = iota
+	// PartitionDaily 按天分表，表名后缀形如 _20260918
+	PartitionDaily
+)
+
+// Partition 按照时间分表的配置
+// 物理表的名字是 TableName 加上时间后缀，例如 events_202609，时间按照 Location 所在的时区计算
+type Partition struct {
+	// Field 决定数据所在的表的时间字段
+	Field *Field
+	// Unit 分表的粒度
+	Unit PartitionUnit
+	// Location 计算表名使用的时区
+	Location *time.Location
+}
+
+// Table 返回 t 所在的物理表
+func (p *Partition) Table(base string, t time.Time) string {
+	layout := "200601"
+	if p.Unit == PartitionDaily {
+		layout = "20060102"
+	}
+	return base + "_" + t.In(p.Location).Format(layout)
+}
+
+// Tables 返回 from 到 to（包含两端）之间的所有物理表，按照时间先后排列
+func (p *Partition) Tables(base string, from, to time.Time) []string {
+	from, to = from.In(p.Location), to.In(p.Location)
+	var cur time.Time
+	if p.Unit == PartitionDaily {
+		cur = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, p.Location)
+	} else {
+		cur = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, p.Location)
+	}
+	var res []string
+	for !cur.After(to) {
+		res = append(res, p.Table(base, cur))
+		if p.Unit == PartitionDaily {
+			cur = cur.AddDate(0, 0, 1)
+		} else {
+			cur = cur.AddDate(0, 1, 0)
+		}
+	}
+	return res
+}
+
+// AutoIncrementField 返回自增主键字段，没有的时候返回 nil
+func (m *Model) AutoIncrementField() *Field {
+	for _, fd := range m.Fields {
+		if fd.AutoIncrement {
+			return fd
+		}
+	}
+	return nil
 }
 
 // tagKeyColumn 是一个常量，定义了Go结构体标签中用于指定数据库列名的键值
 // 我们支持的全部标签上的 key 都放在这里
 // 方便用户查找，和我们后期维护
 const (
-	tagKeyColumn = "column"
+	tagKeyColumn        = "column"
+	tagKeyPrimaryKey    = "primary_key"
+	tagKeyAutoIncrement = "auto_increment"
+	tagKeyDefault       = "default"
 )
 
 // 用户自定义一些模型信息的接口，集中放在这里
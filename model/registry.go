@@ -1,10 +1,13 @@
 package model
 
 import (
+	"database/sql"
 	"github.com/xzhHas/sorm/internal/errs"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
@@ -79,6 +82,7 @@ func (r *registry) parseModel(val any) (*Model, error) {
 	fieldMap := make(map[string]*Field, numField)
 	fields := make([]*Field, 0, numField)
 	colMap := make(map[string]*Field, numField)
+	var pks []*Field
 	for i := 0; i < numField; i++ {
 		fdType := typ.Field(i)
 		tags, err := r.parseTag(fdType.Tag)
@@ -96,10 +100,34 @@ func (r *registry) parseModel(val any) (*Model, error) {
 			Offset:  fdType.Offset,
 			Index:   i,
 		}
+		if f.PrimaryKey, err = r.parseBoolTag(tags, tagKeyPrimaryKey); err != nil {
+			return nil, err
+		}
+		if f.AutoIncrement, err = r.parseBoolTag(tags, tagKeyAutoIncrement); err != nil {
+			return nil, err
+		}
+		if f.Default, err = r.parseBoolTag(tags, tagKeyDefault); err != nil {
+			return nil, err
+		}
+		if f.AutoIncrement && !isInteger(f.Type) {
+			return nil, errs.NewErrInvalidAutoIncrement(f.GoName)
+		}
+		if f.PrimaryKey {
+			pks = append(pks, f)
+		}
 		fieldMap[fdType.Name] = f
 		fields = append(fields, f)
 		colMap[colName] = f
 	}
+	// 没有显式指定主键的时候，按照约定把 id 列当成主键
+	// 自增列不按照约定推断，只能通过 auto_increment 标签或者 WithAutoIncrement 指定
+	if len(pks) == 0 {
+		if f, ok := colMap["id"]; ok {
+			f.PrimaryKey = true
+			pks = append(pks, f)
+		}
+	}
+
 	var tableName string
 	if tn, ok := val.(TableName); ok {
 		tableName = tn.TableName()
@@ -110,10 +138,11 @@ func (r *registry) parseModel(val any) (*Model, error) {
 	}
 
 	return &Model{
-		TableName: tableName,
-		FieldMap:  fieldMap,
-		ColumnMap: colMap,
-		Fields:    fields,
+		TableName:   tableName,
+		FieldMap:    fieldMap,
+		ColumnMap:   colMap,
+		Fields:      fields,
+		PrimaryKeys: pks,
 	}, nil
 }
 
@@ -140,6 +169,31 @@ func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 	return res, nil
 }
 
+// parseBoolTag 解析布尔类型的标签，例如 orm:"primary_key=true"
+// 没有设置的时候返回 false
+func (r *registry) parseBoolTag(tags map[string]string, key string) (bool, error) {
+	val, ok := tags[key]
+	if !ok {
+		return false, nil
+	}
+	res, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, errs.NewErrInvalidTagContent(key + "=" + val)
+	}
+	return res, nil
+}
+
+// isInteger 判断是不是整数类型，只有整数类型才能作为自增列
+func isInteger(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
 // underscoreName 将驼峰命名转换为下划线命名
 func underscoreName(tableName string) string {
 	var buf []byte
@@ -179,3 +233,79 @@ func WithColumnName(field string, columnName string) Option {
 		return nil
 	}
 }
+
+// WithPrimaryKey 用于设置模型的主键，多个字段的时候就是联合主键
+// 它会覆盖标签和默认约定所设置的主键
+func WithPrimaryKey(fields ...string) Option {
+	return func(model *Model) error {
+		pks := make([]*Field, 0, len(fields))
+		for _, field := range fields {
+			fd, ok := model.FieldMap[field]
+			if !ok {
+				return errs.NewErrUnknownField(field)
+			}
+			pks = append(pks, fd)
+		}
+		for _, fd := range model.PrimaryKeys {
+			fd.PrimaryKey = false
+		}
+		for _, fd := range pks {
+			fd.PrimaryKey = true
+		}
+		model.PrimaryKeys = pks
+		return nil
+	}
+}
+
+// WithAutoIncrement 用于设置模型的自增列，传入空字符串代表模型没有自增列
+func WithAutoIncrement(field string) Option {
+	return func(model *Model) error {
+		var target *Field
+		if field != "" {
+			fd, ok := model.FieldMap[field]
+			if !ok {
+				return errs.NewErrUnknownField(field)
+			}
+			if !isInteger(fd.Type) {
+				return errs.NewErrInvalidAutoIncrement(field)
+			}
+			target = fd
+		}
+		for _, fd := range model.Fields {
+			fd.AutoIncrement = fd == target
+		}
+		return nil
+	}
+}
+
+// WithTimePartition 按照时间字段 field 分表，field 的类型可以是 time.Time、*time.Time 或者 sql.NullTime
+// 表名按照 UTC 计算，例如按月分表的时候，UTC 时间 2026 年 9 月的数据存放在 events_202609。
+// 需要按照其它时区划分的时候使用 WithTimePartitionIn
+func WithTimePartition(field string, unit PartitionUnit) Option {
+	return WithTimePartitionIn(field, unit, time.UTC)
+}
+
+// WithTimePartitionIn 和 WithTimePartition 一样，但是表名按照 loc 所在的时区计算，loc 为 nil 的时候使用 UTC
+// 不要使用 time.Local，否则部署在不同时区的服务在月底或者日末会读写不同的表
+func WithTimePartitionIn(field string, unit PartitionUnit, loc *time.Location) Option {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		switch fd.Type {
+		case reflect.TypeOf(time.Time{}), reflect.TypeOf(&time.Time{}), reflect.TypeOf(sql.NullTime{}):
+		default:
+			return errs.NewErrInvalidPartitionField(field)
+		}
+		model.Partition = &Partition{
+			Field:    fd,
+			Unit:     unit,
+			Location: loc,
+		}
+		return nil
+	}
+}
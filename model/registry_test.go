@@ -7,6 +7,7 @@ import (
 	"github.com/xzhHas/sorm/internal/errs"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestModelWithTableName(t *testing.T) {
@@ -124,6 +125,7 @@ func TestRegistry_get(t *testing.T) {
 					"age":        tm.AgeField(),
 					"last_name":  tm.LastNameField(),
 				},
+				PrimaryKeys: []*Field{tm.IdField()},
 			},
 		},
 		{
@@ -162,28 +164,22 @@ func TestRegistry_get(t *testing.T) {
 				}
 				return &ColumnTag{}
 			}(),
-			wantModel: &Model{
-				TableName: "column_tag",
-				Fields: []*Field{{
-					ColName: "id",
-					Type:    reflect.TypeOf(uint64(0)),
-					GoName:  "ID",
-				}},
-				FieldMap: map[string]*Field{
-					"ID": {
-						ColName: "id",
-						Type:    reflect.TypeOf(uint64(0)),
-						GoName:  "ID",
-					},
-				},
-				ColumnMap: map[string]*Field{
-					"id": {
-						ColName: "id",
-						Type:    reflect.TypeOf(uint64(0)),
-						GoName:  "ID",
-					},
-				},
-			},
+			// 列名是 id，所以会被当成主键，但是不会被当成自增列
+			wantModel: func() *Model {
+				fd := &Field{
+					ColName:    "id",
+					Type:       reflect.TypeOf(uint64(0)),
+					GoName:     "ID",
+					PrimaryKey: true,
+				}
+				return &Model{
+					TableName:   "column_tag",
+					Fields:      []*Field{fd},
+					FieldMap:    map[string]*Field{"ID": fd},
+					ColumnMap:   map[string]*Field{"id": fd},
+					PrimaryKeys: []*Field{fd},
+				}
+			}(),
 		},
 		{
 			// 如果用户设置了 column，但是传入一个空字符串，那么会用默认的名字
@@ -358,6 +354,270 @@ func TestRegistry_get(t *testing.T) {
 	}
 }
 
+func TestRegistry_PrimaryKey(t *testing.T) {
+	testCases := []struct {
+		name        string
+		val         any
+		opts        []Option
+		wantPKs     []string
+		wantAutoInc string
+		wantErr     error
+	}{
+		{
+			// 按照约定，id 列就是主键，但是自增需要显式指定
+			name:    "default",
+			val:     &TestModel{},
+			wantPKs: []string{"Id"},
+		},
+		{
+			name: "auto increment tag",
+			val: func() any {
+				type AutoIncTag struct {
+					Id int64 `orm:"auto_increment=true"`
+				}
+				return &AutoIncTag{}
+			}(),
+			wantPKs:     []string{"Id"},
+			wantAutoInc: "Id",
+		},
+		{
+			name: "no id",
+			val: func() any {
+				type NoId struct {
+					Name string
+				}
+				return &NoId{}
+			}(),
+		},
+		{
+			// 字符串类型的 id 不是自增列
+			name: "string id",
+			val: func() any {
+				type StringId struct {
+					Id string
+				}
+				return &StringId{}
+			}(),
+			wantPKs: []string{"Id"},
+		},
+		{
+			name: "tag",
+			val: func() any {
+				type PKTag struct {
+					Id     int64
+					UserId int64 `orm:"primary_key=true,auto_increment=true"`
+				}
+				return &PKTag{}
+			}(),
+			wantPKs:     []string{"UserId"},
+			wantAutoInc: "UserId",
+		},
+		{
+			name: "composite tag",
+			val: func() any {
+				type CompositeTag struct {
+					OrderId int64 `orm:"primary_key=true"`
+					ItemId  int64 `orm:"primary_key=true"`
+				}
+				return &CompositeTag{}
+			}(),
+			wantPKs: []string{"OrderId", "ItemId"},
+		},
+		{
+			name: "invalid bool tag",
+			val: func() any {
+				type InvalidBool struct {
+					Id int64 `orm:"primary_key=yes"`
+				}
+				return &InvalidBool{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("primary_key=yes"),
+		},
+		{
+			name: "invalid auto increment tag",
+			val: func() any {
+				type InvalidAutoInc struct {
+					Name string `orm:"auto_increment=true"`
+				}
+				return &InvalidAutoInc{}
+			}(),
+			wantErr: errs.NewErrInvalidAutoIncrement("Name"),
+		},
+		{
+			name:    "option",
+			val:     &TestModel{},
+			opts:    []Option{WithPrimaryKey("FirstName", "LastName"), WithAutoIncrement("")},
+			wantPKs: []string{"FirstName", "LastName"},
+		},
+		{
+			name:    "composite primary key option",
+			val:     &TestModel{},
+			opts:    []Option{WithPrimaryKey("FirstName", "Age")},
+			wantPKs: []string{"FirstName", "Age"},
+		},
+		{
+			// 自增列不一定是主键，替换主键之后也会保留下来
+			name: "composite primary key with tagged id",
+			val: func() any {
+				type TaggedId struct {
+					Id      int64 `orm:"auto_increment=true"`
+					OrderId int64
+					ItemId  int64
+				}
+				return &TaggedId{}
+			}(),
+			opts:        []Option{WithPrimaryKey("OrderId", "ItemId")},
+			wantPKs:     []string{"OrderId", "ItemId"},
+			wantAutoInc: "Id",
+		},
+		{
+			name:        "auto increment option",
+			val:         &TestModel{},
+			opts:        []Option{WithAutoIncrement("Age")},
+			wantPKs:     []string{"Id"},
+			wantAutoInc: "Age",
+		},
+		{
+			name:    "invalid primary key option",
+			val:     &TestModel{},
+			opts:    []Option{WithPrimaryKey("Invalid")},
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "invalid auto increment option",
+			val:     &TestModel{},
+			opts:    []Option{WithAutoIncrement("FirstName")},
+			wantErr: errs.NewErrInvalidAutoIncrement("FirstName"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry()
+			m, err := r.Register(tc.val, tc.opts...)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			var pks []string
+			for _, fd := range m.PrimaryKeys {
+				assert.True(t, fd.PrimaryKey)
+				pks = append(pks, fd.GoName)
+			}
+			assert.Equal(t, tc.wantPKs, pks)
+			var autoInc string
+			if fd := m.AutoIncrementField(); fd != nil {
+				autoInc = fd.GoName
+			}
+			assert.Equal(t, tc.wantAutoInc, autoInc)
+		})
+	}
+}
+
+func TestRegistry_Default(t *testing.T) {
+	type DefaultModel struct {
+		Id        int64
+		CreatedAt int64 `orm:"default=true"`
+		Status    int8  `orm:"default=false"`
+	}
+	m, err := NewRegistry().Register(&DefaultModel{})
+	assert.Nil(t, err)
+	assert.False(t, m.FieldMap["Id"].Default)
+	assert.True(t, m.FieldMap["CreatedAt"].Default)
+	assert.False(t, m.FieldMap["Status"].Default)
+
+	type InvalidDefault struct {
+		CreatedAt int64 `orm:"default=1s"`
+	}
+	_, err = NewRegistry().Register(&InvalidDefault{})
+	assert.Equal(t, errs.NewErrInvalidTagContent("default=1s"), err)
+}
+
+func TestWithTimePartition(t *testing.T) {
+	type Event struct {
+		Id        int64
+		CreatedAt time.Time
+		DeletedAt *time.Time
+		UpdatedAt sql.NullTime
+		Name      string
+	}
+	shanghai := time.FixedZone("Asia/Shanghai", 8*3600)
+	testCases := []struct {
+		name     string
+		opt      Option
+		wantUnit PartitionUnit
+		wantLoc  *time.Location
+		wantErr  error
+	}{
+		{
+			// 默认按照 UTC 计算，不受服务器时区的影响
+			name:     "time",
+			opt:      WithTimePartition("CreatedAt", PartitionMonthly),
+			wantUnit: PartitionMonthly,
+			wantLoc:  time.UTC,
+		},
+		{
+			name:     "pointer",
+			opt:      WithTimePartition("DeletedAt", PartitionDaily),
+			wantUnit: PartitionDaily,
+			wantLoc:  time.UTC,
+		},
+		{
+			name:     "null time",
+			opt:      WithTimePartition("UpdatedAt", PartitionDaily),
+			wantUnit: PartitionDaily,
+			wantLoc:  time.UTC,
+		},
+		{
+			name:     "location",
+			opt:      WithTimePartitionIn("CreatedAt", PartitionMonthly, shanghai),
+			wantUnit: PartitionMonthly,
+			wantLoc:  shanghai,
+		},
+		{
+			name:     "nil location",
+			opt:      WithTimePartitionIn("CreatedAt", PartitionMonthly, nil),
+			wantUnit: PartitionMonthly,
+			wantLoc:  time.UTC,
+		},
+		{
+			name:    "invalid type",
+			opt:     WithTimePartition("Name", PartitionMonthly),
+			wantErr: errs.NewErrInvalidPartitionField("Name"),
+		},
+		{
+			name:    "unknown field",
+			opt:     WithTimePartition("Invalid", PartitionMonthly),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewRegistry().Register(&Event{}, tc.opt)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantUnit, m.Partition.Unit)
+			assert.Equal(t, tc.wantLoc, m.Partition.Location)
+		})
+	}
+
+	monthly := &Partition{Unit: PartitionMonthly, Location: shanghai}
+	// UTC 的 8 月 31 日 20 点是北京时间 9 月 1 日
+	assert.Equal(t, "event_202609", monthly.Table("event", time.Date(2026, time.August, 31, 20, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []string{"event_202611", "event_202612", "event_202701"},
+		monthly.Tables("event", time.Date(2026, time.November, 30, 0, 0, 0, 0, shanghai),
+			time.Date(2027, time.January, 1, 0, 0, 0, 0, shanghai)))
+	daily := &Partition{Unit: PartitionDaily, Location: shanghai}
+	assert.Equal(t, []string{"event_20260228", "event_20260301"},
+		daily.Tables("event", time.Date(2026, time.February, 28, 23, 0, 0, 0, shanghai),
+			time.Date(2026, time.March, 1, 1, 0, 0, 0, shanghai)))
+	assert.Equal(t, []string(nil),
+		daily.Tables("event", time.Date(2026, time.March, 2, 0, 0, 0, 0, shanghai),
+			time.Date(2026, time.March, 1, 0, 0, 0, 0, shanghai)))
+}
+
 func Test_underscoreName(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -423,11 +683,12 @@ type TestModel struct {
 
 func (TestModel) IdField() *Field {
 	return &Field{
-		ColName: "id",
-		Type:    reflect.TypeOf(int64(0)),
-		GoName:  "Id",
-		Offset:  0,
-		Index:   0,
+		ColName:    "id",
+		Type:       reflect.TypeOf(int64(0)),
+		GoName:     "Id",
+		Offset:     0,
+		Index:      0,
+		PrimaryKey: true,
 	}
 }
 
@@ -0,0 +1,239 @@
+package sorm
+
+import (
+	"database/sql/driver"
+	"github.com/xzhHas/sorm/internal/errs"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Named 命名参数，用于原生查询，例如：
+// RawQuery[User](db, "SELECT * FROM `user` WHERE `id` = :id AND `status` IN (:statuses)", Named{"id": 1, "statuses": []int{1, 2}})
+type Named map[string]any
+
+// namedSource 返回 args 里面的命名参数来源，只有一个参数，
+// 并且是 Named 或者结构体（指针）的时候才会使用命名参数，否则返回 nil
+func namedSource(args []any) any {
+	if len(args) != 1 {
+		return nil
+	}
+	switch arg := args[0].(type) {
+	case Named:
+		return arg
+	case driver.Valuer, time.Time, *time.Time:
+		return nil
+	}
+	typ := reflect.TypeOf(args[0])
+	if typ == nil {
+		return nil
+	}
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return args[0]
+}
+
+// namedLookup 按照名字查找参数
+type namedLookup func(name string) (any, error)
+
+// structLookup 从结构体里面按照字段名或者列名查找参数
+func (c core) structLookup(val any) (namedLookup, error) {
+	if reflect.TypeOf(val).Kind() != reflect.Pointer {
+		// valuer 要求传入指针
+		ptr := reflect.New(reflect.TypeOf(val))
+		ptr.Elem().Set(reflect.ValueOf(val))
+		val = ptr.Interface()
+	}
+	m, err := c.r.Get(val)
+	if err != nil {
+		return nil, err
+	}
+	v := c.valCreator(val, m)
+	return func(name string) (any, error) {
+		fd, ok := m.FieldMap[name]
+		if !ok {
+			fd, ok = m.ColumnMap[name]
+		}
+		if !ok {
+			return nil, errs.NewErrMissingNamedArg(name)
+		}
+		return v.Field(fd.GoName)
+	}, nil
+}
+
+// bindNamed 把 query 里面的 :name 替换成数据库方言的占位符，并且按照出现的顺序生成参数
+// 切片会被展开成多个占位符，用于 IN (:ids) 之类的场景，空切片会返回错误。
+// 字符串、带引号的标识符和注释里面的内容不会被替换，:: 也不会被当成参数
+func bindNamed(dialect Dialect, query string, lookup namedLookup) (*Query, error) {
+	tokens, err := tokenizeSQL(dialect, query)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.Grow(len(query))
+	args := make([]any, 0, 8)
+	for _, tk := range tokens {
+		if tk.kind != tokenNamed {
+			sb.WriteString(tk.text)
+			continue
+		}
+		val, err := lookup(tk.text)
+		if err != nil {
+			return nil, err
+		}
+		if args, err = appendNamedArg(&sb, dialect, args, tk.text, val); err != nil {
+			return nil, err
+		}
+	}
+	return &Query{SQL: sb.String(), Args: args}, nil
+}
+
+// sqlTokenKind SQL 片段的类型
+type sqlTokenKind int
+
+const (
+	// tokenCode 普通的 SQL
+	tokenCode sqlTokenKind = iota
+	// tokenLiteral 字符串、带引号的标识符和注释，原样保留
+	tokenLiteral
+	// tokenNamed 命名参数，text 是去掉冒号之后的名字
+	tokenNamed
+	// tokenPositional 占位符 ?
+	tokenPositional
+)
+
+// sqlToken SQL 片段
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// tokenizeSQL 把 query 拆分成片段，用于替换命名参数和校验 SQL，字符串的转义规则由 dialect 决定
+// 引号或者注释没有闭合的时候返回错误
+func tokenizeSQL(dialect Dialect, query string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	code := 0
+	flush := func(i int) {
+		if code < i {
+			tokens = append(tokens, sqlToken{kind: tokenCode, text: query[code:i]})
+		}
+	}
+	for i := 0; i < len(query); {
+		ch := query[i]
+		var kind sqlTokenKind
+		var end int
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			var ok bool
+			if end, ok = skipQuoted(query, i, ch, dialect.backslashEscapes()); !ok {
+				return nil, errs.NewErrUnterminatedSQL(query[i:])
+			}
+			kind = tokenLiteral
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end = strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i
+			}
+			kind = tokenLiteral
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end = strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, errs.NewErrUnterminatedSQL(query[i:])
+			}
+			end += i + 4
+			kind = tokenLiteral
+		case ch == ':' && strings.HasPrefix(query[i:], "::"):
+			i += 2
+			continue
+		case ch == ':' && i+1 < len(query) && isNameStart(query[i+1]):
+			end = i + 2
+			for end < len(query) && isNamePart(query[end]) {
+				end++
+			}
+			flush(i)
+			tokens = append(tokens, sqlToken{kind: tokenNamed, text: query[i+1 : end]})
+			i, code = end, end
+			continue
+		case ch == '?':
+			end = i + 1
+			kind = tokenPositional
+		default:
+			i++
+			continue
+		}
+		flush(i)
+		tokens = append(tokens, sqlToken{kind: kind, text: query[i:end]})
+		i, code = end, end
+	}
+	flush(len(query))
+	return tokens, nil
+}
+
+// appendNamedArg 写入参数对应的占位符，切片会被展开
+func appendNamedArg(sb *strings.Builder, dialect Dialect, args []any, name string, val any) ([]any, error) {
+	rv := reflect.ValueOf(val)
+	if _, ok := val.(driver.Valuer); ok || val == nil ||
+		rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		sb.WriteString(dialect.placeholder(len(args) + 1))
+		return append(args, val), nil
+	}
+	if rv.Len() == 0 {
+		return nil, errs.NewErrEmptySliceArg(name)
+	}
+	for idx := 0; idx < rv.Len(); idx++ {
+		if idx > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(dialect.placeholder(len(args) + 1))
+		args = append(args, rv.Index(idx).Interface())
+	}
+	return args, nil
+}
+
+// skipQuoted 返回从 start 开始的引号内容结束之后的下标，两个连续的引号代表引号本身
+// backslash 为 true 的时候，单引号和双引号里面的反斜杠会转义下一个字符，例如 MySQL；
+// 否则反斜杠是普通字符，例如 SQLite 里面的 'C:\'。引号没有闭合的时候返回 false
+func skipQuoted(query string, start int, quote byte, backslash bool) (int, bool) {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslash && quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return len(query), false
+}
+
+func isNameStart(ch byte) bool {
+	return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+}
+
+func isNamePart(ch byte) bool {
+	return isNameStart(ch) || ('0' <= ch && ch <= '9')
+}
+
+// isIdentifier 判断 name 是不是由字母、数字和下划线组成，并且不以数字开头，name 不能为空
+func isIdentifier(name string) bool {
+	if !isNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isNamePart(name[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -119,7 +119,7 @@ func TestSQLite(t *testing.T) {
 }
 
 type TestModel struct {
-	Id        int64
+	Id        int64 `orm:"auto_increment=true"`
 	FirstName string
 	Age       int8
 	LastName  *sql.NullString
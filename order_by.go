@@ -0,0 +1,28 @@
+package sorm
+
+// OrderBy 排序条件，通过 Asc 和 Desc 创建
+type OrderBy struct {
+	table TableReference
+	col   string
+	order string
+}
+
+// Asc 按照字段 col 升序排列，col 是字段名
+func Asc(col string) OrderBy {
+	return OrderBy{col: col, order: "ASC"}
+}
+
+// Desc 按照字段 col 降序排列，col 是字段名
+func Desc(col string) OrderBy {
+	return OrderBy{col: col, order: "DESC"}
+}
+
+// Asc 按照当前列升序排列
+func (c Column) Asc() OrderBy {
+	return OrderBy{table: c.table, col: c.name, order: "ASC"}
+}
+
+// Desc 按照当前列降序排列
+func (c Column) Desc() OrderBy {
+	return OrderBy{table: c.table, col: c.name, order: "DESC"}
+}
@@ -0,0 +1,443 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/model"
+	"slices"
+	"sort"
+	"time"
+)
+
+// timeRange 分表字段的一个取值范围，包含两端
+// from 或者 to 是零值的时候代表没有下限或者上限
+type timeRange struct {
+	from time.Time
+	to   time.Time
+}
+
+// bounded 是否同时有上限和下限
+func (r timeRange) bounded() bool {
+	return !r.from.IsZero() && !r.to.IsZero()
+}
+
+// intersect 返回两个范围的交集，没有交集的时候第二个返回值为 false
+func (r timeRange) intersect(o timeRange) (timeRange, bool) {
+	res := r
+	if res.from.IsZero() || (!o.from.IsZero() && o.from.After(res.from)) {
+		res.from = o.from
+	}
+	if res.to.IsZero() || (!o.to.IsZero() && o.to.Before(res.to)) {
+		res.to = o.to
+	}
+	return res, !res.bounded() || !res.from.After(res.to)
+}
+
+// intersectRanges 返回 a 和 b 的交集
+func intersectRanges(a, b []timeRange) []timeRange {
+	var res []timeRange
+	for _, x := range a {
+		for _, y := range b {
+			if r, ok := x.intersect(y); ok {
+				res = append(res, r)
+			}
+		}
+	}
+	return res
+}
+
+// partitionTime 把分表字段的值转换成时间
+func partitionTime(field string, val any) (time.Time, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case sql.NullTime:
+		if v.Valid {
+			return v.Time, nil
+		}
+	}
+	return time.Time{}, errs.NewErrPartitionKeyType(field, val)
+}
+
+// partitionRanges 根据 WHERE 条件找到分表字段可能的取值范围
+func partitionRanges(fd *model.Field, ps []Predicate) ([]timeRange, error) {
+	res := []timeRange{{}}
+	for _, p := range ps {
+		sub, err := partitionPredicate(fd, p)
+		if err != nil {
+			return nil, err
+		}
+		res = intersectRanges(res, sub)
+	}
+	return res, nil
+}
+
+// partitionPredicate 分析单个条件，只有分表字段的 =、IN 和大小比较能够缩小范围
+func partitionPredicate(fd *model.Field, p Predicate) ([]timeRange, error) {
+	all := []timeRange{{}}
+	switch p.op {
+	case opAND, opOR:
+		left, ok1 := p.left.(Predicate)
+		right, ok2 := p.right.(Predicate)
+		if !ok1 || !ok2 {
+			return all, nil
+		}
+		l, err := partitionPredicate(fd, left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := partitionPredicate(fd, right)
+		if err != nil {
+			return nil, err
+		}
+		if p.op == opAND {
+			return intersectRanges(l, r), nil
+		}
+		return append(l, r...), nil
+	case opEQ, opIN, opLT, opLTE, opGT, opGTE:
+		col, ok := p.left.(Column)
+		if !ok || col.name != fd.GoName {
+			return all, nil
+		}
+		val, ok := p.right.(value)
+		if !ok {
+			return all, nil
+		}
+		vals := []any{val.val}
+		if p.op == opIN {
+			if vals, ok = val.val.([]any); !ok {
+				return all, nil
+			}
+		}
+		res := make([]timeRange, 0, len(vals))
+		for _, v := range vals {
+			t, err := partitionTime(fd.GoName, v)
+			if err != nil {
+				return nil, err
+			}
+			switch p.op {
+			case opLT:
+				res = append(res, timeRange{to: t.Add(-time.Nanosecond)})
+			case opLTE:
+				res = append(res, timeRange{to: t})
+			case opGT:
+				res = append(res, timeRange{from: t.Add(time.Nanosecond)})
+			case opGTE:
+				res = append(res, timeRange{from: t})
+			default:
+				res = append(res, timeRange{from: t, to: t})
+			}
+		}
+		return res, nil
+	default:
+		return all, nil
+	}
+}
+
+// partitionTables 根据 WHERE 条件找到需要访问的分表，按照时间先后排列
+// 条件必须限定分表字段的上下限，否则无法确定需要访问哪些表
+func partitionTables(m *model.Model, ps []Predicate) ([]string, error) {
+	p := m.Partition
+	ranges, err := partitionRanges(p.Field, ps)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, errs.NewErrNoPartition(p.Field.GoName)
+	}
+	var res []string
+	for _, r := range ranges {
+		if !r.bounded() {
+			return nil, errs.NewErrPartitionUnbounded(p.Field.GoName)
+		}
+		for _, tbl := range p.Tables(m.TableName, r.from, r.to) {
+			if !slices.Contains(res, tbl) {
+				res = append(res, tbl)
+			}
+		}
+	}
+	// 后缀的长度是一样的，所以按照字符串排序也就是按照时间排序
+	sort.Strings(res)
+	return res, nil
+}
+
+// partitionModel 返回需要按照时间分表的模型
+// 模型没有分表、已经指定了物理表，或者 table 是 JOIN 之类的时候返回 nil
+func (b *builder) partitionModel(entity any, table TableReference) *model.Model {
+	if b.tableName != "" {
+		return nil
+	}
+	m, err := b.r.Get(entity)
+	if err != nil || m.Partition == nil {
+		return nil
+	}
+	switch tab := table.(type) {
+	case nil:
+		return m
+	case Table:
+		if tm, err := b.r.Get(tab.entity); err == nil && tm == m {
+			return m
+		}
+	}
+	return nil
+}
+
+// withTable 返回一个使用物理表 table 的 builder
+func (b *builder) withTable(table string) builder {
+	return builder{
+		core:      b.core,
+		dialect:   b.dialect,
+		quoter:    b.quoter,
+		tableName: table,
+	}
+}
+
+// buildPartitionUnion 查询跨越多张分表的时候，在每张表上执行 WHERE，然后用 UNION ALL 合并成一张表，
+// 别名是逻辑表名或者 From 指定的别名，这样外层的 GROUP BY、ORDER BY 和 LIMIT 之类的部分不需要改动
+func (s *Selector[T]) buildPartitionUnion(tables []string) error {
+	s.sb.WriteByte('(')
+	for i, tbl := range tables {
+		if i > 0 {
+			s.sb.WriteString(" UNION ALL ")
+		}
+		s.tableName = tbl
+		s.sb.WriteString("SELECT * FROM ")
+		if err := s.buildTable(s.table); err != nil {
+			return err
+		}
+		if len(s.where) > 0 {
+			s.sb.WriteString(" WHERE ")
+			if err := s.buildPredicates(s.where); err != nil {
+				return err
+			}
+		}
+	}
+	s.sb.WriteString(") AS ")
+	alias := s.model.TableName
+	if tab, ok := s.table.(Table); ok && tab.alias != "" {
+		alias = tab.alias
+	}
+	s.quote(alias)
+	return nil
+}
+
+// partitionOf 返回 row 按照分表字段的值所在的分表
+func (b *builder) partitionOf(m *model.Model, row any) (string, error) {
+	p := m.Partition
+	val, err := b.valCreator(row, m).Field(p.Field.GoName)
+	if err != nil {
+		return "", err
+	}
+	t, err := partitionTime(p.Field.GoName, val)
+	if err != nil {
+		return "", err
+	}
+	return p.Table(m.TableName, t), nil
+}
+
+// groupByPartition 按照分表把 rows 分组，tables 是分表出现的顺序
+func groupByPartition[T any](b *builder, m *model.Model, rows []*T) ([]string, map[string][]*T, error) {
+	var tables []string
+	groups := make(map[string][]*T, 2)
+	for _, row := range rows {
+		tbl, err := b.partitionOf(m, row)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := groups[tbl]; !ok {
+			tables = append(tables, tbl)
+		}
+		groups[tbl] = append(groups[tbl], row)
+	}
+	return tables, groups, nil
+}
+
+// partitionExec 按照每一行分表字段的值把数据插入到对应的分表，不存在的分表会先创建
+func (i *Inserter[T]) partitionExec(ctx context.Context, m *model.Model) Result {
+	if len(i.maps) > 0 || i.sel != nil {
+		return Result{err: errs.NewErrPartitionUnsupported("使用 Maps 和 FromSelect 插入")}
+	}
+	if len(i.values) == 0 {
+		return Result{err: errs.ErrInsertZeroRow}
+	}
+	tables, groups, err := groupByPartition(&i.builder, m, i.values)
+	if err != nil {
+		return Result{err: err}
+	}
+	fns := make([]execFunc, 0, len(tables))
+	for _, tbl := range tables {
+		if err := createPartition(ctx, i.sess, i.core, m, tbl); err != nil {
+			return Result{err: err}
+		}
+		sub := *i
+		sub.builder = i.withTable(tbl)
+		sub.values = groups[tbl]
+		fns = append(fns, func(ctx context.Context, sess Session) Result {
+			sub.sess = sess
+			return sub.Exec(ctx)
+		})
+	}
+	if len(fns) == 1 {
+		return fns[0](ctx, i.sess)
+	}
+	return execChunks(ctx, i.sess, fns, i.inTx)
+}
+
+// partitionExec 在 WHERE 条件命中的每一张分表上执行更新
+// 使用 UpdateNonZero 和 UpdateAll 的时候，如果结构体里面分表字段不是零值，那么只更新它所在的分表
+func (u *Updater[T]) partitionExec(ctx context.Context, m *model.Model) Result {
+	ps := u.where
+	if u.mode != updateExplicit && u.val != nil {
+		fd := m.Partition.Field
+		val, err := u.valCreator(u.val, m).Field(fd.GoName)
+		if err != nil {
+			return Result{err: err}
+		}
+		if t, err := partitionTime(fd.GoName, val); err == nil && !t.IsZero() {
+			ps = append(slices.Clip(ps), C(fd.GoName).EQ(t))
+		}
+	}
+	tables, err := partitionTables(m, ps)
+	if err != nil {
+		return Result{err: err}
+	}
+	fns := make([]execFunc, 0, len(tables))
+	for _, tbl := range tables {
+		sub := *u
+		sub.builder = u.withTable(tbl)
+		fns = append(fns, func(ctx context.Context, _ Session) Result {
+			return sub.Exec(ctx)
+		})
+	}
+	return execShards(ctx, u.sess, fns)
+}
+
+// partitionExec 按照每一行分表字段的值，在对应的分表上执行批量更新
+func (b *BatchUpdater[T]) partitionExec(ctx context.Context, m *model.Model) Result {
+	if len(b.values) == 0 {
+		return Result{err: errs.ErrUpdateZeroRow}
+	}
+	tables, groups, err := groupByPartition(&b.builder, m, b.values)
+	if err != nil {
+		return Result{err: err}
+	}
+	fns := make([]execFunc, 0, len(tables))
+	for _, tbl := range tables {
+		sub := *b
+		sub.builder = b.withTable(tbl)
+		sub.values = groups[tbl]
+		fns = append(fns, func(ctx context.Context, sess Session) Result {
+			sub.sess = sess
+			return sub.Exec(ctx)
+		})
+	}
+	if len(fns) == 1 {
+		return fns[0](ctx, b.sess)
+	}
+	return execChunks(ctx, b.sess, fns, b.inTx)
+}
+
+// partitionQueries 返回在 WHERE 条件命中的每一张分表上执行的 Deleter
+func (d *Deleter[T]) partitionQueries(m *model.Model) ([]*Deleter[T], error) {
+	tables, err := partitionTables(m, d.where)
+	if err != nil {
+		return nil, err
+	}
+	if d.limit > 0 && len(tables) > 1 {
+		return nil, errs.NewErrPartitionUnsupported("在多张分表上使用 LIMIT 删除")
+	}
+	res := make([]*Deleter[T], 0, len(tables))
+	for _, tbl := range tables {
+		sub := *d
+		sub.builder = d.withTable(tbl)
+		res = append(res, &sub)
+	}
+	return res, nil
+}
+
+// partitionExec 在 WHERE 条件命中的每一张分表上执行删除
+func (d *Deleter[T]) partitionExec(ctx context.Context, m *model.Model) Result {
+	queries, err := d.partitionQueries(m)
+	if err != nil {
+		return Result{err: err}
+	}
+	fns := make([]execFunc, 0, len(queries))
+	for _, q := range queries {
+		fns = append(fns, func(ctx context.Context, _ Session) Result {
+			return q.Exec(ctx)
+		})
+	}
+	return execShards(ctx, d.sess, fns)
+}
+
+// partitionGetMulti 在 WHERE 条件命中的每一张分表上执行删除，并且合并 RETURNING 的结果
+func (d *Deleter[T]) partitionGetMulti(ctx context.Context, m *model.Model) ([]*T, error) {
+	queries, err := d.partitionQueries(m)
+	if err != nil {
+		return nil, err
+	}
+	var res []*T
+	for _, q := range queries {
+		rows, err := q.GetMulti(ctx)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, rows...)
+	}
+	return res, nil
+}
+
+// createPartition 以逻辑表为模板创建分表 table，语句经过中间件，类型是 TypeDDL
+// 创建过的表会记录下来，不会重复执行；在事务里面创建的表要等到提交之后才会记录，因为回滚会撤销建表
+func createPartition(ctx context.Context, sess Session, c core, m *model.Model, table string) error {
+	if c.partitions != nil {
+		if _, ok := c.partitions.Load(table); ok {
+			return nil
+		}
+	}
+	stmt, err := c.dialect.createTableLike(ctx, sess, table, m.TableName)
+	if err != nil {
+		return err
+	}
+	res := exec(ctx, sess, c, &QueryContext{
+		Type:    TypeDDL,
+		Builder: txStatement(stmt),
+		Model:   m,
+	})
+	if res.err != nil || c.partitions == nil {
+		return res.err
+	}
+	if tx, ok := sess.(*Tx); ok {
+		tx.OnCommit(func(ctx context.Context) {
+			c.partitions.Store(table, struct{}{})
+		})
+	} else {
+		c.partitions.Store(table, struct{}{})
+	}
+	return nil
+}
+
+// CreatePartitions 提前创建 entity 在 from 到 to 之间的分表，entity 需要通过 model.WithTimePartition 设置分表
+// 插入数据的时候会自动创建分表，但是查询不会，所以查询一段还没有数据的时间之前，可以先调用它建表。
+// 另外 MySQL 的 DDL 会隐式提交当前的事务，需要在事务里面插入数据的时候，也可以先调用它建表
+func CreatePartitions(ctx context.Context, sess Session, entity any, from, to time.Time) error {
+	c := coreOf(sess)
+	m, err := c.r.Get(entity)
+	if err != nil {
+		return err
+	}
+	if m.Partition == nil {
+		return errs.NewErrNotPartitioned(entity)
+	}
+	for _, tbl := range m.Partition.Tables(m.TableName, from, to) {
+		if err = createPartition(ctx, sess, c, m, tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
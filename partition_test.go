@@ -0,0 +1,305 @@
+package sorm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/model"
+	"testing"
+	"time"
+)
+
+// PartitionEvent 按月分表的模型，物理表是 partition_event_202609 之类的
+type PartitionEvent struct {
+	Id        int64 `orm:"auto_increment=true"`
+	Name      string
+	CreatedAt time.Time
+}
+
+func (PartitionEvent) CreateSQL() string {
+	return `
+CREATE TABLE IF NOT EXISTS partition_event(
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+)
+`
+}
+
+// partitionRegistry 注册按月分表的 PartitionEvent
+func partitionRegistry(t *testing.T) model.Registry {
+	r := model.NewRegistry()
+	_, err := r.Register(&PartitionEvent{}, model.WithTimePartition("CreatedAt", model.PartitionMonthly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestSelector_Partition(t *testing.T) {
+	db := MemoryDB(t, DBWithRegistry(partitionRegistry(t)))
+	sep, oct, nov := day(2026, time.September, 10), day(2026, time.October, 1), day(2026, time.November, 30)
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "eq",
+			q:    NewSelector[PartitionEvent](db).Where(C("CreatedAt").EQ(sep)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `partition_event_202609` WHERE `created_at` = ?;",
+				Args: []any{sep},
+			},
+		},
+		{
+			name: "range in one month",
+			q: NewSelector[PartitionEvent](db).
+				Where(C("CreatedAt").GTE(sep), C("CreatedAt").LT(oct), C("Name").EQ("login")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `partition_event_202609` WHERE ((`created_at` >= ?) AND (`created_at` < ?)) AND (`name` = ?);",
+				Args: []any{sep, oct, "login"},
+			},
+		},
+		{
+			name: "union all",
+			q: NewSelector[PartitionEvent](db).Select(C("Name"), Count("Id").As("id")).
+				Where(C("CreatedAt").GT(sep).And(C("CreatedAt").LTE(nov))).
+				GroupBy(C("Name")).OrderBy(Desc("Id")).Limit(10),
+			wantQuery: &Query{
+				SQL: "SELECT `name`,COUNT(`id`) AS `id` FROM (" +
+					"SELECT * FROM `partition_event_202609` WHERE (`created_at` > ?) AND (`created_at` <= ?) UNION ALL " +
+					"SELECT * FROM `partition_event_202610` WHERE (`created_at` > ?) AND (`created_at` <= ?) UNION ALL " +
+					"SELECT * FROM `partition_event_202611` WHERE (`created_at` > ?) AND (`created_at` <= ?)" +
+					") AS `partition_event` GROUP BY `name` ORDER BY `id` DESC LIMIT ?;",
+				Args: []any{sep, nov, sep, nov, sep, nov, 10},
+			},
+		},
+		{
+			name: "in with alias",
+			q: func() QueryBuilder {
+				tbl := TableOf(&PartitionEvent{}).As("e")
+				return NewSelector[PartitionEvent](db).From(tbl).Where(tbl.C("CreatedAt").In(nov, sep))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (" +
+					"SELECT * FROM `partition_event_202609` AS `e` WHERE `e`.`created_at` IN (?,?) UNION ALL " +
+					"SELECT * FROM `partition_event_202611` AS `e` WHERE `e`.`created_at` IN (?,?)" +
+					") AS `e`;",
+				Args: []any{nov, sep, nov, sep},
+			},
+		},
+		{
+			name: "or",
+			q: NewSelector[PartitionEvent](db).
+				Where(C("CreatedAt").EQ(sep).Or(C("CreatedAt").EQ(nov)), C("CreatedAt").LT(oct)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `partition_event_202609` WHERE ((`created_at` = ?) OR (`created_at` = ?)) AND (`created_at` < ?);",
+				Args: []any{sep, nov, oct},
+			},
+		},
+		{
+			name:    "unbounded",
+			q:       NewSelector[PartitionEvent](db).Where(C("CreatedAt").GTE(sep)),
+			wantErr: errs.NewErrPartitionUnbounded("CreatedAt"),
+		},
+		{
+			name:    "no where",
+			q:       NewSelector[PartitionEvent](db),
+			wantErr: errs.NewErrPartitionUnbounded("CreatedAt"),
+		},
+		{
+			name:    "no partition",
+			q:       NewSelector[PartitionEvent](db).Where(C("CreatedAt").GT(oct), C("CreatedAt").LT(sep)),
+			wantErr: errs.NewErrNoPartition("CreatedAt"),
+		},
+		{
+			name:    "invalid type",
+			q:       NewSelector[PartitionEvent](db).Where(C("CreatedAt").EQ("2026-09-10")),
+			wantErr: errs.NewErrPartitionKeyType("CreatedAt", "2026-09-10"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestPartition_Exec(t *testing.T) {
+	var ddl []string
+	db := MemoryDB(t, DBWithDialect(SQLite3), DBWithRegistry(partitionRegistry(t)),
+		DBWithMiddleware(func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				if qc.Type == TypeDDL {
+					q, _ := qc.Builder.Build()
+					ddl = append(ddl, q.SQL)
+				}
+				return next(ctx, qc)
+			}
+		}))
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	if _, err := db.db.Exec(PartitionEvent{}.CreateSQL()); err != nil {
+		t.Fatal(err)
+	}
+
+	res := NewInserter[PartitionEvent](db).Values(
+		&PartitionEvent{Name: "login", CreatedAt: day(2026, time.September, 10)},
+		&PartitionEvent{Name: "logout", CreatedAt: day(2026, time.November, 2)},
+		&PartitionEvent{Name: "login", CreatedAt: day(2026, time.September, 20)},
+	).Exec(ctx)
+	assert.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	// 已经创建过的表不会重复创建
+	res = NewInserter[PartitionEvent](db).Values(
+		&PartitionEvent{Name: "logout", CreatedAt: day(2026, time.September, 30)},
+	).Exec(ctx)
+	assert.NoError(t, res.Err())
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `partition_event_202609`(\n    id INTEGER PRIMARY KEY,\n    name TEXT NOT NULL,\n    created_at DATETIME NOT NULL\n);",
+		"CREATE TABLE IF NOT EXISTS `partition_event_202611`(\n    id INTEGER PRIMARY KEY,\n    name TEXT NOT NULL,\n    created_at DATETIME NOT NULL\n);",
+	}, ddl)
+
+	names := func(rows []*PartitionEvent) []string {
+		res := make([]string, 0, len(rows))
+		for _, row := range rows {
+			res = append(res, row.Name)
+		}
+		return res
+	}
+	// 10 月的表不存在，查询之前先创建
+	from, to := day(2026, time.September, 1), day(2026, time.December, 1)
+	err = CreatePartitions(ctx, db, &PartitionEvent{}, from, to.Add(-time.Nanosecond))
+	assert.NoError(t, err)
+	rows, err := NewSelector[PartitionEvent](db).
+		Where(C("CreatedAt").GTE(from), C("CreatedAt").LT(to)).
+		OrderBy(Asc("CreatedAt")).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"login", "login", "logout", "logout"}, names(rows))
+
+	row, err := NewSelector[PartitionEvent](db).Where(C("CreatedAt").EQ(day(2026, time.November, 2))).Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "logout", row.Name)
+
+	res = NewUpdater[PartitionEvent](db).Set(Assign("Name", "signin")).
+		Where(C("CreatedAt").GTE(from), C("CreatedAt").LT(to), C("Name").EQ("login")).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	res = NewDeleter[PartitionEvent](db).
+		Where(C("CreatedAt").In(day(2026, time.September, 30), day(2026, time.November, 2))).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	rows, err = NewSelector[PartitionEvent](db).
+		Where(C("CreatedAt").GTE(from), C("CreatedAt").LT(to)).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"signin", "signin"}, names(rows))
+
+	res = NewUpdater[PartitionEvent](db).Set(Assign("Name", "signin")).AllowFullTable().Exec(ctx)
+	assert.Equal(t, errs.NewErrPartitionUnbounded("CreatedAt"), res.Err())
+	res = NewInserter[PartitionEvent](db).Maps(map[string]any{"Name": "login"}).Exec(ctx)
+	assert.Equal(t, errs.NewErrPartitionUnsupported("使用 Maps 和 FromSelect 插入"), res.Err())
+	err = CreatePartitions(ctx, db, &TestModel{}, from, to)
+	assert.Equal(t, errs.NewErrNotPartitioned(&TestModel{}), err)
+}
+
+func TestPartition_Tx(t *testing.T) {
+	db := MemoryDB(t, DBWithDialect(SQLite3), DBWithRegistry(partitionRegistry(t)))
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	if _, err := db.db.Exec(PartitionEvent{}.CreateSQL()); err != nil {
+		t.Fatal(err)
+	}
+	created := day(2025, time.March, 3)
+
+	// 回滚会撤销建表，之后插入的时候需要重新创建
+	err := db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		res := NewInserter[PartitionEvent](tx).Values(&PartitionEvent{Name: "login", CreatedAt: created}).Exec(ctx)
+		assert.NoError(t, res.Err())
+		return errs.ErrNoRows
+	}, nil)
+	assert.Equal(t, errs.ErrNoRows, err)
+	res := NewInserter[PartitionEvent](db).Values(&PartitionEvent{Name: "logout", CreatedAt: created}).Exec(ctx)
+	assert.NoError(t, res.Err())
+
+	rows, err := NewSelector[PartitionEvent](db).Where(C("CreatedAt").EQ(created)).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "logout", rows[0].Name)
+}
+
+func TestPartition_ExecByValues(t *testing.T) {
+	db, err := Open("sqlite3", "file:partition_values.db?cache=shared&mode=memory",
+		DBWithDialect(SQLite3), DBWithRegistry(partitionRegistry(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	if _, err = db.db.Exec(PartitionEvent{}.CreateSQL()); err != nil {
+		t.Fatal(err)
+	}
+	sep, nov := day(2026, time.September, 10), day(2026, time.November, 2)
+	// 每张分表的主键是独立生成的，所以两行的 Id 都是 1
+	res := NewInserter[PartitionEvent](db).Values(
+		&PartitionEvent{Name: "login", CreatedAt: sep},
+		&PartitionEvent{Name: "login", CreatedAt: nov},
+	).Exec(ctx)
+	assert.NoError(t, res.Err())
+	name := func(created time.Time) string {
+		row, err := NewSelector[PartitionEvent](db).Where(C("CreatedAt").EQ(created)).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row.Name
+	}
+
+	res = NewBatchUpdater[PartitionEvent](db).Set("Name").Values(
+		&PartitionEvent{Id: 1, Name: "signin", CreatedAt: sep},
+		&PartitionEvent{Id: 1, Name: "logout", CreatedAt: nov},
+	).Exec(ctx)
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, []string{"signin", "logout"}, []string{name(sep), name(nov)})
+
+	// 没有 WHERE 条件，根据结构体里面的 CreatedAt 找到分表
+	res = NewUpdater[PartitionEvent](db).
+		UpdateNonZero(&PartitionEvent{Id: 1, Name: "signout", CreatedAt: nov}).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	assert.Equal(t, []string{"signin", "signout"}, []string{name(sep), name(nov)})
+
+	// WHERE 条件和结构体里面的 CreatedAt 不在同一张分表
+	res = NewUpdater[PartitionEvent](db).UpdateNonZero(&PartitionEvent{Id: 1, Name: "login", CreatedAt: nov}).
+		Where(C("CreatedAt").EQ(sep)).Exec(ctx)
+	assert.Equal(t, errs.NewErrNoPartition("CreatedAt"), res.Err())
+
+	// CreatedAt 是零值，对应的分表不存在
+	res = NewBatchUpdater[PartitionEvent](db).Set("Name").Values(&PartitionEvent{Id: 1, Name: "login"}).Exec(ctx)
+	assert.Error(t, res.Err())
+	assert.Equal(t, []string{"signin", "signout"}, []string{name(sep), name(nov)})
+
+	_, err = NewBulkLoader[PartitionEvent](db).Load(ctx, func(yield func(*PartitionEvent) bool) {
+		yield(&PartitionEvent{Name: "login", CreatedAt: sep})
+	})
+	assert.Equal(t, errs.NewErrPartitionUnsupported("使用 BulkLoader 导入"), err)
+}
@@ -8,6 +8,8 @@ const (
 	opEQ    = "="
 	opLT    = "<"
 	opGT    = ">"
+	opLTE   = "<="
+	opGTE   = ">="
 	opIN    = "IN"
 	opExist = "EXIST"
 	opAND   = "AND"
@@ -15,6 +17,17 @@ const (
 	opNOT   = "NOT"
 	opAdd   = "+"
 	opMulti = "*"
+	opSub   = "-"
+	opDiv   = "/"
+	opMod   = "%"
+	// 位运算
+	opBitAnd     = "&"
+	opBitOr      = "|"
+	opBitXor     = "^"
+	opLeftShift  = "<<"
+	opRightShift = ">>"
+	// opConcat 字符串拼接，MySQL 里面会被翻译成 CONCAT 函数
+	opConcat = "||"
 )
 
 func (o op) String() string {
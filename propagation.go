@@ -0,0 +1,90 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/xzhHas/sorm/internal/errs"
+)
+
+// Propagation 事务的传播方式，决定了 context 里面已经有事务的时候如何处理
+type Propagation int
+
+const (
+	// PropagationRequired 有事务就加入，没有就开启一个新事务
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew 总是开启一个新事务，新事务和外层的事务互不影响
+	// 注意新事务会占用另外一个连接
+	PropagationRequiresNew
+	// PropagationSupports 有事务就加入，没有就不使用事务
+	PropagationSupports
+	// PropagationNever 不使用事务，有事务的时候返回 ErrTxExists
+	PropagationNever
+	// PropagationNested 有事务的时候创建一个保存点，fn 失败只会回滚到保存点；没有事务的时候和 PropagationRequired 一样
+	PropagationNested
+)
+
+// txKey context 里面存放事务的 key
+type txKey struct{}
+
+// contextWithTx 返回带有事务 tx 的 context
+func contextWithTx(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// txFromContext 返回 ctx 里面属于 db 并且还没有结束的事务
+func (db *DB) txFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*Tx)
+	if !ok || tx.db != db || tx.done {
+		return nil, false
+	}
+	return tx, true
+}
+
+// Session 返回 ctx 里面的事务，没有事务的时候返回 db 本身
+// 这样仓储层只需要传递 context，不需要关心调用者有没有开启事务，例如：
+//
+//	func (r *UserRepo) Create(ctx context.Context, u *User) error {
+//		return sorm.NewInserter[User](r.db.Session(ctx)).Values(u).Exec(ctx).Err()
+//	}
+func (db *DB) Session(ctx context.Context) Session {
+	if tx, ok := db.txFromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// DoTxWithPropagation 按照传播方式 p 执行 fn
+// fn 里面通过 Session(ctx) 拿到当前的事务，不使用事务的时候拿到的是 db 本身。
+// 开启了新事务或者创建了保存点的时候，fn 返回错误或者发生 panic 会回滚，否则提交；
+// 加入外层事务的时候，fn 的错误直接返回，由外层决定是否回滚。
+// opts 只在开启新事务的时候生效
+func (db *DB) DoTxWithPropagation(ctx context.Context, p Propagation,
+	fn func(ctx context.Context) error, opts *sql.TxOptions) error {
+	tx, inTx := db.txFromContext(ctx)
+	newTx := func(ctx context.Context, _ *Tx) error {
+		return fn(ctx)
+	}
+	switch p {
+	case PropagationRequired:
+		if inTx {
+			return fn(ctx)
+		}
+		return db.DoTx(ctx, newTx, opts)
+	case PropagationRequiresNew:
+		return db.DoTx(ctx, newTx, opts)
+	case PropagationSupports:
+		return fn(ctx)
+	case PropagationNever:
+		if inTx {
+			return errs.ErrTxExists
+		}
+		return fn(ctx)
+	case PropagationNested:
+		if inTx {
+			return tx.DoTx(ctx, newTx)
+		}
+		return db.DoTx(ctx, newTx, opts)
+	default:
+		return errs.NewErrUnknownPropagation(int(p))
+	}
+}
@@ -0,0 +1,197 @@
+package sorm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+)
+
+func TestDB_DoTxWithPropagation(t *testing.T) {
+	testErr := errors.New("test error")
+	deleteSQL := "DELETE FROM `test_model` WHERE `id` = ?;"
+	testCases := []struct {
+		name string
+		// outer 为 true 的时候在外层事务里面执行
+		outer   bool
+		p       Propagation
+		mock    func(mock sqlmock.Sqlmock)
+		fnErr   error
+		wantErr error
+	}{
+		{
+			name: "required new",
+			p:    PropagationRequired,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "required rollback",
+			p:    PropagationRequired,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectRollback()
+			},
+			fnErr:   testErr,
+			wantErr: testErr,
+		},
+		{
+			// 加入外层事务，由外层提交
+			name:  "required join",
+			outer: true,
+			p:     PropagationRequired,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:  "requires new",
+			outer: true,
+			p:     PropagationRequiresNew,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "supports without tx",
+			p:    PropagationSupports,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:  "supports join",
+			outer: true,
+			p:     PropagationSupports,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "never",
+			p:    PropagationNever,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			// fn 没有执行，外层事务回滚
+			name:  "never in tx",
+			outer: true,
+			p:     PropagationNever,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			wantErr: errs.ErrTxExists,
+		},
+		{
+			name: "nested without tx",
+			p:    PropagationNested,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:  "nested",
+			outer: true,
+			p:     PropagationNested,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			fnErr:   testErr,
+			wantErr: testErr,
+		},
+		{
+			name:    "unknown",
+			p:       Propagation(100),
+			mock:    func(mock sqlmock.Sqlmock) {},
+			wantErr: errs.NewErrUnknownPropagation(100),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.mock(mock)
+			fn := func(ctx context.Context) error {
+				err := NewDeleter[TestModel](db.Session(ctx)).Where(C("Id").EQ(1)).Exec(ctx).Err()
+				if err != nil {
+					return err
+				}
+				return tc.fnErr
+			}
+			ctx := context.Background()
+			if tc.outer {
+				err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+					return db.DoTxWithPropagation(ctx, tc.p, fn, nil)
+				}, nil)
+			} else {
+				err = db.DoTxWithPropagation(ctx, tc.p, fn, nil)
+			}
+			assert.Equal(t, tc.wantErr, err)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDB_Session(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDB, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	assert.Equal(t, db, db.Session(ctx))
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	var txCtx context.Context
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		txCtx = ctx
+		assert.Equal(t, tx, db.Session(ctx))
+		// 别的 DB 的事务不会被使用
+		assert.Equal(t, otherDB, otherDB.Session(ctx))
+		return nil
+	}, nil)
+	assert.Nil(t, err)
+	// 事务结束之后不会再被使用
+	assert.Equal(t, db, db.Session(txCtx))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package sorm
 
 import (
 	"context"
+	"github.com/xzhHas/sorm/internal/errs"
 )
 
 var _ Querier[any] = &RawQuerier[any]{}
@@ -9,25 +10,34 @@ var _ Querier[any] = &RawQuerier[any]{}
 // RawQuerier 原生查询器
 type RawQuerier[T any] struct {
 	core
-	sess session
+	sess Session
 	sql  string
 	args []any
+	// useReplica 为 true 的时候 Get 和 GetMulti 可能会在从库上执行
+	useReplica bool
 }
 
 // Exec 执行原生 SQL 语句
 func (r *RawQuerier[T]) Exec(ctx context.Context) Result {
-	return exec(ctx, r.sess, r.core, &QueryContext{
-		Builder: r,
-		Type:    "RAW",
-	})
+	return exec(ctx, r.sess, r.core, newQueryContext[T](r.core, TypeRaw, r))
+}
+
+// UseReplica 允许在从库上执行 Get 和 GetMulti，参考 DBWithReplicas
+// 原生 SQL 可能是 INSERT ... RETURNING、SELECT ... FOR UPDATE 之类必须在主库上执行的语句，
+// 所以默认在主库上执行，确认是只读查询的时候才调用它
+func (r *RawQuerier[T]) UseReplica() *RawQuerier[T] {
+	r.useReplica = true
+	return r
+}
+
+// readOnly 是否可以在从库上执行
+func (r *RawQuerier[T]) readOnly() bool {
+	return r.useReplica
 }
 
 // Get 获取单条记录
 func (r *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
-	res := get[T](ctx, r.core, r.sess, &QueryContext{
-		Builder: r,
-		Type:    "RAW",
-	})
+	res := get[T](ctx, r.core, r.sess, newQueryContext[T](r.core, TypeRaw, r))
 	if res.Result != nil {
 		return res.Result.(*T), res.Err
 	}
@@ -36,26 +46,62 @@ func (r *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
 
 // GetMulti 获取多条记录
 func (r *RawQuerier[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	// TODO implement me
-	panic("implement me")
+	res := getMulti[T](ctx, r.core, r.sess, newQueryContext[T](r.core, TypeRaw, r))
+	if res.Result != nil {
+		return res.Result.([]*T), res.Err
+	}
+	return nil, res.Err
 }
 
 // Build 构建 SQL 查询语句
+// 使用命名参数的时候，把 :name 替换成占位符，并且按照顺序生成参数
 func (r *RawQuerier[T]) Build() (*Query, error) {
-	return &Query{
-		SQL:  r.sql,
-		Args: r.args,
-	}, nil
+	src := namedSource(r.args)
+	if src == nil {
+		return &Query{
+			SQL:  r.sql,
+			Args: r.args,
+		}, nil
+	}
+	var lookup namedLookup
+	if named, ok := src.(Named); ok {
+		lookup = func(name string) (any, error) {
+			val, ok := named[name]
+			if !ok {
+				return nil, errs.NewErrMissingNamedArg(name)
+			}
+			return val, nil
+		}
+	} else {
+		var err error
+		if lookup, err = r.structLookup(src); err != nil {
+			return nil, err
+		}
+	}
+	q, err := bindNamed(r.dialect, r.sql, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := src.(Named); !ok && len(q.Args) == 0 {
+		// 没有命名参数，结构体本身就是一个普通的参数
+		return &Query{
+			SQL:  r.sql,
+			Args: r.args,
+		}, nil
+	}
+	return q, nil
 }
 
 // RawQuery 创建一个 RawQuerier 实例
 // 泛型参数 T 是目标类型
 // 例如，如果查询 User 的数据，那么 T 就是 User
-func RawQuery[T any](sess session, sql string, args ...any) *RawQuerier[T] {
+// 如果 args 只有一个，并且是 Named 或者结构体，那么使用命名参数，例如 :id，
+// 结构体按照字段名或者列名匹配参数，切片会被展开，用于 IN (:ids)
+func RawQuery[T any](sess Session, sql string, args ...any) *RawQuerier[T] {
 	return &RawQuerier[T]{
 		sql:  sql,
 		args: args,
-		core: sess.getCore(),
+		core: coreOf(sess),
 		sess: sess,
 	}
 }
@@ -0,0 +1,154 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+)
+
+func TestRawQuerier_Build(t *testing.T) {
+	db := MemoryDB(t)
+	sqliteDB := MemoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name    string
+		q       QueryBuilder
+		want    *Query
+		wantErr error
+	}{
+		{
+			name: "positional",
+			q:    RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = ?", 1),
+			want: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ?",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "named",
+			q: RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = :id AND `age` IN (:ages) OR `id` = :id",
+				Named{"id": 1, "ages": []int{18, 20}}),
+			want: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ? AND `age` IN (?,?) OR `id` = ?",
+				Args: []any{1, 18, 20, 1},
+			},
+		},
+		{
+			// 字符串、带引号的标识符、注释和 :: 里面的内容不会被替换
+			name: "skip quoted and comments",
+			q: RawQuery[TestModel](db, "SELECT ':id', \"a\\\":id\", `:id`, 'it''s :id' -- :id\n"+
+				"/* :id */ FROM `test_model` WHERE `id` = :id AND `age`::int > 0",
+				Named{"id": 1}),
+			want: &Query{
+				SQL: "SELECT ':id', \"a\\\":id\", `:id`, 'it''s :id' -- :id\n" +
+					"/* :id */ FROM `test_model` WHERE `id` = ? AND `age`::int > 0",
+				Args: []any{1},
+			},
+		},
+		{
+			// SQLite 里面反斜杠不是转义字符，'C:\' 之后的 :name 是命名参数
+			name: "sqlite backslash",
+			q: RawQuery[TestModel](sqliteDB, "SELECT * FROM `test_model` WHERE `last_name` = 'C:\\' AND `first_name` = :name",
+				Named{"name": "Tom"}),
+			want: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `last_name` = 'C:\\' AND `first_name` = ?",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			// MySQL 里面 'C:\' 的引号没有结束
+			name:    "mysql backslash",
+			q:       RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `last_name` = 'C:\\' AND `first_name` = :name", Named{"name": "Tom"}),
+			wantErr: errs.NewErrUnterminatedSQL("'C:\\' AND `first_name` = :name"),
+		},
+		{
+			// []byte 不会被展开
+			name: "bytes",
+			q:    RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `first_name` = :name", Named{"name": []byte("Tom")}),
+			want: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ?",
+				Args: []any{[]byte("Tom")},
+			},
+		},
+		{
+			// 按照字段名或者列名匹配
+			name: "struct",
+			q: RawQuery[TestModel](db, "UPDATE `test_model` SET `age` = :Age WHERE `id` = :id",
+				&TestModel{Id: 12, Age: 18}),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `age` = ? WHERE `id` = ?",
+				Args: []any{int8(18), int64(12)},
+			},
+		},
+		{
+			name: "struct value",
+			q:    RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `first_name` = :first_name", TestModel{FirstName: "Tom"}),
+			want: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ?",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			// 实现了 driver.Valuer 的结构体是普通参数
+			name: "valuer",
+			q:    RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `last_name` = ?", sql.NullString{String: "Tom", Valid: true}),
+			want: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `last_name` = ?",
+				Args: []any{sql.NullString{String: "Tom", Valid: true}},
+			},
+		},
+		{
+			name:    "missing",
+			q:       RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = :id", Named{"Id": 1}),
+			wantErr: errs.NewErrMissingNamedArg("id"),
+		},
+		{
+			name:    "struct missing",
+			q:       RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = :invalid", &TestModel{}),
+			wantErr: errs.NewErrMissingNamedArg("invalid"),
+		},
+		{
+			name:    "empty slice",
+			q:       RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` IN (:ids)", Named{"ids": []int64{}}),
+			wantErr: errs.NewErrEmptySliceArg("ids"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, q)
+		})
+	}
+}
+
+func TestRawQuerier_GetMulti(t *testing.T) {
+	db := memoryDBWithDB("raw_query_get_multi", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES " +
+		"(1, 'Tom', 18, 'Cat'), (2, 'Jerry', 20, 'Mouse'), (3, 'Deng', 30, 'Ming')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	got, err := RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` IN (:ids) ORDER BY `id`",
+		Named{"ids": []int64{1, 3, 4}}).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 1, FirstName: "Tom", Age: 18, LastName: &sql.NullString{String: "Cat", Valid: true}},
+		{Id: 3, FirstName: "Deng", Age: 30, LastName: &sql.NullString{String: "Ming", Valid: true}},
+	}, got)
+
+	got, err = RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `age` > ?", 100).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, got)
+}
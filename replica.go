@@ -0,0 +1,292 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica 从库
+type Replica struct {
+	db       *sql.DB
+	inFlight atomic.Int64
+	// unhealthy 健康检查失败或者连接出错的时候为 true，这时候不会被选中
+	unhealthy atomic.Bool
+	// failedAt 最近一次被标记为不可用或者试探的时间，UnixNano
+	failedAt atomic.Int64
+}
+
+// DB 返回从库的连接池
+func (r *Replica) DB() *sql.DB {
+	return r.db
+}
+
+// InFlight 返回正在从库上执行的查询数量
+// 只统计执行查询的过程，不包括读取结果集的过程
+func (r *Replica) InFlight() int64 {
+	return r.inFlight.Load()
+}
+
+// Healthy 从库是否可用
+func (r *Replica) Healthy() bool {
+	return !r.unhealthy.Load()
+}
+
+// setHealthy 更新从库的状态，不可用的时候记录下时间，用于判断什么时候重新试探
+func (r *Replica) setHealthy(healthy bool) {
+	if !healthy {
+		r.failedAt.Store(time.Now().UnixNano())
+	}
+	r.unhealthy.Store(!healthy)
+}
+
+// probe 从库不可用超过 cooldown 之后 Ping 一次，成功的时候恢复为可用
+// 同一时间只有一个查询会去 Ping，其它的查询仍然跳过这个从库，失败之后要再等 cooldown 才会重新试探
+func (r *Replica) probe(ctx context.Context, cooldown time.Duration) bool {
+	failedAt := r.failedAt.Load()
+	now := time.Now()
+	if now.Sub(time.Unix(0, failedAt)) < cooldown || !r.failedAt.CompareAndSwap(failedAt, now.UnixNano()) {
+		return false
+	}
+	if r.db.PingContext(ctx) != nil {
+		return false
+	}
+	r.unhealthy.Store(false)
+	return true
+}
+
+// queryContext 在从库上执行查询，并且统计正在执行的查询数量
+func (r *Replica) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	return r.db.QueryContext(ctx, query, args...)
+}
+
+// LoadBalancer 从库的负载均衡策略
+type LoadBalancer interface {
+	// Pick 从可用的从库里面选择一个，replicas 不会为空
+	Pick(replicas []*Replica) *Replica
+}
+
+// NewRoundRobinBalancer 轮询
+func NewRoundRobinBalancer() LoadBalancer {
+	return &roundRobinBalancer{}
+}
+
+type roundRobinBalancer struct {
+	cnt atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Pick(replicas []*Replica) *Replica {
+	idx := (b.cnt.Add(1) - 1) % uint64(len(replicas))
+	return replicas[idx]
+}
+
+// NewRandomBalancer 随机
+func NewRandomBalancer() LoadBalancer {
+	return randomBalancer{}
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) Pick(replicas []*Replica) *Replica {
+	return replicas[rand.IntN(len(replicas))]
+}
+
+// NewLeastInFlightBalancer 选择正在执行的查询最少的从库，一样多的时候选择靠前的
+func NewLeastInFlightBalancer() LoadBalancer {
+	return leastInFlightBalancer{}
+}
+
+type leastInFlightBalancer struct{}
+
+func (leastInFlightBalancer) Pick(replicas []*Replica) *Replica {
+	res := replicas[0]
+	for _, r := range replicas[1:] {
+		if r.InFlight() < res.InFlight() {
+			res = r
+		}
+	}
+	return res
+}
+
+// defaultReplicaCooldown 不可用的从库经过多久之后重新试探
+const defaultReplicaCooldown = 5 * time.Second
+
+// replicaSet DB 的从库
+type replicaSet struct {
+	lb       LoadBalancer
+	replicas []*Replica
+	// interval 健康检查的间隔，0 表示不定时检查
+	interval time.Duration
+	// cooldown 不可用的从库经过多久之后，在选择从库的时候重新试探，0 表示只依赖健康检查恢复
+	cooldown  time.Duration
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// pick 选择一个可用的从库，都不可用的时候返回 nil
+// 不可用超过 cooldown 的从库会先 Ping 一次，成功之后重新参与选择
+func (s *replicaSet) pick(ctx context.Context) *Replica {
+	healthy := make([]*Replica, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if r.Healthy() || s.cooldown > 0 && r.probe(ctx, s.cooldown) {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return s.lb.Pick(healthy)
+}
+
+// DBWithReplicas 设置从库，Open 或者 OpenDB 传入的是主库
+// Selector 和调用了 UseReplica 的 RawQuerier 的 Get、GetMulti 会按照 lb 选择一个从库执行，lb 为 nil 的时候使用轮询。
+// 其它的语句、事务里面的查询、调用了 UsePrimary 或者 context 经过 ForcePrimary 处理的查询都在主库上执行。
+// 从库都不可用的时候，查询会在主库上执行。
+// 连接出错的从库会被标记为不可用，5 秒之后选择从库的时候会 Ping 一次，成功之后恢复；
+// 也可以通过 DBWithReplicaHealthCheck 定时检查。Close 的时候也会关闭从库
+func DBWithReplicas(lb LoadBalancer, replicas ...*sql.DB) DBOption {
+	return func(db *DB) {
+		if lb == nil {
+			lb = NewRoundRobinBalancer()
+		}
+		rs := make([]*Replica, 0, len(replicas))
+		for _, r := range replicas {
+			rs = append(rs, &Replica{db: r})
+		}
+		interval := time.Duration(0)
+		if db.replicas != nil {
+			interval = db.replicas.interval
+		}
+		db.replicas = &replicaSet{lb: lb, replicas: rs, interval: interval,
+			cooldown: defaultReplicaCooldown, stop: make(chan struct{})}
+	}
+}
+
+// DBWithReplicaHealthCheck 每隔 interval 检查一次从库是否可用，需要和 DBWithReplicas 一起使用
+// 检查失败的从库不会再被选中，直到下一次检查成功，或者在选择从库的时候试探成功
+func DBWithReplicaHealthCheck(interval time.Duration) DBOption {
+	return func(db *DB) {
+		if db.replicas == nil {
+			db.replicas = &replicaSet{lb: NewRoundRobinBalancer(), cooldown: defaultReplicaCooldown, stop: make(chan struct{})}
+		}
+		db.replicas.interval = interval
+	}
+}
+
+// primaryKey context 里面强制使用主库的 key
+type primaryKey struct{}
+
+// ForcePrimary 返回的 context 里面的查询都在主库上执行，用于刚写入就要读取之类的场景
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// isForcePrimary 判断是否强制使用主库
+func isForcePrimary(ctx context.Context) bool {
+	force, _ := ctx.Value(primaryKey{}).(bool)
+	return force
+}
+
+// readOnlyQuery 声明自己是只读查询，可以在从库上执行的原生查询
+type readOnlyQuery interface {
+	readOnly() bool
+}
+
+// queryRead 执行读取数据的查询，只有 DB 上的 SELECT 和调用了 UseReplica 的原生查询可能会在从库上执行
+// 嵌入了 *DB 的自定义会话覆盖了 QueryContext，所以还是使用它们自己的 QueryContext
+func queryRead(ctx context.Context, sess Session, qc *QueryContext, q *Query) (*sql.Rows, error) {
+	if db, ok := sess.(*DB); ok && (qc.Type == TypeSelect || qc.Type == TypeRaw && isReadOnly(qc.Builder)) {
+		return db.readContext(ctx, q.SQL, q.Args...)
+	}
+	return sess.QueryContext(ctx, q.SQL, q.Args...)
+}
+
+// isReadOnly 判断原生查询是否可以在从库上执行
+func isReadOnly(b QueryBuilder) bool {
+	ro, ok := b.(readOnlyQuery)
+	return ok && ro.readOnly()
+}
+
+// readContext 选择一个从库执行查询，从库连接出错的时候标记为不可用，然后在主库上重新执行
+func (db *DB) readContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if db.replicas == nil || isForcePrimary(ctx) {
+		return db.db.QueryContext(ctx, query, args...)
+	}
+	r := db.replicas.pick(ctx)
+	if r == nil {
+		return db.db.QueryContext(ctx, query, args...)
+	}
+	rows, err := r.queryContext(ctx, query, args...)
+	if err != nil && isConnError(err) {
+		r.setHealthy(false)
+		return db.db.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// isConnError 判断是不是连接出错，而不是 SQL 本身的错误
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr)
+}
+
+// Replicas 返回所有的从库，可以用于监控
+func (db *DB) Replicas() []*Replica {
+	if db.replicas == nil {
+		return nil
+	}
+	return db.replicas.replicas
+}
+
+// CheckReplicas 检查所有从库是否可用，并且更新它们的状态
+func (db *DB) CheckReplicas(ctx context.Context) {
+	for _, r := range db.Replicas() {
+		r.setHealthy(r.db.PingContext(ctx) == nil)
+	}
+}
+
+// startHealthCheck 定时检查从库，Close 的时候停止
+func (db *DB) startHealthCheck() {
+	rs := db.replicas
+	if rs == nil || rs.interval <= 0 || len(rs.replicas) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(rs.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rs.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), rs.interval)
+				db.CheckReplicas(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// closeReplicas 停止健康检查并且关闭从库
+func (db *DB) closeReplicas() error {
+	rs := db.replicas
+	if rs == nil {
+		return nil
+	}
+	var err error
+	rs.closeOnce.Do(func() {
+		close(rs.stop)
+		for _, r := range rs.replicas {
+			err = errors.Join(err, r.db.Close())
+		}
+	})
+	return err
+}
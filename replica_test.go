@@ -0,0 +1,222 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// openReplicaTestDB 打开一个 SQLite 数据库，里面只有一行数据，FirstName 是数据库的名字
+func openReplicaTestDB(t *testing.T, name string) *sql.DB {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s.db?cache=shared&mode=memory", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES (1, ?, 18, 'Cat')", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDB_Replicas(t *testing.T) {
+	primary := openReplicaTestDB(t, "replica_primary")
+	replica1 := openReplicaTestDB(t, "replica_1")
+	replica2 := openReplicaTestDB(t, "replica_2")
+	db, err := OpenDB(primary, DBWithDialect(SQLite3), DBWithReplicas(nil, replica1, replica2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	name := func(q interface {
+		Get(ctx context.Context) (*TestModel, error)
+	}, ctx context.Context) string {
+		res, err := q.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.FirstName
+	}
+
+	// 默认轮询
+	assert.Equal(t, "replica_1", name(NewSelector[TestModel](db), ctx))
+	assert.Equal(t, "replica_2", name(NewSelector[TestModel](db), ctx))
+	assert.Equal(t, "replica_1", name(RawQuery[TestModel](db, "SELECT * FROM `test_model`").UseReplica(), ctx))
+	assert.Equal(t, "replica_2", name(NewSelector[TestModel](db), ctx))
+
+	// 强制使用主库，原生查询默认使用主库
+	assert.Equal(t, "replica_primary", name(NewSelector[TestModel](db).UsePrimary(), ctx))
+	assert.Equal(t, "replica_primary", name(RawQuery[TestModel](db, "SELECT * FROM `test_model`"), ctx))
+	assert.Equal(t, "replica_primary", name(NewSelector[TestModel](db), ForcePrimary(ctx)))
+
+	// 写操作和事务都在主库上
+	res := NewUpdater[TestModel](db).Set(Assign("Age", 20)).Where(C("Id").EQ(1)).Exec(ctx)
+	assert.Nil(t, res.Err())
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		got, err := NewSelector[TestModel](tx).Get(ctx)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "replica_primary", got.FirstName)
+		assert.Equal(t, int8(20), got.Age)
+		return nil
+	}, nil)
+	assert.Nil(t, err)
+	got, err := NewSelector[TestModel](db).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(18), got.Age)
+
+	// 从库不可用的时候跳过
+	_ = replica1.Close()
+	db.CheckReplicas(ctx)
+	assert.False(t, db.Replicas()[0].Healthy())
+	assert.True(t, db.Replicas()[1].Healthy())
+	assert.Equal(t, "replica_2", name(NewSelector[TestModel](db), ctx))
+	assert.Equal(t, "replica_2", name(NewSelector[TestModel](db), ctx))
+
+	// 都不可用的时候使用主库
+	_ = replica2.Close()
+	db.CheckReplicas(ctx)
+	assert.Equal(t, "replica_primary", name(NewSelector[TestModel](db), ctx))
+}
+
+func TestDB_RawQueryOnPrimary(t *testing.T) {
+	primary, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB(primary, DBWithReplicas(nil, replica))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+
+	// 这些语句会修改数据或者加锁，在从库上执行是错误的
+	cols := []string{"id", "first_name", "age", "last_name"}
+	mock.ExpectQuery("UPDATE `test_model` SET `age` = 20 WHERE `id` = 1 RETURNING \\*").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "Tom", 20, "Cat"))
+	mock.ExpectQuery("SELECT \\* FROM `test_model` WHERE `id` = 1 FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "Tom", 20, "Cat"))
+	replicaMock.ExpectQuery("SELECT \\* FROM `test_model`").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "Tom", 18, "Cat"))
+
+	got, err := RawQuery[TestModel](db, "UPDATE `test_model` SET `age` = 20 WHERE `id` = 1 RETURNING *").Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(20), got.Age)
+	rows, err := RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = 1 FOR UPDATE").GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	got, err = RawQuery[TestModel](db, "SELECT * FROM `test_model`").UseReplica().Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(18), got.Age)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Nil(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestDB_ReplicaRecover(t *testing.T) {
+	primary := openReplicaTestDB(t, "recover_primary")
+	replica := openReplicaTestDB(t, "recover_replica")
+	down := openReplicaTestDB(t, "recover_down")
+	db, err := OpenDB(primary, DBWithDialect(SQLite3), DBWithReplicas(nil, replica, down))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	db.replicas.cooldown = 50 * time.Millisecond
+	ctx := context.Background()
+	name := func() string {
+		res, err := NewSelector[TestModel](db).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.FirstName
+	}
+
+	// 连接短暂出错之后被标记为不可用，down 则是真的不可用了
+	_ = down.Close()
+	r, d := db.Replicas()[0], db.Replicas()[1]
+	r.setHealthy(false)
+	d.setHealthy(false)
+	assert.Equal(t, "recover_primary", name())
+
+	// 冷却之后 Ping 成功的从库恢复，失败的继续跳过
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "recover_replica", name())
+	assert.True(t, r.Healthy())
+	assert.False(t, d.Healthy())
+	assert.Equal(t, "recover_replica", name())
+	assert.Equal(t, "recover_replica", name())
+}
+
+func TestLoadBalancer(t *testing.T) {
+	newReplicas := func(inFlight ...int64) []*Replica {
+		res := make([]*Replica, 0, len(inFlight))
+		for _, n := range inFlight {
+			r := &Replica{}
+			r.inFlight.Store(n)
+			res = append(res, r)
+		}
+		return res
+	}
+	testCases := []struct {
+		name     string
+		lb       LoadBalancer
+		replicas []*Replica
+		want     []int
+	}{
+		{
+			name:     "round robin",
+			lb:       NewRoundRobinBalancer(),
+			replicas: newReplicas(0, 0, 0),
+			want:     []int{0, 1, 2, 0},
+		},
+		{
+			name:     "least in flight",
+			lb:       NewLeastInFlightBalancer(),
+			replicas: newReplicas(3, 1, 2, 1),
+			want:     []int{1, 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int
+			for range tc.want {
+				r := tc.lb.Pick(tc.replicas)
+				for idx, candidate := range tc.replicas {
+					if candidate == r {
+						got = append(got, idx)
+					}
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	replicas := newReplicas(0, 0)
+	lb := NewRandomBalancer()
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, replicas, lb.Pick(replicas))
+	}
+}
+
+func TestIsConnError(t *testing.T) {
+	assert.True(t, isConnError(driver.ErrBadConn))
+	assert.True(t, isConnError(fmt.Errorf("query: %w", sql.ErrConnDone)))
+	assert.False(t, isConnError(sql.ErrNoRows))
+}
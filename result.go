@@ -1,11 +1,16 @@
 package sorm
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+)
 
 // Result 结构体表示数据库操作的结果
 type Result struct {
 	err error
 	res sql.Result
+	// chunkErrs 分批执行的时候每一批的错误
+	chunkErrs []*ChunkError
 }
 
 // Err 返回 Result 中的错误信息
@@ -14,17 +19,93 @@ func (r Result) Err() error {
 }
 
 // LastInsertId 返回最后一次插入操作生成的 ID
+// 分批执行的时候返回的是最后一批的 ID。执行出错的时候返回 Err
 func (r Result) LastInsertId() (int64, error) {
-	if r.err != nil {
+	if r.err != nil || r.res == nil {
 		return 0, r.err
 	}
 	return r.res.LastInsertId()
 }
 
 // RowsAffected 返回受影响的行数
+// 分批执行的时候返回的是所有批次的行数之和。
+// 执行出错的时候返回 Err，部分批次失败的时候，可以通过 ChunkErrors 知道哪些批次没有执行成功
 func (r Result) RowsAffected() (int64, error) {
-	if r.err != nil {
+	if r.err != nil || r.res == nil {
 		return 0, r.err
 	}
 	return r.res.RowsAffected()
 }
+
+// ChunkErrors 返回分批执行的时候，执行失败的批次
+// 不是分批执行，或者全部成功的时候返回 nil
+func (r Result) ChunkErrors() []*ChunkError {
+	return r.chunkErrs
+}
+
+// ChunkError 分批执行的时候，某一批的执行错误
+type ChunkError struct {
+	// Index 批次的下标，从 0 开始
+	Index int
+	// Err 这一批的错误
+	Err error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("orm: 第 %d 批执行失败: %v", e.Index, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// batchResult 汇总分批执行的结果
+type batchResult []sql.Result
+
+// LastInsertId 返回最后一批的 ID
+func (b batchResult) LastInsertId() (int64, error) {
+	return b[len(b)-1].LastInsertId()
+}
+
+// RowsAffected 返回每一批受影响行数之和
+func (b batchResult) RowsAffected() (int64, error) {
+	var total int64
+	for _, res := range b {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// returningResult 通过 RETURNING 读取到的结果
+type returningResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r returningResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r returningResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// RowError 批量导入的时候，某一行的错误
+type RowError struct {
+	// Index 行的下标，从 0 开始
+	Index int64
+	// Err 这一行的错误
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("orm: 第 %d 行导入失败: %v", e.Index, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
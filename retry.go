@@ -0,0 +1,101 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/xzhHas/sorm/internal/errs"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy 事务的重试策略
+// DB.DoTx 在 fn、开启事务或者回滚的时候遇到可以重试的错误，会在一个新的事务里面重新执行整个 fn，
+// 所以 fn 里面除了数据库操作之外不应该有其它副作用。
+// 提交失败的时候不会重试，因为这个时候无法确定事务到底有没有提交成功。
+// 嵌套的事务（加入外层事务或者使用保存点）不会单独重试，由最外层的事务负责。
+type RetryPolicy struct {
+	// MaxAttempts 最多执行多少次，包括第一次，小于等于 1 的时候不重试
+	MaxAttempts int
+	// Backoff 第 attempt 次失败之后等待多久，为 nil 的时候使用 ExponentialBackoff(10ms, time.Second)
+	Backoff Backoff
+	// Retryable 判断错误能不能重试，为 nil 的时候使用数据库方言的判断：
+	// MySQL 是死锁（1213）和锁等待超时（1205），SQLite 是 SQLITE_BUSY 和 SQLITE_LOCKED
+	Retryable func(err error) bool
+	// OnRetry 在第 attempt 次失败，准备重试之前调用，可以用来记录日志或者打点
+	OnRetry func(ctx context.Context, attempt int, err error)
+}
+
+// Backoff 根据失败的次数返回下一次重试之前的等待时间，attempt 从 1 开始
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff 指数退避，等待时间从 initial 开始每次翻倍，最多不超过 max。
+// 为了避免多个事务同时重试又一起冲突，实际等待的时间在计算结果的一半到全部之间随机
+func ExponentialBackoff(initial, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := initial
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if d <= 0 {
+			return 0
+		}
+		half := d / 2
+		return half + rand.N(d-half+1)
+	}
+}
+
+// DBWithRetryPolicy 设置 DB.DoTx 的重试策略
+func DBWithRetryPolicy(p RetryPolicy) DBOption {
+	return func(db *DB) {
+		if p.Backoff == nil {
+			p.Backoff = ExponentialBackoff(10*time.Millisecond, time.Second)
+		}
+		db.retry = &p
+	}
+}
+
+// attemptKey context 里面存放当前是第几次执行事务的 key
+type attemptKey struct{}
+
+// TxAttempt 返回当前是第几次执行事务，从 1 开始，在 DoTx 的 fn 里面使用
+// 没有重试策略或者不在事务里面的时候返回 1
+func TxAttempt(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// doTxWithRetry 按照重试策略执行事务，重试之后仍然失败的时候，错误里面带有执行的次数
+func (db *DB) doTxWithRetry(ctx context.Context, begin beginFunc, fn FN, opts *sql.TxOptions) error {
+	p := db.retry
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = db.dialect.retryable
+	}
+	for attempt := 1; ; attempt++ {
+		commitFailed, err := db.doTx(context.WithValue(ctx, attemptKey{}, attempt), begin, fn, opts)
+		if err == nil {
+			return nil
+		}
+		if commitFailed || attempt >= p.MaxAttempts || !retryable(err) {
+			if attempt > 1 {
+				return errs.NewErrTxRetryExhausted(attempt, err)
+			}
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(ctx, attempt, err)
+		}
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errs.NewErrTxRetryExhausted(attempt, err)
+		case <-timer.C:
+		}
+	}
+}
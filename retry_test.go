@@ -0,0 +1,201 @@
+package sorm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"testing"
+	"time"
+)
+
+func TestDB_DoTx_Retry(t *testing.T) {
+	deadlock := errors.New("Error 1213 (40001): Deadlock found when trying to get lock; try restarting transaction")
+	lockWait := errors.New("Error 1205 (HY000): Lock wait timeout exceeded; try restarting transaction")
+	busy := errors.New("database is locked")
+	deleteSQL := "DELETE FROM `test_model` WHERE `id` = ?;"
+	testCases := []struct {
+		name         string
+		dialect      Dialect
+		retryable    func(err error) bool
+		mock         func(mock sqlmock.Sqlmock)
+		wantAttempts []int
+		wantRetries  []error
+		wantErr      error
+	}{
+		{
+			name: "deadlock",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnError(deadlock)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnError(lockWait)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantAttempts: []int{1, 2, 3},
+			wantRetries:  []error{deadlock, lockWait},
+		},
+		{
+			name: "exhausted",
+			mock: func(mock sqlmock.Sqlmock) {
+				for i := 0; i < 3; i++ {
+					mock.ExpectBegin()
+					mock.ExpectExec(deleteSQL).WillReturnError(deadlock)
+					mock.ExpectRollback()
+				}
+			},
+			wantAttempts: []int{1, 2, 3},
+			wantRetries:  []error{deadlock, deadlock},
+			wantErr:      errs.NewErrTxRetryExhausted(3, deadlock),
+		},
+		{
+			name: "not retryable",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnError(errs.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			wantAttempts: []int{1},
+			wantErr:      errs.ErrNoRows,
+		},
+		{
+			// 提交失败的时候不知道事务有没有提交成功，所以不重试
+			name: "commit failed",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(deadlock)
+			},
+			wantAttempts: []int{1},
+			wantErr:      deadlock,
+		},
+		{
+			name:    "sqlite busy",
+			dialect: SQLite3,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(busy)
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantAttempts: []int{2},
+			wantRetries:  []error{busy},
+		},
+		{
+			// SQLite 不认识 MySQL 的错误
+			name:    "sqlite deadlock",
+			dialect: SQLite3,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnError(deadlock)
+				mock.ExpectRollback()
+			},
+			wantAttempts: []int{1},
+			wantErr:      deadlock,
+		},
+		{
+			name: "custom retryable",
+			retryable: func(err error) bool {
+				return errors.Is(err, errs.ErrNoRows)
+			},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnError(errs.ErrNoRows)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantAttempts: []int{1, 2},
+			wantRetries:  []error{errs.ErrNoRows},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			var retries []error
+			db, err := OpenDB(mockDB, DBWithRetryPolicy(RetryPolicy{
+				MaxAttempts: 3,
+				Backoff: func(attempt int) time.Duration {
+					return 0
+				},
+				Retryable: tc.retryable,
+				OnRetry: func(ctx context.Context, attempt int, err error) {
+					assert.Equal(t, len(retries)+1, attempt)
+					retries = append(retries, err)
+				},
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.dialect != nil {
+				db.dialect = tc.dialect
+			}
+			tc.mock(mock)
+			var attempts []int
+			err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+				attempts = append(attempts, TxAttempt(ctx))
+				return NewDeleter[TestModel](tx).Where(C("Id").EQ(1)).Exec(ctx).Err()
+			}, nil)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantAttempts, attempts)
+			assert.Equal(t, tc.wantRetries, retries)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDB_DoTx_RetryCanceled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     ExponentialBackoff(time.Minute, time.Minute),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadlock := errors.New("Error 1213: Deadlock found when trying to get lock")
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// 等待重试的时候 context 超时，不会再执行
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		return deadlock
+	}, nil)
+	assert.Equal(t, errs.NewErrTxRetryExhausted(1, deadlock), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	testCases := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 1, min: 5 * time.Millisecond, max: 10 * time.Millisecond},
+		{attempt: 2, min: 10 * time.Millisecond, max: 20 * time.Millisecond},
+		{attempt: 3, min: 20 * time.Millisecond, max: 40 * time.Millisecond},
+		{attempt: 10, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+	}
+	backoff := ExponentialBackoff(10*time.Millisecond, 100*time.Millisecond)
+	for _, tc := range testCases {
+		for i := 0; i < 10; i++ {
+			d := backoff(tc.attempt)
+			assert.True(t, d >= tc.min && d <= tc.max, "attempt %d: %s", tc.attempt, d)
+		}
+	}
+}
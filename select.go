@@ -14,9 +14,12 @@ type Selector[T any] struct {
 	having  []Predicate
 	columns []Selectable
 	groupBy []Column
+	orderBy []OrderBy
 	offset  int
 	limit   int
-	sess    session
+	sess    Session
+	// usePrimary 为 true 的时候在主库上执行
+	usePrimary bool
 }
 
 // Select 方法用于指定查询操作选择的列
@@ -55,16 +58,32 @@ func (s *Selector[T]) Build() (*Query, error) {
 	}
 	// 指定查询的数据表
 	s.sb.WriteString(" FROM ")
-	if err = s.buildTable(s.table); err != nil {
-		return nil, err
+	// 按照时间分表的模型，根据 WHERE 条件找到物理表
+	var tables []string
+	if m := s.partitionModel(new(T), s.table); m != nil {
+		if tables, err = partitionTables(m, s.where); err != nil {
+			return nil, err
+		}
 	}
-	// 构造 WHERE字句，用于过滤条件
-	if len(s.where) > 0 {
-		// 类似这种可有可无的部分，都要在前面加一个空格
-		s.sb.WriteString(" WHERE ")
-		if err = s.buildPredicates(s.where); err != nil {
+	if len(tables) > 1 {
+		if err = s.buildPartitionUnion(tables); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(tables) == 1 {
+			s.tableName = tables[0]
+		}
+		if err = s.buildTable(s.table); err != nil {
 			return nil, err
 		}
+		// 构造 WHERE字句，用于过滤条件
+		if len(s.where) > 0 {
+			// 类似这种可有可无的部分，都要在前面加一个空格
+			s.sb.WriteString(" WHERE ")
+			if err = s.buildPredicates(s.where); err != nil {
+				return nil, err
+			}
+		}
 	}
 	// 构造 GROUP BY，用于分组
 	if len(s.groupBy) > 0 {
@@ -85,6 +104,12 @@ func (s *Selector[T]) Build() (*Query, error) {
 			return nil, err
 		}
 	}
+	// 构造 ORDER BY，用于排序
+	if len(s.orderBy) > 0 {
+		if err = s.buildOrderBy(s.orderBy); err != nil {
+			return nil, err
+		}
+	}
 	// 添加 LIMIT，限制返回结果的数量
 	if s.limit > 0 {
 		s.sb.WriteString(" LIMIT ?")
@@ -103,75 +128,6 @@ func (s *Selector[T]) Build() (*Query, error) {
 	}, nil
 }
 
-// buildTable 根据给定的表引用构建查询表部分
-// 它处理了不同类型的表引用，并相应地构建查询语句
-func (s *Selector[T]) buildTable(table TableReference) error {
-	switch tab := table.(type) {
-	case nil:
-		s.quote(s.model.TableName)
-	case Table:
-		model, err := s.r.Get(tab.entity)
-		if err != nil {
-			return err
-		}
-		s.quote(model.TableName)
-		if tab.alias != "" {
-			s.sb.WriteString(" AS ")
-			s.quote(tab.alias)
-		}
-	case Join:
-		return s.buildJoin(tab)
-	case Subquery:
-		return s.buildSubquery(tab, true)
-	default:
-		return errs.NewErrUnsupportedExpressionType(tab)
-	}
-	return nil
-}
-
-// buildJoin 构建一个 JOIN 语句
-// tab: Join 类型的参数，包含构建 JOIN 语句所需的所有信息
-func (s *Selector[T]) buildJoin(tab Join) error {
-	s.sb.WriteByte('(')
-	// 构建JOIN的左侧表
-	if err := s.buildTable(tab.left); err != nil {
-		return err
-	}
-	// 添加空格和JOIN类型
-	s.sb.WriteString(" ")
-	s.sb.WriteString(tab.typ)
-	s.sb.WriteString(" ")
-	// 构建JOIN的右侧表
-	if err := s.buildTable(tab.right); err != nil {
-		return err
-	}
-	// 处理 USING 子句
-	if len(tab.using) > 0 {
-		s.sb.WriteString(" USING (")
-		for i, col := range tab.using {
-			if i > 0 {
-				s.sb.WriteByte(',')
-			}
-			// 构建USING子句中的列名
-			err := s.buildColumn(Column{name: col}, false)
-			if err != nil {
-				return err
-			}
-		}
-		s.sb.WriteString(")")
-	}
-	// 如果使用ON关键字，则构建ON子句
-	if len(tab.on) > 0 {
-		s.sb.WriteString(" ON ")
-		err := s.buildPredicates(tab.on)
-		if err != nil {
-			return err
-		}
-	}
-	s.sb.WriteByte(')')
-	return nil
-}
-
 // buildColumns构建查询语句中的列部分
 // 该方法根据Selector结构体中的columns切片来生成查询语句的列部分
 // 切片为空时，会使用通配符*来表示选择所有列。否则，会遍历columns切片中的每个元素
@@ -237,6 +193,12 @@ func (s *Selector[T]) Having(ps ...Predicate) *Selector[T] {
 	return s
 }
 
+// OrderBy 设置排序，例如 OrderBy(Asc("Age"), Desc("Id"))
+func (s *Selector[T]) OrderBy(obs ...OrderBy) *Selector[T] {
+	s.orderBy = obs
+	return s
+}
+
 func (s *Selector[T]) Offset(offset int) *Selector[T] {
 	s.offset = offset
 	return s
@@ -265,15 +227,65 @@ func (s *Selector[T]) AsSubquery(alias string) Subquery {
 	}
 }
 
+// selectedFields 返回查询结果里面每一列的名字，用于 INSERT ... SELECT 校验列
+// 列有别名的时候用别名，否则用字段名；聚合函数和原生表达式没有别名的时候是空字符串。
+// SELECT * 的时候返回对应模型的全部字段，如果无法确定 * 代表哪些列（例如 JOIN），那么返回 nil
+func (s *Selector[T]) selectedFields() ([]string, error) {
+	if len(s.columns) == 0 {
+		var entity any = new(T)
+		switch tab := s.table.(type) {
+		case nil:
+		case Table:
+			entity = tab.entity
+		default:
+			return nil, nil
+		}
+		m, err := s.r.Get(entity)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]string, 0, len(m.Fields))
+		for _, fd := range m.Fields {
+			res = append(res, fd.GoName)
+		}
+		return res, nil
+	}
+	res := make([]string, 0, len(s.columns))
+	for _, c := range s.columns {
+		name := c.selectedAlias()
+		if col, ok := c.(Column); ok && name == "" {
+			name = col.name
+		}
+		res = append(res, name)
+	}
+	return res, nil
+}
+
+// UsePrimary 在主库上执行查询，用于刚写入就要读取之类的场景，参考 DBWithReplicas
+func (s *Selector[T]) UsePrimary() *Selector[T] {
+	s.usePrimary = true
+	return s
+}
+
 // Get 方法用于从数据库中获取特定类型 T 的数据
 // 该方法通过提供的 context.Context 对象来控制请求的取消或超时
 func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
 	// 调用 get 函数执行查询操作，传入上下文对象、core、sess 和查询上下文
 	// 这里使用了一个泛型 T，使得同一个函数可以处理不同类型的查询
-	res := get[T](ctx, s.core, s.sess, &QueryContext{
-		Builder: s,
-		Type:    "SELECT",
-	})
+	if s.usePrimary {
+		ctx = ForcePrimary(ctx)
+	}
+	if sdb := s.sharding; sdb != nil {
+		rows, err := s.shardingGetMulti(ctx, sdb, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, ErrNoRows
+		}
+		return rows[0], nil
+	}
+	res := get[T](ctx, s.core, s.sess, newQueryContext[T](s.core, TypeSelect, s))
 	if res.Result != nil {
 		return res.Result.(*T), res.Err
 	}
@@ -298,10 +310,13 @@ func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
 
 func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
 	// 调用 getMulti 函数执行查询操作，传入上下文对象、core、sess 和查询上下文
-	res := getMulti[T](ctx, s.core, s.sess, &QueryContext{
-		Builder: s,
-		Type:    "SELECT",
-	})
+	if s.usePrimary {
+		ctx = ForcePrimary(ctx)
+	}
+	if sdb := s.sharding; sdb != nil {
+		return s.shardingGetMulti(ctx, sdb, false)
+	}
+	res := getMulti[T](ctx, s.core, s.sess, newQueryContext[T](s.core, TypeSelect, s))
 	if res.Result != nil {
 		return res.Result.([]*T), res.Err
 	}
@@ -311,9 +326,9 @@ func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
 // NewSelector 创建并返回一个新的 Selector 实例
 // - 该函数使用泛型 T 来允许创建任意类型的 Selector 实例
 // - 通过 sess 参数获取数据库操作的核心配置（如连接信息和方言设置）
-// - 返回的 Selector 实例封装了 session 和 builder，提供便捷的数据库查询构建方法
-func NewSelector[T any](sess session) *Selector[T] {
-	c := sess.getCore()
+// - 返回的 Selector 实例封装了 Session 和 builder，提供便捷的数据库查询构建方法
+func NewSelector[T any](sess Session) *Selector[T] {
+	c := coreOf(sess)
 	return &Selector[T]{
 		sess: sess,
 		builder: builder{
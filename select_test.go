@@ -391,6 +391,48 @@ func TestSelector_OffsetLimit(t *testing.T) {
 	}
 }
 
+func TestSelector_OrderBy(t *testing.T) {
+	db := MemoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "order by",
+			q:    NewSelector[TestModel](db).OrderBy(Asc("Age"), Desc("Id")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `age` ASC,`id` DESC;",
+			},
+		},
+		{
+			name: "order by limit",
+			q:    NewSelector[TestModel](db).Where(C("Age").GT(18)).OrderBy(Desc("Age")).Limit(10),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `age` DESC LIMIT ?;",
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).OrderBy(Asc("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
 func TestSelector_Having(t *testing.T) {
 	db := MemoryDB(t)
 	testCases := []struct {
@@ -563,6 +605,33 @@ func TestSelector_Select(t *testing.T) {
 				Args: []any{100},
 			},
 		},
+		{
+			name: "where in",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").In(1, 2, 3)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?,?);",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			// 空的 IN 恒为假
+			name: "where in empty",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").In(), C("Age").GT(18)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (1=0) AND (`age` > ?);",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "where not in empty",
+			q: NewSelector[TestModel](db).
+				Where(Not(C("Id").In())),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE  NOT (1=0);",
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
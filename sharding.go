@@ -0,0 +1,394 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/model"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// ShardingDst 分片的目标，也就是物理库和物理表
+// DB 是 OpenShardingDB 传入的 dbs 里面的名字
+type ShardingDst struct {
+	DB    string
+	Table string
+}
+
+// ShardingAlgorithm 分片算法，决定数据存放在哪个库的哪个表
+type ShardingAlgorithm interface {
+	// ShardingKey 返回分片键，也就是结构体的字段名
+	ShardingKey() string
+	// Shard 返回分片键的值为 val 的数据所在的库和表
+	Shard(val any) (ShardingDst, error)
+	// Broadcast 返回所有的库和表，无法根据条件确定目标的时候会在这些表上执行
+	Broadcast() []ShardingDst
+}
+
+// ShardingPattern 库名或者表名的格式
+type ShardingPattern struct {
+	// Name 名字的格式，例如 order_db_%d，%d 会被替换成从 0 开始的下标；Count 不超过 1 的时候原样使用
+	Name string
+	// Count 库或者表的数量
+	Count int
+}
+
+// count 返回数量，没有设置的时候是 1
+func (p ShardingPattern) count() int {
+	if p.Count < 1 {
+		return 1
+	}
+	return p.Count
+}
+
+// name 返回第 idx 个库或者表的名字
+func (p ShardingPattern) name(idx int) string {
+	if p.Count <= 1 {
+		return p.Name
+	}
+	return fmt.Sprintf(p.Name, idx)
+}
+
+// patternDst 把 n 映射到 dbs × tables 个表里面，先按照库排列，再按照表排列
+// 例如 16 个库，每个库 32 张表的时候，0~31 在第一个库，32~63 在第二个库
+func patternDst(dbs, tables ShardingPattern, n uint64) ShardingDst {
+	idx := n % uint64(dbs.count()*tables.count())
+	return ShardingDst{
+		DB:    dbs.name(int(idx / uint64(tables.count()))),
+		Table: tables.name(int(idx % uint64(tables.count()))),
+	}
+}
+
+// patternBroadcast 返回所有的库和表
+func patternBroadcast(dbs, tables ShardingPattern) []ShardingDst {
+	res := make([]ShardingDst, 0, dbs.count()*tables.count())
+	for i := 0; i < dbs.count()*tables.count(); i++ {
+		res = append(res, patternDst(dbs, tables, uint64(i)))
+	}
+	return res
+}
+
+// ModSharding 对整数类型的分片键取模
+type ModSharding struct {
+	Key          string
+	DBPattern    ShardingPattern
+	TablePattern ShardingPattern
+}
+
+func (m ModSharding) ShardingKey() string {
+	return m.Key
+}
+
+func (m ModSharding) Shard(val any) (ShardingDst, error) {
+	n, err := shardingInt(m.Key, val)
+	if err != nil {
+		return ShardingDst{}, err
+	}
+	if n < 0 {
+		n = -n
+	}
+	return patternDst(m.DBPattern, m.TablePattern, uint64(n)), nil
+}
+
+func (m ModSharding) Broadcast() []ShardingDst {
+	return patternBroadcast(m.DBPattern, m.TablePattern)
+}
+
+// HashSharding 对分片键的哈希值取模，适用于字符串之类的分片键
+// 哈希使用 FNV-1a，整数和字符串 "12" 的哈希值是一样的
+type HashSharding struct {
+	Key          string
+	DBPattern    ShardingPattern
+	TablePattern ShardingPattern
+}
+
+func (h HashSharding) ShardingKey() string {
+	return h.Key
+}
+
+func (h HashSharding) Shard(val any) (ShardingDst, error) {
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer {
+		return ShardingDst{}, errs.NewErrShardingKeyType(h.Key, val)
+	}
+	hash := fnv.New64a()
+	_, _ = fmt.Fprint(hash, rv.Interface())
+	return patternDst(h.DBPattern, h.TablePattern, hash.Sum64()), nil
+}
+
+func (h HashSharding) Broadcast() []ShardingDst {
+	return patternBroadcast(h.DBPattern, h.TablePattern)
+}
+
+// ShardingRange 范围分片的一个区间，分片键小于 Upper 并且不小于上一个区间的 Upper 的数据存放在 Dst
+type ShardingRange struct {
+	Upper int64
+	Dst   ShardingDst
+}
+
+// RangeSharding 按照整数类型的分片键的范围分片，例如按照 ID 每一千万一张表
+// Ranges 需要按照 Upper 升序排列，最后一个区间可以使用 math.MaxInt64 作为 Upper
+type RangeSharding struct {
+	Key    string
+	Ranges []ShardingRange
+}
+
+func (r RangeSharding) ShardingKey() string {
+	return r.Key
+}
+
+func (r RangeSharding) Shard(val any) (ShardingDst, error) {
+	n, err := shardingInt(r.Key, val)
+	if err != nil {
+		return ShardingDst{}, err
+	}
+	idx := sort.Search(len(r.Ranges), func(i int) bool {
+		return n < r.Ranges[i].Upper || r.Ranges[i].Upper == math.MaxInt64
+	})
+	if idx == len(r.Ranges) {
+		return ShardingDst{}, errs.NewErrShardingOutOfRange(r.Key, val)
+	}
+	return r.Ranges[idx].Dst, nil
+}
+
+func (r RangeSharding) Broadcast() []ShardingDst {
+	res := make([]ShardingDst, 0, len(r.Ranges))
+	for _, rg := range r.Ranges {
+		if !slices.Contains(res, rg.Dst) {
+			res = append(res, rg.Dst)
+		}
+	}
+	return res
+}
+
+// shardingInt 把整数类型的分片键转换成 int64
+func shardingInt(key string, val any) (int64, error) {
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() <= math.MaxInt64 {
+			return int64(rv.Uint()), nil
+		}
+	}
+	return 0, errs.NewErrShardingKeyType(key, val)
+}
+
+// ShardingDB 分库分表的会话
+// Selector、Inserter、Updater 和 Deleter 使用 ShardingDB 的时候，会根据分片规则找到物理库和物理表：
+// 查询、更新和删除根据 WHERE 里面分片键的 = 和 IN 条件确定目标，确定不了的时候在所有的表上执行；
+// 插入根据每一行分片键的值确定目标。
+// 在多个表上执行的查询会合并结果，支持 ORDER BY、LIMIT、OFFSET、GROUP BY 以及 COUNT、SUM、MAX 和 MIN。
+// 不支持跨表的事务，也不支持 JOIN、子查询、HAVING 和原生查询
+type ShardingDB struct {
+	core
+	dbs   map[string]*DB
+	rules map[reflect.Type]ShardingAlgorithm
+}
+
+var _ Session = &ShardingDB{}
+
+// ShardingOption 配置 ShardingDB
+type ShardingOption func(db *ShardingDB)
+
+// ShardingWithRule 设置 entity 的分片规则，entity 是结构体指针，例如 &Order{}
+func ShardingWithRule(entity any, algo ShardingAlgorithm) ShardingOption {
+	return func(db *ShardingDB) {
+		db.rules[reflect.TypeOf(entity)] = algo
+	}
+}
+
+// OpenShardingDB 创建 ShardingDB，dbs 的 key 是 ShardingDst.DB 使用的库名
+// 数据库方言和元数据注册中心使用名字最小的那个 DB 的
+func OpenShardingDB(dbs map[string]*DB, opts ...ShardingOption) (*ShardingDB, error) {
+	if len(dbs) == 0 {
+		return nil, errs.ErrEmptyShardingDBs
+	}
+	names := make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := &ShardingDB{
+		core:  dbs[names[0]].core,
+		dbs:   dbs,
+		rules: make(map[reflect.Type]ShardingAlgorithm, 4),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	for _, algo := range res.rules {
+		for _, dst := range algo.Broadcast() {
+			if _, ok := dbs[dst.DB]; !ok {
+				return nil, errs.NewErrUnknownShardingDB(dst.DB)
+			}
+		}
+	}
+	return res, nil
+}
+
+// getCore 返回带上了分片信息的 core，嵌入了 ShardingDB 的结构体也会按照分片规则路由
+func (s *ShardingDB) getCore() core {
+	res := s.core
+	res.sharding = s
+	return res
+}
+
+// Dialect 返回数据库方言
+func (s *ShardingDB) Dialect() Dialect {
+	return s.dialect
+}
+
+// Registry 返回模型的元数据注册中心
+func (s *ShardingDB) Registry() model.Registry {
+	return s.r
+}
+
+// QueryContext 不知道在哪个库上执行，所以总是返回错误
+func (s *ShardingDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, errs.ErrShardingRawSQL
+}
+
+// ExecContext 不知道在哪个库上执行，所以总是返回错误
+func (s *ShardingDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, errs.ErrShardingRawSQL
+}
+
+// DB 返回名字为 name 的库，可以用来执行原生查询之类的操作
+func (s *ShardingDB) DB(name string) (*DB, bool) {
+	db, ok := s.dbs[name]
+	return db, ok
+}
+
+// ruleOf 返回 entity 的分片规则
+func (s *ShardingDB) ruleOf(entity any) (ShardingAlgorithm, error) {
+	algo, ok := s.rules[reflect.TypeOf(entity)]
+	if !ok {
+		return nil, errs.NewErrNoShardingRule(entity)
+	}
+	return algo, nil
+}
+
+// dbOf 返回 dst 所在的库
+func (s *ShardingDB) dbOf(dst ShardingDst) (*DB, error) {
+	db, ok := s.dbs[dst.DB]
+	if !ok {
+		return nil, errs.NewErrUnknownShardingDB(dst.DB)
+	}
+	return db, nil
+}
+
+// shardingSet 条件能够命中的分片，all 为 true 的时候代表所有的分片
+type shardingSet struct {
+	all  bool
+	dsts []ShardingDst
+}
+
+func (s shardingSet) and(r shardingSet) shardingSet {
+	if s.all {
+		return r
+	}
+	if r.all {
+		return s
+	}
+	res := shardingSet{}
+	for _, dst := range s.dsts {
+		if slices.Contains(r.dsts, dst) {
+			res.dsts = append(res.dsts, dst)
+		}
+	}
+	return res
+}
+
+func (s shardingSet) or(r shardingSet) shardingSet {
+	if s.all || r.all {
+		return shardingSet{all: true}
+	}
+	res := shardingSet{dsts: slices.Clone(s.dsts)}
+	for _, dst := range r.dsts {
+		if !slices.Contains(res.dsts, dst) {
+			res.dsts = append(res.dsts, dst)
+		}
+	}
+	return res
+}
+
+// shardingDsts 根据 WHERE 条件找到需要执行的分片
+func shardingDsts(algo ShardingAlgorithm, ps []Predicate) ([]ShardingDst, error) {
+	set := shardingSet{all: true}
+	for _, p := range ps {
+		sub, err := shardingPredicate(algo, p)
+		if err != nil {
+			return nil, err
+		}
+		set = set.and(sub)
+	}
+	if set.all {
+		return algo.Broadcast(), nil
+	}
+	return set.dsts, nil
+}
+
+// shardingPredicate 分析单个条件，只有分片键的 = 和 IN 能够缩小范围
+func shardingPredicate(algo ShardingAlgorithm, p Predicate) (shardingSet, error) {
+	switch p.op {
+	case opAND, opOR:
+		left, ok1 := p.left.(Predicate)
+		right, ok2 := p.right.(Predicate)
+		if !ok1 || !ok2 {
+			return shardingSet{all: true}, nil
+		}
+		l, err := shardingPredicate(algo, left)
+		if err != nil {
+			return shardingSet{}, err
+		}
+		r, err := shardingPredicate(algo, right)
+		if err != nil {
+			return shardingSet{}, err
+		}
+		if p.op == opAND {
+			return l.and(r), nil
+		}
+		return l.or(r), nil
+	case opEQ, opIN:
+		col, ok := p.left.(Column)
+		if !ok || col.name != algo.ShardingKey() {
+			return shardingSet{all: true}, nil
+		}
+		val, ok := p.right.(value)
+		if !ok {
+			return shardingSet{all: true}, nil
+		}
+		vals := []any{val.val}
+		if p.op == opIN {
+			if vals, ok = val.val.([]any); !ok {
+				return shardingSet{all: true}, nil
+			}
+		}
+		res := shardingSet{}
+		for _, v := range vals {
+			dst, err := algo.Shard(v)
+			if err != nil {
+				return shardingSet{}, err
+			}
+			if !slices.Contains(res.dsts, dst) {
+				res.dsts = append(res.dsts, dst)
+			}
+		}
+		return res, nil
+	default:
+		return shardingSet{all: true}, nil
+	}
+}
@@ -0,0 +1,317 @@
+package sorm
+
+import (
+	"context"
+	"github.com/xzhHas/sorm/internal/errs"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// shardingBuilder 在 db 上使用物理表 table 的 builder
+func shardingBuilder(db *DB, table string) builder {
+	return builder{
+		core:      db.core,
+		dialect:   db.dialect,
+		quoter:    db.dialect.quoter(),
+		tableName: table,
+	}
+}
+
+// onShard 复制一个在 db 的物理表 table 上执行的 Selector
+func (s *Selector[T]) onShard(db *DB, table string) *Selector[T] {
+	res := *s
+	res.builder = shardingBuilder(db, table)
+	res.sess = db
+	return &res
+}
+
+// shardingGetMulti 在分片上执行查询，多个分片的时候合并结果；single 为 true 的时候只需要第一行
+func (s *Selector[T]) shardingGetMulti(ctx context.Context, sdb *ShardingDB, single bool) ([]*T, error) {
+	algo, err := sdb.ruleOf(new(T))
+	if err != nil {
+		return nil, err
+	}
+	if tab, ok := s.table.(Table); s.table != nil && (!ok || reflect.TypeOf(tab.entity) != reflect.TypeOf(new(T))) {
+		return nil, errs.NewErrShardingUnsupported("JOIN 和子查询")
+	}
+	dsts, err := shardingDsts(algo, s.where)
+	if err != nil || len(dsts) == 0 {
+		return nil, err
+	}
+	if len(dsts) == 1 {
+		db, err := sdb.dbOf(dsts[0])
+		if err != nil {
+			return nil, err
+		}
+		return s.onShard(db, dsts[0].Table).GetMulti(ctx)
+	}
+
+	m, err := s.r.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	merger, err := newShardingMerger(m, s.columns, s.groupBy, s.orderBy)
+	if err != nil {
+		return nil, err
+	}
+	limit, offset := s.limit, s.offset
+	if single {
+		limit = 1
+	}
+	if merger.aggregated() && len(s.having) > 0 {
+		return nil, errs.NewErrShardingUnsupported("在多个分片上使用 HAVING")
+	}
+	queries := make([]*Selector[T], 0, len(dsts))
+	for _, dst := range dsts {
+		db, err := sdb.dbOf(dst)
+		if err != nil {
+			return nil, err
+		}
+		q := s.onShard(db, dst.Table)
+		// 每个分片都要返回足够的数据，合并之后再处理 OFFSET 和 LIMIT
+		q.offset = 0
+		q.limit = 0
+		if !merger.aggregated() && limit > 0 {
+			q.limit = offset + limit
+		}
+		queries = append(queries, q)
+	}
+
+	shards := make([][]*T, len(queries))
+	shardErrs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for idx, q := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shards[idx], shardErrs[idx] = q.GetMulti(ctx)
+		}()
+	}
+	wg.Wait()
+	for _, err = range shardErrs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	res, err := mergeShards(merger, shards)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= len(res) {
+		return nil, nil
+	}
+	res = res[offset:]
+	if limit > 0 && limit < len(res) {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// onShard 复制一个在 db 的物理表 table 上插入 values 的 Inserter
+func (i *Inserter[T]) onShard(db *DB, table string, values []*T) *Inserter[T] {
+	res := *i
+	res.builder = shardingBuilder(db, table)
+	res.sess = db
+	res.values = values
+	return &res
+}
+
+// shardingExec 按照每一行分片键的值把数据插入到对应的分片，同一个分片的数据一起插入
+// 不同分片之间没有事务，InTx 只保证同一个分片里面的数据一起成功或者失败
+func (i *Inserter[T]) shardingExec(ctx context.Context, sdb *ShardingDB) Result {
+	if len(i.maps) > 0 || i.sel != nil {
+		return Result{err: errs.NewErrShardingUnsupported("使用 Maps 和 FromSelect 插入")}
+	}
+	if len(i.values) == 0 {
+		return Result{err: errs.ErrInsertZeroRow}
+	}
+	algo, err := sdb.ruleOf(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	m, err := i.r.Get(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	var dsts []ShardingDst
+	groups := make(map[ShardingDst][]*T, 4)
+	for _, v := range i.values {
+		key, err := i.valCreator(v, m).Field(algo.ShardingKey())
+		if err != nil {
+			return Result{err: err}
+		}
+		dst, err := algo.Shard(key)
+		if err != nil {
+			return Result{err: err}
+		}
+		if _, ok := groups[dst]; !ok {
+			dsts = append(dsts, dst)
+		}
+		groups[dst] = append(groups[dst], v)
+	}
+	fns := make([]execFunc, 0, len(dsts))
+	for _, dst := range dsts {
+		db, err := sdb.dbOf(dst)
+		if err != nil {
+			return Result{err: err}
+		}
+		sub := i.onShard(db, dst.Table, groups[dst])
+		fns = append(fns, func(ctx context.Context, _ Session) Result {
+			return sub.Exec(ctx)
+		})
+	}
+	if len(fns) == 1 {
+		return fns[0](ctx, sdb)
+	}
+	return execBatch(ctx, sdb, fns, false)
+}
+
+// onShard 复制一个在 db 的物理表 table 上执行的 Updater
+func (u *Updater[T]) onShard(db *DB, table string) *Updater[T] {
+	res := *u
+	res.builder = shardingBuilder(db, table)
+	res.sess = db
+	return &res
+}
+
+// shardingExec 在 WHERE 条件命中的分片上执行更新，不能修改分片键
+// 使用 UpdateNonZero 和 UpdateAll 的时候，根据结构体里面分片键的值确定分片
+func (u *Updater[T]) shardingExec(ctx context.Context, sdb *ShardingDB) Result {
+	if u.table != nil {
+		return Result{err: errs.NewErrShardingUnsupported("多表更新")}
+	}
+	algo, err := sdb.ruleOf(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	m, err := u.r.Get(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	key := algo.ShardingKey()
+	keyFd, ok := m.FieldMap[key]
+	if !ok {
+		return Result{err: errs.NewErrUnknownField(key)}
+	}
+	_, inMap := u.setMap[key]
+	_, colInMap := u.setMap[keyFd.ColName]
+	if inMap || colInMap || slices.ContainsFunc(u.assigns, func(a Assignable) bool {
+		switch assign := a.(type) {
+		case Column:
+			return assign.name == key
+		case Assignment:
+			return assign.column == key
+		}
+		return false
+	}) {
+		return Result{err: errs.NewErrShardingUnsupported("修改分片键")}
+	}
+	dsts, err := shardingDsts(algo, u.where)
+	if err != nil {
+		return Result{err: err}
+	}
+	if u.mode != updateExplicit && u.val != nil {
+		keyVal, err := u.valCreator(u.val, m).Field(key)
+		if err != nil {
+			return Result{err: err}
+		}
+		dst, err := algo.Shard(keyVal)
+		if err != nil {
+			return Result{err: err}
+		}
+		dsts = shardingSet{dsts: dsts}.and(shardingSet{dsts: []ShardingDst{dst}}).dsts
+	}
+	fns := make([]execFunc, 0, len(dsts))
+	for _, dst := range dsts {
+		db, err := sdb.dbOf(dst)
+		if err != nil {
+			return Result{err: err}
+		}
+		sub := u.onShard(db, dst.Table)
+		fns = append(fns, func(ctx context.Context, _ Session) Result {
+			return sub.Exec(ctx)
+		})
+	}
+	return execShards(ctx, sdb, fns)
+}
+
+// onShard 复制一个在 db 的物理表 table 上执行的 Deleter
+func (d *Deleter[T]) onShard(db *DB, table string) *Deleter[T] {
+	res := *d
+	res.builder = shardingBuilder(db, table)
+	res.sess = db
+	return &res
+}
+
+// shardingQueries 返回在 WHERE 条件命中的分片上执行的 Deleter
+func (d *Deleter[T]) shardingQueries(sdb *ShardingDB) ([]*Deleter[T], error) {
+	if d.table != nil {
+		return nil, errs.NewErrShardingUnsupported("多表删除")
+	}
+	algo, err := sdb.ruleOf(new(T))
+	if err != nil {
+		return nil, err
+	}
+	dsts, err := shardingDsts(algo, d.where)
+	if err != nil {
+		return nil, err
+	}
+	if d.limit > 0 && len(dsts) > 1 {
+		return nil, errs.NewErrShardingUnsupported("在多个分片上使用 LIMIT 删除")
+	}
+	res := make([]*Deleter[T], 0, len(dsts))
+	for _, dst := range dsts {
+		db, err := sdb.dbOf(dst)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d.onShard(db, dst.Table))
+	}
+	return res, nil
+}
+
+// shardingExec 在 WHERE 条件命中的分片上执行删除
+func (d *Deleter[T]) shardingExec(ctx context.Context, sdb *ShardingDB) Result {
+	queries, err := d.shardingQueries(sdb)
+	if err != nil {
+		return Result{err: err}
+	}
+	fns := make([]execFunc, 0, len(queries))
+	for _, q := range queries {
+		fns = append(fns, func(ctx context.Context, _ Session) Result {
+			return q.Exec(ctx)
+		})
+	}
+	return execShards(ctx, sdb, fns)
+}
+
+// shardingGetMulti 在 WHERE 条件命中的分片上执行删除，并且合并 RETURNING 的结果
+func (d *Deleter[T]) shardingGetMulti(ctx context.Context, sdb *ShardingDB) ([]*T, error) {
+	queries, err := d.shardingQueries(sdb)
+	if err != nil {
+		return nil, err
+	}
+	var res []*T
+	for _, q := range queries {
+		rows, err := q.GetMulti(ctx)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, rows...)
+	}
+	return res, nil
+}
+
+// execShards 依次在每个分片或者分表上执行，一个的时候直接返回它的结果
+// 没有命中任何分片的时候返回一个没有影响任何行的结果
+func execShards(ctx context.Context, sess Session, fns []execFunc) Result {
+	switch len(fns) {
+	case 0:
+		return Result{}
+	case 1:
+		return fns[0](ctx, sess)
+	default:
+		return execBatch(ctx, sess, fns, false)
+	}
+}
@@ -0,0 +1,265 @@
+package sorm
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"fmt"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/model"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// shardingAgg 查询的聚合函数，field 是结果所在的字段
+type shardingAgg struct {
+	field string
+	fn    string
+}
+
+// shardingMerger 合并多个分片的查询结果
+type shardingMerger struct {
+	// selectAll 为 true 代表 SELECT *，所有的字段都有值
+	selectAll bool
+	selected  []string
+	aggs      []shardingAgg
+	groupBy   []string
+	orderBy   []OrderBy
+}
+
+// newShardingMerger 分析查询的列，确定合并的方式
+// 聚合函数需要使用和字段列名相同的别名，这样才能知道结果在哪个字段；
+// GROUP BY 和 ORDER BY 的字段需要出现在查询的列里面
+func newShardingMerger(m *model.Model, columns []Selectable, groupBy []Column, orderBy []OrderBy) (*shardingMerger, error) {
+	res := &shardingMerger{selectAll: len(columns) == 0, orderBy: orderBy}
+	raw := false
+	for _, c := range columns {
+		switch col := c.(type) {
+		case Column:
+			field := col.name
+			if col.alias != "" {
+				if fd, ok := m.ColumnMap[col.alias]; ok {
+					field = fd.GoName
+				}
+			}
+			res.selected = append(res.selected, field)
+		case Aggregate:
+			fd, ok := m.ColumnMap[col.alias]
+			if !ok {
+				return nil, errs.NewErrShardingMergeField(fmt.Sprintf("%s(%s)", col.fn, col.arg))
+			}
+			if col.fn == "AVG" {
+				return nil, errs.NewErrShardingUnsupported("在多个分片上使用 AVG，可以分别查询 SUM 和 COUNT")
+			}
+			res.aggs = append(res.aggs, shardingAgg{field: fd.GoName, fn: col.fn})
+			res.selected = append(res.selected, fd.GoName)
+		default:
+			raw = true
+		}
+	}
+	for _, c := range groupBy {
+		res.groupBy = append(res.groupBy, c.name)
+	}
+	if raw && res.aggregated() {
+		return nil, errs.NewErrShardingUnsupported("在多个分片上同时使用原生表达式和聚合函数")
+	}
+	for _, fd := range res.groupBy {
+		if err := res.checkSelected(m, fd); err != nil {
+			return nil, err
+		}
+	}
+	for _, ob := range orderBy {
+		if err := res.checkSelected(m, ob.col); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// checkSelected 检查 fd 是否出现在查询的列里面
+func (s *shardingMerger) checkSelected(m *model.Model, fd string) error {
+	if _, ok := m.FieldMap[fd]; !ok {
+		return errs.NewErrUnknownField(fd)
+	}
+	if !s.selectAll && !slices.Contains(s.selected, fd) {
+		return errs.NewErrShardingMergeField(fd)
+	}
+	return nil
+}
+
+// aggregated 是否需要按照分组合并聚合函数的结果
+func (s *shardingMerger) aggregated() bool {
+	return len(s.aggs) > 0 || len(s.groupBy) > 0
+}
+
+// mergeShards 合并每个分片的结果：先按照分组合并聚合函数，再排序
+func mergeShards[T any](s *shardingMerger, shards [][]*T) ([]*T, error) {
+	var res []*T
+	if s.aggregated() {
+		index := make(map[string]*T, 16)
+		for _, rows := range shards {
+			for _, row := range rows {
+				key, err := s.groupKey(row)
+				if err != nil {
+					return nil, err
+				}
+				dst, ok := index[key]
+				if !ok {
+					index[key] = row
+					res = append(res, row)
+					continue
+				}
+				if err = s.mergeRow(reflect.ValueOf(dst).Elem(), reflect.ValueOf(row).Elem()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else {
+		for _, rows := range shards {
+			res = append(res, rows...)
+		}
+	}
+	if len(s.orderBy) > 0 {
+		slices.SortStableFunc(res, func(a, b *T) int {
+			va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+			for _, ob := range s.orderBy {
+				c := compareValues(va.FieldByName(ob.col), vb.FieldByName(ob.col))
+				if ob.order == "DESC" {
+					c = -c
+				}
+				if c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+	return res, nil
+}
+
+// groupKey 返回 row 所在分组的 key
+func (s *shardingMerger) groupKey(row any) (string, error) {
+	val := reflect.ValueOf(row).Elem()
+	var sb strings.Builder
+	for _, fd := range s.groupBy {
+		v := val.FieldByName(fd)
+		if !v.IsValid() {
+			return "", errs.NewErrUnknownField(fd)
+		}
+		// 使用 %#v 区分 nil 指针、空字符串之类的值
+		if v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		fmt.Fprintf(&sb, "%#v;", v.Interface())
+	}
+	return sb.String(), nil
+}
+
+// mergeRow 把 src 的聚合结果合并到 dst
+func (s *shardingMerger) mergeRow(dst, src reflect.Value) error {
+	for _, agg := range s.aggs {
+		d, v := dst.FieldByName(agg.field), src.FieldByName(agg.field)
+		switch agg.fn {
+		case "COUNT", "SUM":
+			if err := addValue(d, v); err != nil {
+				return err
+			}
+		case "MAX", "MIN":
+			if isNullValue(v) {
+				continue
+			}
+			c := compareValues(v, d)
+			if isNullValue(d) || (agg.fn == "MAX" && c > 0) || (agg.fn == "MIN" && c < 0) {
+				d.Set(v)
+			}
+		default:
+			return errs.NewErrShardingUnsupported(fmt.Sprintf("在多个分片上使用 %s", agg.fn))
+		}
+	}
+	return nil
+}
+
+// addValue 把 v 加到 dst 上，nil 指针当成没有值
+func addValue(dst, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(v)
+			return nil
+		}
+		return addValue(dst.Elem(), v.Elem())
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst.SetInt(dst.Int() + v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		dst.SetUint(dst.Uint() + v.Uint())
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(dst.Float() + v.Float())
+	default:
+		return errs.NewErrShardingUnsupported(fmt.Sprintf("合并 %s 类型的 COUNT 和 SUM", v.Type()))
+	}
+	return nil
+}
+
+// isNullValue 判断 v 是不是 NULL，包括 nil 指针和 Valid 为 false 的 sql.NullXXX
+func isNullValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Pointer {
+		return v.IsNil()
+	}
+	if vl, ok := v.Interface().(driver.Valuer); ok {
+		val, err := vl.Value()
+		return err == nil && val == nil
+	}
+	return false
+}
+
+// compareValues 比较两个字段的值，NULL 比其它的值都小
+func compareValues(a, b reflect.Value) int {
+	an, bn := isNullValue(a), isNullValue(b)
+	switch {
+	case an && bn:
+		return 0
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	if a.Kind() == reflect.Pointer {
+		return compareValues(a.Elem(), b.Elem())
+	}
+	if va, ok := a.Interface().(driver.Valuer); ok {
+		x, errA := va.Value()
+		y, errB := b.Interface().(driver.Valuer).Value()
+		if errA == nil && errB == nil {
+			return compareValues(reflect.ValueOf(x), reflect.ValueOf(y))
+		}
+	}
+	if ta, ok := a.Interface().(time.Time); ok {
+		return ta.Compare(b.Interface().(time.Time))
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return cmp.Compare(boolInt(a.Bool()), boolInt(b.Bool()))
+	default:
+		return cmp.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,525 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"math"
+	"strings"
+	"testing"
+)
+
+// openShardingTestDB 打开 shard_db_0 和 shard_db_1 两个库，每个库里面有 test_model_0 和 test_model_1 两张表
+// Id 对 4 取模：0 在 shard_db_0.test_model_0，1 在 shard_db_0.test_model_1，
+// 2 在 shard_db_1.test_model_0，3 在 shard_db_1.test_model_1
+func openShardingTestDB(t *testing.T, name string) *ShardingDB {
+	dbs := make(map[string]*DB, 2)
+	for i := 0; i < 2; i++ {
+		db, err := Open("sqlite3", fmt.Sprintf("file:%s_%d.db?cache=shared&mode=memory", name, i), DBWithDialect(SQLite3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		for j := 0; j < 2; j++ {
+			createSQL := strings.Replace(TestModel{}.CreateSQL(), "test_model", fmt.Sprintf("test_model_%d", j), 1)
+			if _, err := db.db.Exec(createSQL); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dbs[fmt.Sprintf("shard_db_%d", i)] = db
+	}
+	sdb, err := OpenShardingDB(dbs, ShardingWithRule(&TestModel{}, ModSharding{
+		Key:          "Id",
+		DBPattern:    ShardingPattern{Name: "shard_db_%d", Count: 2},
+		TablePattern: ShardingPattern{Name: "test_model_%d", Count: 2},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sdb
+}
+
+// shardRows 返回物理表里面的 Id
+func shardRows(t *testing.T, sdb *ShardingDB, db, table string) []int64 {
+	d, ok := sdb.DB(db)
+	if !ok {
+		t.Fatalf("unknown db %s", db)
+	}
+	rows, err := RawQuery[TestModel](d, fmt.Sprintf("SELECT * FROM `%s` ORDER BY `id`", table)).GetMulti(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.Id)
+	}
+	return res
+}
+
+func shardingTestModel(id int64, name string, age int8) *TestModel {
+	return &TestModel{Id: id, FirstName: name, Age: age, LastName: &sql.NullString{String: "Cat", Valid: true}}
+}
+
+func TestShardingAlgorithm(t *testing.T) {
+	mod := ModSharding{
+		Key:          "Id",
+		DBPattern:    ShardingPattern{Name: "db_%d", Count: 2},
+		TablePattern: ShardingPattern{Name: "tab_%d", Count: 3},
+	}
+	rg := RangeSharding{
+		Key: "Id",
+		Ranges: []ShardingRange{
+			{Upper: 100, Dst: ShardingDst{DB: "db", Table: "tab_0"}},
+			{Upper: 200, Dst: ShardingDst{DB: "db", Table: "tab_1"}},
+		},
+	}
+	single := HashSharding{
+		Key:          "Name",
+		DBPattern:    ShardingPattern{Name: "db"},
+		TablePattern: ShardingPattern{Name: "tab_%d", Count: 2},
+	}
+	testCases := []struct {
+		name    string
+		algo    ShardingAlgorithm
+		val     any
+		wantDst ShardingDst
+		wantErr error
+	}{
+		{
+			name:    "mod",
+			algo:    mod,
+			val:     int64(10),
+			wantDst: ShardingDst{DB: "db_1", Table: "tab_1"},
+		},
+		{
+			name:    "mod pointer",
+			algo:    mod,
+			val:     func() *uint8 { v := uint8(2); return &v }(),
+			wantDst: ShardingDst{DB: "db_0", Table: "tab_2"},
+		},
+		{
+			name:    "mod invalid type",
+			algo:    mod,
+			val:     "10",
+			wantErr: errs.NewErrShardingKeyType("Id", "10"),
+		},
+		{
+			name:    "range",
+			algo:    rg,
+			val:     100,
+			wantDst: ShardingDst{DB: "db", Table: "tab_1"},
+		},
+		{
+			name:    "range out of range",
+			algo:    rg,
+			val:     200,
+			wantErr: errs.NewErrShardingOutOfRange("Id", 200),
+		},
+		{
+			name:    "range max",
+			algo:    RangeSharding{Key: "Id", Ranges: []ShardingRange{{Upper: math.MaxInt64, Dst: ShardingDst{DB: "db"}}}},
+			val:     int64(math.MaxInt64),
+			wantDst: ShardingDst{DB: "db"},
+		},
+		{
+			name:    "hash same as int",
+			algo:    single,
+			val:     "12",
+			wantDst: func() ShardingDst { dst, _ := single.Shard(12); return dst }(),
+		},
+		{
+			name:    "hash nil",
+			algo:    single,
+			val:     nil,
+			wantErr: errs.NewErrShardingKeyType("Name", nil),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, err := tc.algo.Shard(tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantDst, dst)
+		})
+	}
+
+	assert.Equal(t, []ShardingDst{
+		{DB: "db_0", Table: "tab_0"}, {DB: "db_0", Table: "tab_1"}, {DB: "db_0", Table: "tab_2"},
+		{DB: "db_1", Table: "tab_0"}, {DB: "db_1", Table: "tab_1"}, {DB: "db_1", Table: "tab_2"},
+	}, mod.Broadcast())
+	assert.Equal(t, []ShardingDst{{DB: "db", Table: "tab_0"}, {DB: "db", Table: "tab_1"}}, rg.Broadcast())
+}
+
+func TestShardingDsts(t *testing.T) {
+	algo := ModSharding{
+		Key:          "Id",
+		DBPattern:    ShardingPattern{Name: "db"},
+		TablePattern: ShardingPattern{Name: "tab_%d", Count: 4},
+	}
+	all := algo.Broadcast()
+	testCases := []struct {
+		name     string
+		where    []Predicate
+		wantDsts []ShardingDst
+		wantErr  error
+	}{
+		{
+			name:     "no where",
+			wantDsts: all,
+		},
+		{
+			name:     "eq",
+			where:    []Predicate{C("Id").EQ(5)},
+			wantDsts: []ShardingDst{{DB: "db", Table: "tab_1"}},
+		},
+		{
+			name:     "in",
+			where:    []Predicate{C("Id").In(1, 5, 2)},
+			wantDsts: []ShardingDst{{DB: "db", Table: "tab_1"}, {DB: "db", Table: "tab_2"}},
+		},
+		{
+			name:     "and",
+			where:    []Predicate{C("Id").In(1, 2), C("Age").GT(18)},
+			wantDsts: []ShardingDst{{DB: "db", Table: "tab_1"}, {DB: "db", Table: "tab_2"}},
+		},
+		{
+			name:     "and intersect",
+			where:    []Predicate{C("Id").In(1, 2), C("Id").EQ(6)},
+			wantDsts: []ShardingDst{{DB: "db", Table: "tab_2"}},
+		},
+		{
+			name:     "or",
+			where:    []Predicate{C("Id").EQ(1).Or(C("Id").EQ(3))},
+			wantDsts: []ShardingDst{{DB: "db", Table: "tab_1"}, {DB: "db", Table: "tab_3"}},
+		},
+		{
+			name:     "or other column",
+			where:    []Predicate{C("Id").EQ(1).Or(C("Age").EQ(3))},
+			wantDsts: all,
+		},
+		{
+			name:     "not",
+			where:    []Predicate{Not(C("Id").EQ(1))},
+			wantDsts: all,
+		},
+		{
+			name:     "gt",
+			where:    []Predicate{C("Id").GT(1)},
+			wantDsts: all,
+		},
+		{
+			name:    "invalid type",
+			where:   []Predicate{C("Id").EQ("abc")},
+			wantErr: errs.NewErrShardingKeyType("Id", "abc"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dsts, err := shardingDsts(algo, tc.where)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantDsts, dsts)
+		})
+	}
+}
+
+func TestOpenShardingDB(t *testing.T) {
+	_, err := OpenShardingDB(nil)
+	assert.Equal(t, errs.ErrEmptyShardingDBs, err)
+
+	db := memoryDBWithDB("open_sharding", t)
+	defer func() { _ = db.Close() }()
+	_, err = OpenShardingDB(map[string]*DB{"db_0": db}, ShardingWithRule(&TestModel{}, ModSharding{
+		Key:       "Id",
+		DBPattern: ShardingPattern{Name: "db_%d", Count: 2},
+	}))
+	assert.Equal(t, errs.NewErrUnknownShardingDB("db_1"), err)
+
+	sdb, err := OpenShardingDB(map[string]*DB{"db": db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewSelector[TestModel](sdb).GetMulti(context.Background())
+	assert.Equal(t, errs.NewErrNoShardingRule(&TestModel{}), err)
+	_, err = RawQuery[TestModel](sdb, "SELECT * FROM `test_model`").GetMulti(context.Background())
+	assert.Equal(t, errs.ErrShardingRawSQL, err)
+}
+
+func TestShardingDB_Insert(t *testing.T) {
+	sdb := openShardingTestDB(t, "sharding_insert")
+	ctx := context.Background()
+
+	res := NewInserter[TestModel](sdb).Values(
+		shardingTestModel(1, "Tom", 18),
+		shardingTestModel(2, "Jerry", 20),
+		shardingTestModel(5, "Cat", 22),
+		shardingTestModel(8, "Dog", 24),
+	).Exec(ctx)
+	assert.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), affected)
+
+	assert.Equal(t, []int64{8}, shardRows(t, sdb, "shard_db_0", "test_model_0"))
+	assert.Equal(t, []int64{1, 5}, shardRows(t, sdb, "shard_db_0", "test_model_1"))
+	assert.Equal(t, []int64{2}, shardRows(t, sdb, "shard_db_1", "test_model_0"))
+	assert.Equal(t, []int64{}, shardRows(t, sdb, "shard_db_1", "test_model_1"))
+
+	res = NewInserter[TestModel](sdb).Values(shardingTestModel(3, "Tom", 18)).
+		Columns("Id", "FirstName", "Age", "LastName").Exec(ctx)
+	assert.NoError(t, res.Err())
+	assert.Equal(t, []int64{3}, shardRows(t, sdb, "shard_db_1", "test_model_1"))
+
+	res = NewInserter[TestModel](sdb).Values().Exec(ctx)
+	assert.Equal(t, errs.ErrInsertZeroRow, res.Err())
+	res = NewInserter[TestModel](sdb).Maps(map[string]any{"Id": 1}).Exec(ctx)
+	assert.Equal(t, errs.NewErrShardingUnsupported("使用 Maps 和 FromSelect 插入"), res.Err())
+}
+
+func TestShardingDB_Select(t *testing.T) {
+	sdb := openShardingTestDB(t, "sharding_select")
+	ctx := context.Background()
+	res := NewInserter[TestModel](sdb).Values(
+		shardingTestModel(1, "Tom", 18),
+		shardingTestModel(2, "Jerry", 20),
+		shardingTestModel(3, "Tom", 22),
+		shardingTestModel(4, "Jerry", 24),
+		shardingTestModel(5, "Tom", 26),
+		shardingTestModel(6, "Dog", 28),
+	).Exec(ctx)
+	if res.Err() != nil {
+		t.Fatal(res.Err())
+	}
+
+	ids := func(rows []*TestModel) []int64 {
+		res := make([]int64, 0, len(rows))
+		for _, row := range rows {
+			res = append(res, row.Id)
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name    string
+		s       *Selector[TestModel]
+		wantIds []int64
+		wantErr error
+	}{
+		{
+			name:    "eq",
+			s:       NewSelector[TestModel](sdb).Where(C("Id").EQ(3)),
+			wantIds: []int64{3},
+		},
+		{
+			name:    "in with order",
+			s:       NewSelector[TestModel](sdb).Where(C("Id").In(1, 2, 5)).OrderBy(Desc("Id")),
+			wantIds: []int64{5, 2, 1},
+		},
+		{
+			name:    "broadcast",
+			s:       NewSelector[TestModel](sdb).Where(C("Age").GT(20)).OrderBy(Asc("Age")),
+			wantIds: []int64{3, 4, 5, 6},
+		},
+		{
+			name:    "limit offset",
+			s:       NewSelector[TestModel](sdb).OrderBy(Asc("FirstName"), Desc("Id")).Offset(1).Limit(3),
+			wantIds: []int64{4, 2, 5},
+		},
+		{
+			name:    "offset out of range",
+			s:       NewSelector[TestModel](sdb).Offset(10),
+			wantIds: []int64{},
+		},
+		{
+			name:    "no shard",
+			s:       NewSelector[TestModel](sdb).Where(C("Id").EQ(3), C("Id").EQ(4)),
+			wantIds: []int64{},
+		},
+		{
+			name:    "order by column not selected",
+			s:       NewSelector[TestModel](sdb).Select(C("Id")).OrderBy(Asc("Age")),
+			wantErr: errs.NewErrShardingMergeField("Age"),
+		},
+		{
+			name:    "avg",
+			s:       NewSelector[TestModel](sdb).Select(Avg("Age").As("age")),
+			wantErr: errs.NewErrShardingUnsupported("在多个分片上使用 AVG，可以分别查询 SUM 和 COUNT"),
+		},
+		{
+			name:    "aggregate without alias",
+			s:       NewSelector[TestModel](sdb).Select(Max("Age")),
+			wantErr: errs.NewErrShardingMergeField("MAX(Age)"),
+		},
+		{
+			name: "having",
+			s: NewSelector[TestModel](sdb).Select(C("FirstName"), Count("Id").As("id")).
+				GroupBy(C("FirstName")).Having(Count("Id").GT(1)),
+			wantErr: errs.NewErrShardingUnsupported("在多个分片上使用 HAVING"),
+		},
+		{
+			name:    "join",
+			s:       NewSelector[TestModel](sdb).From(TableOf(&TestModel{}).Join(TableOf(&TestModel{})).On(C("Id").EQ(C("Id")))),
+			wantErr: errs.NewErrShardingUnsupported("JOIN 和子查询"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := tc.s.GetMulti(ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantIds, ids(rows))
+		})
+	}
+
+	got, err := NewSelector[TestModel](sdb).Where(C("Id").EQ(4)).Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, shardingTestModel(4, "Jerry", 24), got)
+	got, err = NewSelector[TestModel](sdb).OrderBy(Desc("Age")).Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), got.Id)
+	_, err = NewSelector[TestModel](sdb).Where(C("Id").EQ(100)).Get(ctx)
+	assert.Equal(t, ErrNoRows, err)
+}
+
+func TestShardingDB_Aggregate(t *testing.T) {
+	sdb := openShardingTestDB(t, "sharding_aggregate")
+	ctx := context.Background()
+	res := NewInserter[TestModel](sdb).Values(
+		shardingTestModel(1, "Tom", 18),
+		shardingTestModel(2, "Jerry", 20),
+		shardingTestModel(3, "Tom", 22),
+		shardingTestModel(4, "Jerry", 24),
+		shardingTestModel(5, "Tom", 26),
+		shardingTestModel(6, "Dog", 28),
+	).Exec(ctx)
+	if res.Err() != nil {
+		t.Fatal(res.Err())
+	}
+
+	rows, err := NewSelector[TestModel](sdb).
+		Select(C("FirstName"), Count("Id").As("id"), Sum("Age").As("age")).
+		GroupBy(C("FirstName")).OrderBy(Desc("Id"), Asc("FirstName")).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*TestModel{
+		{FirstName: "Tom", Id: 3, Age: 66},
+		{FirstName: "Jerry", Id: 2, Age: 44},
+		{FirstName: "Dog", Id: 1, Age: 28},
+	}, rows)
+
+	rows, err = NewSelector[TestModel](sdb).
+		Select(Max("Age").As("age"), Min("Id").As("id")).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*TestModel{{Id: 1, Age: 28}}, rows)
+
+	got, err := NewSelector[TestModel](sdb).Select(Count("Id").As("id")).
+		Where(C("FirstName").EQ("Tom")).Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), got.Id)
+}
+
+func TestShardingDB_UpdateDelete(t *testing.T) {
+	sdb := openShardingTestDB(t, "sharding_update_delete")
+	ctx := context.Background()
+	res := NewInserter[TestModel](sdb).Values(
+		shardingTestModel(1, "Tom", 18),
+		shardingTestModel(2, "Jerry", 20),
+		shardingTestModel(3, "Tom", 22),
+		shardingTestModel(4, "Jerry", 24),
+	).Exec(ctx)
+	if res.Err() != nil {
+		t.Fatal(res.Err())
+	}
+	age := func(id int64) int8 {
+		row, err := NewSelector[TestModel](sdb).Where(C("Id").EQ(id)).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row.Age
+	}
+
+	res = NewUpdater[TestModel](sdb).Set(Assign("Age", 30)).Where(C("Id").In(1, 2)).Exec(ctx)
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, []int8{30, 30, 22, 24}, []int8{age(1), age(2), age(3), age(4)})
+
+	res = NewUpdater[TestModel](sdb).Set(Assign("Age", 40)).Where(C("FirstName").EQ("Jerry")).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, []int8{30, 40, 22, 40}, []int8{age(1), age(2), age(3), age(4)})
+
+	res = NewUpdater[TestModel](sdb).UpdateNonZero(&TestModel{Id: 3, Age: 50}).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	assert.Equal(t, int8(50), age(3))
+
+	res = NewUpdater[TestModel](sdb).Set(Assign("Id", 10)).Where(C("Id").EQ(1)).Exec(ctx)
+	assert.Equal(t, errs.NewErrShardingUnsupported("修改分片键"), res.Err())
+
+	res = NewDeleter[TestModel](sdb).Where(C("Id").EQ(1), C("Id").EQ(2)).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+
+	res = NewDeleter[TestModel](sdb).Where(C("Age").EQ(40)).Limit(1).Exec(ctx)
+	assert.Equal(t, errs.NewErrShardingUnsupported("在多个分片上使用 LIMIT 删除"), res.Err())
+
+	res = NewDeleter[TestModel](sdb).Where(C("Age").EQ(40)).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	rows, err := NewDeleter[TestModel](sdb).Where(C("Id").EQ(3)).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*TestModel{shardingTestModel(3, "Tom", 50)}, rows)
+	assert.Equal(t, []int64{1}, shardRows(t, sdb, "shard_db_0", "test_model_1"))
+	assert.Equal(t, []int64{}, shardRows(t, sdb, "shard_db_1", "test_model_1"))
+}
+
+func TestShardingDB_Embedded(t *testing.T) {
+	// 嵌入了 ShardingDB 的会话，例如加上了日志或者指标的封装，也要按照分片规则路由
+	type wrappedDB struct {
+		*ShardingDB
+	}
+	sdb := openShardingTestDB(t, "sharding_embedded")
+	sess := wrappedDB{ShardingDB: sdb}
+	ctx := context.Background()
+
+	res := NewInserter[TestModel](sess).Values(
+		shardingTestModel(1, "Tom", 18),
+		shardingTestModel(2, "Jerry", 20),
+	).Exec(ctx)
+	assert.NoError(t, res.Err())
+	assert.Equal(t, []int64{1}, shardRows(t, sdb, "shard_db_0", "test_model_1"))
+	assert.Equal(t, []int64{2}, shardRows(t, sdb, "shard_db_1", "test_model_0"))
+
+	rows, err := NewSelector[TestModel](sess).OrderBy(Asc("Id")).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*TestModel{shardingTestModel(1, "Tom", 18), shardingTestModel(2, "Jerry", 20)}, rows)
+	row, err := NewSelector[TestModel](sess).Where(C("Id").EQ(2)).Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, shardingTestModel(2, "Jerry", 20), row)
+
+	res = NewUpdater[TestModel](sess).Set(Assign("Age", 30)).Where(C("Id").EQ(1)).Exec(ctx)
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	deleted, err := NewDeleter[TestModel](sess).Where(C("Id").EQ(1)).GetMulti(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*TestModel{shardingTestModel(1, "Tom", 30)}, deleted)
+	res = NewDeleter[TestModel](sess).Where(C("Id").EQ(2)).Exec(ctx)
+	affected, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+}
@@ -3,29 +3,65 @@ package sorm
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/internal/valuer"
+	"github.com/xzhHas/sorm/model"
 )
 
-// 确保Tx和DB实现了session接口
-var _ session = &Tx{}
-var _ session = &DB{}
+// 确保Tx和DB实现了Session接口
+var _ Session = &Tx{}
+var _ Session = &DB{}
 
-// session 代表一个抽象的概念，即会话
-// 暂时做成私有的，后面考虑重构，因为这个东西用户可能有点难以理解
-type session interface {
-	// 获取与会话的核心信息
+// Session 代表一个抽象的概念，即会话，DB 和 Tx 都是会话
+// NewSelector 等方法接收的就是 Session，所以可以写出同时支持 DB 和 Tx 的通用方法。
+//
+// 也可以自己实现 Session，例如代理或者分库分表的会话。
+// 嵌入 *DB 或者 *Tx 的实现会沿用它们的中间件等配置，只需要覆盖 QueryContext 和 ExecContext；
+// 其它的实现只会使用 Dialect 和 Registry，不经过中间件。
+type Session interface {
+	// Dialect 返回数据库方言
+	Dialect() Dialect
+	// Registry 返回模型的元数据注册中心
+	Registry() model.Registry
+	// QueryContext 执行查询，返回多行数据
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	// ExecContext 执行增删改之类的语句
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// coreSession 能够提供 core 的会话，DB、Tx 以及嵌入了它们的结构体都实现了这个接口
+type coreSession interface {
 	getCore() core
-	// 执行SQL查询的封装并返回结果
-	queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
-	// 数据库交互提供基础的操作接口
-	execContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// coreOf 返回 sess 的 core，sess 没有 core 的时候根据 Dialect 和 Registry 构造一个
+func coreOf(sess Session) core {
+	if cs, ok := sess.(coreSession); ok {
+		return cs.getCore()
+	}
+	return core{
+		r:          sess.Registry(),
+		dialect:    sess.Dialect(),
+		valCreator: valuer.NewUnsafeValue,
+	}
 }
 
 type Tx struct {
 	tx *sql.Tx
 	db *DB
-	// 事务扩散方案里面，
-	// 这个要在 commit 或者 rollback 的时候修改为 true
-	// done bool
+	// ctx 开启事务时候的 context，提交和回滚的时候传给中间件
+	ctx context.Context
+	// savepoints 已经创建的保存点数量，用于 DoTx 生成保存点的名字
+	savepoints int
+	// done 在 commit 或者 rollback 之后修改为 true，
+	// 结束了的事务不会再被当成 context 里面的事务
+	done bool
+	// onCommit 和 onRollback 是 OnCommit 和 OnRollback 注册的回调
+	onCommit   []func(ctx context.Context)
+	onRollback []func(ctx context.Context)
+	// marks 还没有释放的保存点，用于回滚到保存点的时候丢弃保存点之后注册的回调
+	marks []hookMark
 }
 
 // getCore 返回Tx对象内部字段*DB结构体里的core核心组件
@@ -33,31 +69,147 @@ func (t *Tx) getCore() core {
 	return t.db.core
 }
 
-// queryContext 对事物内部QueryContext进行封装
-func (t *Tx) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+// Dialect 返回数据库方言
+func (t *Tx) Dialect() Dialect {
+	return t.db.dialect
+}
+
+// Registry 返回模型的元数据注册中心
+func (t *Tx) Registry() model.Registry {
+	return t.db.r
+}
+
+// QueryContext 对事务内部QueryContext进行封装，不经过中间件
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
 	return t.tx.QueryContext(ctx, query, args...)
 }
 
-// execContext 对事务内部ExecContext进行封装
-func (t *Tx) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+// ExecContext 对事务内部ExecContext进行封装，不经过中间件
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	return t.tx.ExecContext(ctx, query, args...)
 }
 
 // Commit 提交事务
 func (t *Tx) Commit() error {
-	return t.tx.Commit()
+	return t.end(TypeCommit, t.tx.Commit)
 }
 
 // Rollback 回滚事务
 func (t *Tx) Rollback() error {
-	return t.tx.Rollback()
+	return t.end(TypeRollback, t.tx.Rollback)
 }
 
 // RollbackIfNotCommit 如果事务没有提交，则回滚事务
 func (t *Tx) RollbackIfNotCommit() error {
-	err := t.tx.Rollback()
+	err := t.Rollback()
 	if err != sql.ErrTxDone {
 		return err
 	}
 	return nil
 }
+
+// end 经过中间件提交或者回滚事务
+func (t *Tx) end(typ string, fn func() error) error {
+	// 事务开启时候的 context 可能已经被取消了，这里只保留里面的值
+	ctx := context.Background()
+	if t.ctx != nil {
+		ctx = context.WithoutCancel(t.ctx)
+	}
+	err := handle(ctx, t.db.core, &QueryContext{
+		Type:    typ,
+		Builder: txStatement(typ),
+	}, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		err := fn()
+		t.done = true
+		return &QueryResult{Err: err}
+	}).Err
+	if err == nil {
+		t.runEndHooks(ctx, typ)
+	}
+	return err
+}
+
+// DoTx 在当前事务里面创建一个保存点，然后执行 fn。
+// 如果 fn 返回错误或者发生 panic，那么回滚到保存点，否则释放保存点。
+// 回滚到保存点只会撤销 fn 里面的修改，外层的事务还是可以继续执行和提交。
+// 和 DB.DoTx 一样，panic 会在回滚之后继续往上抛。
+// fn 拿到的还是同一个 Tx，所以在 fn 里面调用 DoTx 可以继续嵌套。
+func (t *Tx) DoTx(ctx context.Context, fn FN) (err error) {
+	t.savepoints++
+	name := fmt.Sprintf("sp_%d", t.savepoints)
+	if err = t.Savepoint(ctx, name); err != nil {
+		return err
+	}
+
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			e := t.RollbackTo(ctx, name)
+			if e != nil {
+				err = errs.NewErrFailToRollbackSavepoint(name, err, e, panicked)
+			}
+		} else {
+			err = t.ReleaseSavepoint(ctx, name)
+		}
+	}()
+
+	err = fn(contextWithTx(ctx, t), t)
+	panicked = false
+	return err
+}
+
+// Savepoint 创建名字为 name 的保存点
+// name 只能包含字母、数字和下划线，同名的保存点会覆盖之前的保存点
+func (t *Tx) Savepoint(ctx context.Context, name string) error {
+	return t.savepoint(ctx, TypeSavepoint, name)
+}
+
+// RollbackTo 回滚到名字为 name 的保存点，保存点之前的修改会保留，事务也不会结束
+func (t *Tx) RollbackTo(ctx context.Context, name string) error {
+	return t.savepoint(ctx, TypeRollbackTo, name)
+}
+
+// ReleaseSavepoint 释放名字为 name 的保存点，保存点之后的修改会保留
+func (t *Tx) ReleaseSavepoint(ctx context.Context, name string) error {
+	return t.savepoint(ctx, TypeReleaseSavepoint, name)
+}
+
+// savepoint 经过中间件执行保存点相关的语句
+func (t *Tx) savepoint(ctx context.Context, typ string, name string) error {
+	if name == "" || !isIdentifier(name) {
+		return errs.NewErrInvalidSavepoint(name)
+	}
+	quoter := t.db.dialect.quoter()
+	stmt := txStatement(fmt.Sprintf("%s %c%s%c", typ, quoter, name, quoter))
+	err := handle(ctx, t.db.core, &QueryContext{
+		Type:    typ,
+		Builder: stmt,
+	}, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		_, err := t.tx.ExecContext(ctx, string(stmt))
+		return &QueryResult{Err: err}
+	}).Err
+	if err == nil {
+		t.markSavepoint(ctx, typ, name)
+	}
+	return err
+}
+
+// runInTx 在事务中执行 fn
+// 如果 sess 本身就是一个事务，那么直接复用；
+// 如果 sess 是 DB 或者 Conn，那么开启一个新事务，fn 返回错误的时候回滚；
+// 其它无法开启事务的会话返回错误，避免在没有事务的情况下执行
+func runInTx(ctx context.Context, sess Session, fn func(ctx context.Context, sess Session) error) error {
+	txFn := func(ctx context.Context, tx *Tx) error {
+		return fn(ctx, tx)
+	}
+	switch s := sess.(type) {
+	case *Tx:
+		return fn(ctx, s)
+	case *DB:
+		return s.DoTx(ctx, txFn, nil)
+	case *Conn:
+		return s.DoTx(ctx, txFn, nil)
+	default:
+		return errs.NewErrTxUnsupported(sess)
+	}
+}
@@ -3,8 +3,11 @@ package sorm
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/model"
 	"testing"
 )
 
@@ -55,3 +58,268 @@ func TestTx_Rollback(t *testing.T) {
 	err = tx.Rollback()
 	assert.Nil(t, err)
 }
+
+func TestTx_DoTx(t *testing.T) {
+	testErr := errors.New("test error")
+	testCases := []struct {
+		name    string
+		mock    func(mock sqlmock.Sqlmock)
+		fn      FN
+		wantErr error
+	}{
+		{
+			name: "release",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("DELETE FROM `test_model` WHERE `id` = ?;").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("RELEASE SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return NewDeleter[TestModel](tx).Where(C("Id").EQ(1)).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return testErr
+			},
+			wantErr: testErr,
+		},
+		{
+			name: "nested",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("SAVEPOINT `sp_2`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_2`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("RELEASE SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				// 内层失败不影响外层
+				err := tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+					return testErr
+				})
+				if err != testErr {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			name: "savepoint error",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnError(testErr)
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return nil
+			},
+			wantErr: testErr,
+		},
+		{
+			name: "rollback error",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnError(sql.ErrConnDone)
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return testErr
+			},
+			wantErr: errs.NewErrFailToRollbackSavepoint("sp_1", testErr, sql.ErrConnDone, false),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mock.ExpectBegin()
+			tc.mock(mock)
+			tx, err := db.BeginTx(context.Background(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tx.DoTx(context.Background(), tc.fn)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTx_DoTx_Panic(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 回滚到保存点之后 panic 继续往上抛
+	assert.PanicsWithValue(t, "test panic", func() {
+		_ = tx.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+			panic("test panic")
+		})
+	})
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_Savepoint(t *testing.T) {
+	db := memoryDBWithDB("tx_savepoint", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		if err := NewInserter[TestModel](tx).Values(&TestModel{Id: 1, FirstName: "Tom", LastName: &sql.NullString{String: "Cat", Valid: true}}).Exec(ctx).Err(); err != nil {
+			return err
+		}
+		err := tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+			if err := NewInserter[TestModel](tx).Values(&TestModel{Id: 2, FirstName: "Jerry", LastName: &sql.NullString{String: "Mouse", Valid: true}}).Exec(ctx).Err(); err != nil {
+				return err
+			}
+			return errors.New("test error")
+		})
+		assert.Equal(t, errors.New("test error"), err)
+
+		if err = tx.Savepoint(ctx, "before_deng"); err != nil {
+			return err
+		}
+		if err = NewInserter[TestModel](tx).Values(&TestModel{Id: 3, FirstName: "Deng", LastName: &sql.NullString{String: "Ming", Valid: true}}).Exec(ctx).Err(); err != nil {
+			return err
+		}
+		return tx.ReleaseSavepoint(ctx, "before_deng")
+	}, nil)
+	assert.Nil(t, err)
+
+	got, err := NewSelector[TestModel](db).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 1, FirstName: "Tom", LastName: &sql.NullString{String: "Cat", Valid: true}},
+		{Id: 3, FirstName: "Deng", LastName: &sql.NullString{String: "Ming", Valid: true}},
+	}, got)
+}
+
+func TestTx_Savepoint_InvalidName(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectBegin()
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Savepoint(context.Background(), "sp; DROP TABLE user")
+	assert.Equal(t, errs.NewErrInvalidSavepoint("sp; DROP TABLE user"), err)
+	err = tx.RollbackTo(context.Background(), "")
+	assert.Equal(t, errs.NewErrInvalidSavepoint(""), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// countingSession 嵌入 *DB 的代理，统计执行的查询
+type countingSession struct {
+	*DB
+	queries []string
+}
+
+func (s *countingSession) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	s.queries = append(s.queries, query)
+	return s.DB.QueryContext(ctx, query, args...)
+}
+
+// plainSession 直接使用 *sql.DB 的会话，没有中间件等配置
+type plainSession struct {
+	db *sql.DB
+}
+
+func (s plainSession) Dialect() Dialect {
+	return SQLite3
+}
+
+func (s plainSession) Registry() model.Registry {
+	return model.NewRegistry()
+}
+
+func (s plainSession) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, query, args...)
+}
+
+func (s plainSession) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(ctx, query, args...)
+}
+
+func TestSession_Custom(t *testing.T) {
+	var mdlCnt int
+	db := memoryDBWithDB("custom_session", t)
+	db.dialect = SQLite3
+	db.ms = []Middleware{func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			mdlCnt++
+			return next(ctx, qc)
+		}
+	}}
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 通用的方法同时支持 DB、Tx 和自定义的会话
+	insert := func(sess Session, id int64) error {
+		return NewInserter[TestModel](sess).Values(&TestModel{
+			Id: id, FirstName: "Tom", LastName: &sql.NullString{String: "Cat", Valid: true},
+		}).Exec(ctx).Err()
+	}
+	count := func(sess Session) (int, error) {
+		res, err := NewSelector[TestModel](sess).GetMulti(ctx)
+		return len(res), err
+	}
+
+	assert.Nil(t, insert(db, 1))
+	assert.Nil(t, db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		return insert(tx, 2)
+	}, nil))
+
+	// 嵌入 *DB 的会话沿用 DB 的中间件
+	proxy := &countingSession{DB: db}
+	mdlCnt = 0
+	cnt, err := count(proxy)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, cnt)
+	assert.Equal(t, []string{"SELECT * FROM `test_model`;"}, proxy.queries)
+	assert.Equal(t, 1, mdlCnt)
+
+	// 其它的会话不经过中间件
+	plain := plainSession{db: db.db}
+	mdlCnt = 0
+	assert.Nil(t, insert(plain, 3))
+	cnt, err = count(plain)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, cnt)
+	assert.Equal(t, 0, mdlCnt)
+}
@@ -0,0 +1,102 @@
+package sorm
+
+import (
+	"context"
+	"log"
+	"slices"
+)
+
+// hookMark 保存点创建的时候已经注册的回调数量
+type hookMark struct {
+	name       string
+	onCommit   int
+	onRollback int
+}
+
+// OnCommit 注册一个回调，在事务提交成功之后按照注册的顺序执行，例如发送邮件、清除缓存。
+// 在保存点里面注册的回调，回滚到保存点的时候会被丢弃；提交失败、回滚或者事务已经结束的时候不会执行。
+// 回调里面的 panic 会被恢复并且记录日志，不影响其它的回调
+func (t *Tx) OnCommit(fn func(ctx context.Context)) {
+	t.onCommit = append(t.onCommit, fn)
+}
+
+// OnRollback 注册一个回调，在事务回滚成功之后按照注册的顺序执行。
+// 在保存点里面注册的回调，回滚到保存点成功之后就会执行，因为保存点之后的修改已经被撤销了。
+// 回调里面的 panic 会被恢复并且记录日志，不影响其它的回调
+func (t *Tx) OnRollback(fn func(ctx context.Context)) {
+	t.onRollback = append(t.onRollback, fn)
+}
+
+// AfterCommit 如果 ctx 里面有 db 的事务，那么在事务提交成功之后执行 fn，否则立刻执行 fn
+// 这样仓储层不需要关心调用者有没有开启事务
+func (db *DB) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if tx, ok := db.txFromContext(ctx); ok {
+		tx.OnCommit(fn)
+		return
+	}
+	runHooks(ctx, []func(ctx context.Context){fn})
+}
+
+// runEndHooks 事务提交或者回滚成功之后执行对应的回调，回调只会执行一次
+func (t *Tx) runEndHooks(ctx context.Context, typ string) {
+	hooks := t.onRollback
+	if typ == TypeCommit {
+		hooks = t.onCommit
+	}
+	t.onCommit, t.onRollback, t.marks = nil, nil, nil
+	runHooks(ctx, hooks)
+}
+
+// markSavepoint 保存点语句执行成功之后维护回调
+func (t *Tx) markSavepoint(ctx context.Context, typ string, name string) {
+	switch typ {
+	case TypeSavepoint:
+		// 同名的保存点会覆盖之前的保存点
+		if idx := t.findMark(name); idx >= 0 {
+			t.marks = append(t.marks[:idx], t.marks[idx+1:]...)
+		}
+		t.marks = append(t.marks, hookMark{name: name, onCommit: len(t.onCommit), onRollback: len(t.onRollback)})
+	case TypeRollbackTo:
+		idx := t.findMark(name)
+		if idx < 0 {
+			return
+		}
+		// 保存点本身还在，之后的保存点都没有了
+		m := t.marks[idx]
+		t.marks = t.marks[:idx+1]
+		// 回调里面可能会注册新的回调，复制一份，避免 append 覆盖还没有执行的回调
+		hooks := slices.Clone(t.onRollback[m.onRollback:])
+		t.onCommit = t.onCommit[:m.onCommit]
+		t.onRollback = t.onRollback[:m.onRollback]
+		runHooks(ctx, hooks)
+	case TypeReleaseSavepoint:
+		// 释放之后回调归属于外层
+		if idx := t.findMark(name); idx >= 0 {
+			t.marks = t.marks[:idx]
+		}
+	}
+}
+
+// findMark 从后往前查找名字为 name 的保存点
+func (t *Tx) findMark(name string) int {
+	for i := len(t.marks) - 1; i >= 0; i-- {
+		if t.marks[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// runHooks 按照顺序执行回调，单个回调的 panic 不影响其它回调
+func runHooks(ctx context.Context, hooks []func(ctx context.Context)) {
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("orm: 事务回调 panic: %v", r)
+				}
+			}()
+			hook(ctx)
+		}()
+	}
+}
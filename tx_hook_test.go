@@ -0,0 +1,239 @@
+package sorm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTx_Hooks(t *testing.T) {
+	testErr := errors.New("test error")
+	testCases := []struct {
+		name    string
+		mock    func(mock sqlmock.Sqlmock)
+		fn      func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "commit",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error {
+				tx.OnCommit(record("commit 1"))
+				tx.OnRollback(record("rollback"))
+				tx.OnCommit(record("commit 2"))
+				return nil
+			},
+			want: []string{"commit 1", "commit 2"},
+		},
+		{
+			name: "rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error {
+				tx.OnCommit(record("commit"))
+				tx.OnRollback(record("rollback 1"))
+				tx.OnRollback(record("rollback 2"))
+				return testErr
+			},
+			want:    []string{"rollback 1", "rollback 2"},
+			wantErr: testErr,
+		},
+		{
+			// 提交失败的时候两种回调都不执行
+			name: "commit failed",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit().WillReturnError(testErr)
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error {
+				tx.OnCommit(record("commit"))
+				tx.OnRollback(record("rollback"))
+				return nil
+			},
+			wantErr: testErr,
+		},
+		{
+			// 回滚到保存点的时候，立刻执行保存点里面的 OnRollback，丢弃保存点里面的 OnCommit
+			name: "savepoint rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error {
+				tx.OnCommit(record("outer commit"))
+				_ = tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+					tx.OnCommit(record("inner commit"))
+					tx.OnRollback(record("inner rollback"))
+					return testErr
+				})
+				tx.OnCommit(record("outer commit 2"))
+				return nil
+			},
+			want: []string{"inner rollback", "outer commit", "outer commit 2"},
+		},
+		{
+			// 回滚到保存点的时候，回调里面注册的新回调不会覆盖还没有执行的回调
+			name: "savepoint rollback register",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error {
+				_ = tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+					tx.OnRollback(func(ctx context.Context) {
+						record("inner rollback 1")(ctx)
+						tx.OnRollback(record("late rollback 1"))
+						tx.OnRollback(record("late rollback 2"))
+					})
+					tx.OnRollback(record("inner rollback 2"))
+					return testErr
+				})
+				tx.OnCommit(record("outer commit"))
+				return nil
+			},
+			want: []string{"inner rollback 1", "inner rollback 2", "outer commit"},
+		},
+		{
+			// 释放保存点之后回调归属于外层事务
+			name: "savepoint release",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("SAVEPOINT `sp_2`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("RELEASE SAVEPOINT `sp_2`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error {
+				tx.OnRollback(record("outer rollback"))
+				return tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+					tx.OnCommit(record("commit"))
+					err := tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+						tx.OnRollback(record("inner rollback"))
+						return nil
+					})
+					if err != nil {
+						return err
+					}
+					return testErr
+				})
+			},
+			want:    []string{"inner rollback", "outer rollback"},
+			wantErr: testErr,
+		},
+		{
+			// panic 不影响其它回调
+			name: "panic",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string) func(ctx context.Context)) error {
+				tx.OnCommit(record("commit 1"))
+				tx.OnCommit(func(ctx context.Context) {
+					panic("hook panic")
+				})
+				tx.OnCommit(record("commit 2"))
+				return nil
+			},
+			want: []string{"commit 1", "commit 2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.mock(mock)
+			var got []string
+			record := func(s string) func(ctx context.Context) {
+				return func(ctx context.Context) {
+					got = append(got, s)
+				}
+			}
+			err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+				return tc.fn(ctx, tx, record)
+			}, nil)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTx_Hooks_Manual(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnt := 0
+	tx.OnCommit(func(ctx context.Context) {
+		cnt++
+	})
+	assert.Nil(t, tx.Commit())
+	// 回调只执行一次
+	assert.Nil(t, tx.RollbackIfNotCommit())
+	assert.Equal(t, 1, cnt)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDB_AfterCommit(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	// 没有事务的时候立刻执行
+	db.AfterCommit(context.Background(), func(ctx context.Context) {
+		got = append(got, "no tx")
+	})
+	assert.Equal(t, []string{"no tx"}, got)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+		db.AfterCommit(ctx, func(ctx context.Context) {
+			got = append(got, "tx")
+		})
+		assert.Equal(t, []string{"no tx"}, got)
+		return nil
+	}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"no tx", "tx"}, got)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
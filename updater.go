@@ -3,6 +3,21 @@ package sorm
 import (
 	"context"
 	"github.com/xzhHas/sorm/internal/errs"
+	"github.com/xzhHas/sorm/model"
+	"reflect"
+	"slices"
+)
+
+// updateMode 决定是否根据结构体自动生成 SET 部分
+type updateMode int
+
+const (
+	// updateExplicit 只更新通过 Set 和 SetMap 指定的列
+	updateExplicit updateMode = iota
+	// updateNonZero 更新结构体里面所有非零值的字段
+	updateNonZero
+	// updateAll 更新结构体里面的所有字段
+	updateAll
 )
 
 // Updater 结构体表示一个用于执行数据库更新操作的对象
@@ -14,17 +29,28 @@ type Updater[T any] struct {
 	assigns []Assignable
 	// val 指向要更新的具体数据类型的指针
 	val *T
+	// table 要更新的表，没有指定的时候使用 T 对应的表，也可以是 JOIN
+	table TableReference
+	// setMap 以 map 形式给出的更新的列，key 是字段名或者列名
+	setMap map[string]any
 	// where 存储更新操作的 WHERE 条件
 	where []Predicate
+	// mode 不是 updateExplicit 的时候，根据 val 生成 SET 部分和主键条件
+	mode updateMode
+	// allowFullTable 允许没有 WHERE 条件，也就是更新整个表
+	allowFullTable bool
+	// only 和 omit 用于筛选根据结构体生成的列
+	only []string
+	omit []string
 	// sess 是与数据库交互的会话对象
-	sess session
+	sess Session
 }
 
 // NewUpdater 创建并返回一个新的 Updater 实例
-// - sess: 一个 session 对象，用于获取核心(core)和会话信息(session)
+// - sess: 一个 Session 对象，用于获取核心(core)和会话信息(session)
 // - *Updater[T]: 返回一个初始化了的 Updater 实例，准备好进行更新操作
-func NewUpdater[T any](sess session) *Updater[T] {
-	c := sess.getCore()
+func NewUpdater[T any](sess Session) *Updater[T] {
+	c := coreOf(sess)
 	return &Updater[T]{
 		builder: builder{
 			core:    c,
@@ -44,6 +70,49 @@ func (u *Updater[T]) Update(t *T) *Updater[T] {
 	return u
 }
 
+// Table 设置要更新的表，可以是 JOIN，此时更新的是最左边的表，其它表的列可以出现在 SET 和 WHERE 里面
+// MySQL 生成 UPDATE t JOIN ... SET ...；SQLite 改写成 UPDATE t SET ... FROM ... WHERE ...，
+// 此时只支持使用 ON 的内连接，连接条件会被放到 WHERE 里面
+func (u *Updater[T]) Table(tbl TableReference) *Updater[T] {
+	u.table = tbl
+	return u
+}
+
+// AllowFullTable 允许在没有 WHERE 条件的情况下更新整个表
+// 默认情况下没有 WHERE 的 UPDATE 会返回 ErrMissingWhere
+func (u *Updater[T]) AllowFullTable() *Updater[T] {
+	u.allowFullTable = true
+	return u
+}
+
+// UpdateNonZero 根据 t 更新所有非零值的字段，并且自动加上主键作为 WHERE 条件
+// 主键本身不会出现在 SET 部分，主键是零值的时候返回错误。
+// 可以通过 Only 和 Omit 筛选字段，通过 Set 和 SetMap 指定的列优先
+func (u *Updater[T]) UpdateNonZero(t *T) *Updater[T] {
+	u.val = t
+	u.mode = updateNonZero
+	return u
+}
+
+// UpdateAll 和 UpdateNonZero 类似，但是零值的字段也会被更新
+func (u *Updater[T]) UpdateAll(t *T) *Updater[T] {
+	u.val = t
+	u.mode = updateAll
+	return u
+}
+
+// Only 只更新 fields 里面的字段，只对 UpdateNonZero 和 UpdateAll 生效
+func (u *Updater[T]) Only(fields ...string) *Updater[T] {
+	u.only = fields
+	return u
+}
+
+// Omit 不更新 fields 里面的字段，只对 UpdateNonZero 和 UpdateAll 生效
+func (u *Updater[T]) Omit(fields ...string) *Updater[T] {
+	u.omit = fields
+	return u
+}
+
 // Set 方法用于设置更新器将要更新的值
 // 该方法接收一个或多个可分配的值，并将它们存储在 Updater 结构体中，以便后续更新操作使用
 // 通过返回 *Updater[T] 类型的指针，该方法支持方法链调用，允许在设置值之后立即进行其他操作
@@ -54,10 +123,23 @@ func (u *Updater[T]) Set(assigns ...Assignable) *Updater[T] {
 	return u
 }
 
+// SetMap 以 map 的形式设置要更新的列，适用于列不固定的场景，可以和 Set 一起使用
+// key 可以是字段名，也可以是列名，value 可以是普通的值，也可以是 C("Age").Add(1) 之类的表达式。
+// 生成的赋值语句排在 Set 的后面，并且按照字段在模型里面定义的顺序排列。多次调用的时候会合并
+func (u *Updater[T]) SetMap(vals map[string]any) *Updater[T] {
+	if u.setMap == nil {
+		u.setMap = make(map[string]any, len(vals))
+	}
+	for k, v := range vals {
+		u.setMap[k] = v
+	}
+	return u
+}
+
 // Build 方法用于构建更新语句
 func (u *Updater[T]) Build() (*Query, error) {
 	// 检查是否有更新的列
-	if len(u.assigns) == 0 {
+	if u.mode == updateExplicit && len(u.assigns) == 0 && len(u.setMap) == 0 {
 		return nil, errs.ErrNoUpdatedColumns
 	}
 	// 如果没有提供值，则初始化为T类型的零值
@@ -69,19 +151,69 @@ func (u *Updater[T]) Build() (*Query, error) {
 		return nil, err
 	}
 	u.model = model
+	assigns := u.assigns
+	if len(u.setMap) > 0 {
+		fds, err := u.mapFields(u.setMap)
+		if err != nil {
+			return nil, err
+		}
+		assigns = slices.Grow(slices.Clone(assigns), len(fds))
+		for _, fd := range fds {
+			val, ok := u.setMap[fd.GoName]
+			if !ok {
+				val = u.setMap[fd.ColName]
+			}
+			assigns = append(assigns, Assign(fd.GoName, val))
+		}
+	}
+	where := u.where
+	if u.mode != updateExplicit {
+		cols, pkWhere, err := u.structAssigns(model, assigns)
+		if err != nil {
+			return nil, err
+		}
+		if len(cols) == 0 && len(assigns) == 0 {
+			return nil, errs.ErrNoUpdatedColumns
+		}
+		assigns = append(cols, assigns...)
+		where = append(pkWhere, where...)
+	}
+	if !u.allowFullTable {
+		if err = u.checkWhere(u.table, where); err != nil {
+			return nil, err
+		}
+	}
+	target, err := targetTable(u.table)
+	if err != nil {
+		return nil, err
+	}
+	var from []TableReference
 	u.sb.WriteString("UPDATE ")
-	u.quote(model.TableName)
+	if join, ok := u.table.(Join); ok && !u.dialect.supportsJoinUpdate() {
+		tables, on, err := flattenJoin(join)
+		if err != nil {
+			return nil, err
+		}
+		from = tables[1:]
+		where = append(on, where...)
+		err = u.buildTable(target)
+	} else {
+		err = u.buildTable(u.table)
+	}
+	if err != nil {
+		return nil, err
+	}
 	u.sb.WriteString(" SET ")
 	// 准备更新的列
 	val := u.valCreator(u.val, model)
 	// 遍历要更新的列，构建 SET 语句
-	for i, a := range u.assigns {
+	for i, a := range assigns {
 		if i > 0 {
 			u.sb.WriteByte(',')
 		}
 		switch assign := a.(type) {
 		case Column:
-			if err = u.buildColumn(assign.table, assign.name); err != nil {
+			if err = u.buildSetColumn(assign.table, assign.name); err != nil {
 				return nil, err
 			}
 			u.sb.WriteString("=?")
@@ -98,10 +230,22 @@ func (u *Updater[T]) Build() (*Query, error) {
 			return nil, errs.NewErrUnsupportedAssignableType(a)
 		}
 	}
+	// 不支持 UPDATE ... JOIN 的时候，其它表放在 FROM 里面
+	if len(from) > 0 {
+		u.sb.WriteString(" FROM ")
+		for i, tab := range from {
+			if i > 0 {
+				u.sb.WriteByte(',')
+			}
+			if err = u.buildTable(tab); err != nil {
+				return nil, err
+			}
+		}
+	}
 	// 构建WHERE子句
-	if len(u.where) > 0 {
+	if len(where) > 0 {
 		u.sb.WriteString(" WHERE ")
-		if err = u.buildPredicates(u.where); err != nil {
+		if err = u.buildPredicates(where); err != nil {
 			return nil, err
 		}
 	}
@@ -112,17 +256,83 @@ func (u *Updater[T]) Build() (*Query, error) {
 	}, nil
 }
 
+// structAssigns 根据 val 生成需要更新的列和主键条件
+// 已经出现在 explicit 里面的字段不会重复生成，列按照字段定义的顺序排列
+func (u *Updater[T]) structAssigns(m *model.Model, explicit []Assignable) ([]Assignable, []Predicate, error) {
+	if len(m.PrimaryKeys) == 0 {
+		return nil, nil, errs.ErrNoPrimaryKey
+	}
+	val := u.valCreator(u.val, m)
+	pkWhere := make([]Predicate, 0, len(m.PrimaryKeys))
+	for _, pk := range m.PrimaryKeys {
+		pkVal, err := val.Field(pk.GoName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if reflect.ValueOf(pkVal).IsZero() {
+			return nil, nil, errs.NewErrZeroPrimaryKey(pk.GoName)
+		}
+		pkWhere = append(pkWhere, C(pk.GoName).EQ(pkVal))
+	}
+	for _, fd := range append(slices.Clone(u.only), u.omit...) {
+		if _, ok := m.FieldMap[fd]; !ok {
+			return nil, nil, errs.NewErrUnknownField(fd)
+		}
+	}
+	cols := make([]Assignable, 0, len(m.Fields))
+	for _, fd := range m.Fields {
+		if fd.PrimaryKey || slices.Contains(u.omit, fd.GoName) ||
+			(len(u.only) > 0 && !slices.Contains(u.only, fd.GoName)) ||
+			slices.ContainsFunc(explicit, func(a Assignable) bool {
+				switch assign := a.(type) {
+				case Column:
+					return assign.name == fd.GoName
+				case Assignment:
+					return assign.column == fd.GoName
+				}
+				return false
+			}) {
+			continue
+		}
+		if u.mode == updateNonZero {
+			fdVal, err := val.Field(fd.GoName)
+			if err != nil {
+				return nil, nil, err
+			}
+			if reflect.ValueOf(fdVal).IsZero() {
+				continue
+			}
+		}
+		cols = append(cols, C(fd.GoName))
+	}
+	return cols, pkWhere, nil
+}
+
 // buildAssignment 构建并添加一个赋值操作到更新语句中
 // assign: 表示一个列和值的映射，其中列名和值分别由Assignment类型的column和val字段表示
 // 该函数将使用这一映射来构建更新语句的一部分
 func (u *Updater[T]) buildAssignment(assign Assignment) error {
-	if err := u.buildColumn(nil, assign.column); err != nil {
+	if err := u.buildSetColumn(assign.table, assign.column); err != nil {
 		return err
 	}
 	u.sb.WriteByte('=')
 	return u.buildExpression(assign.val)
 }
 
+// buildSetColumn 构造 SET 里面被赋值的列
+// 只有支持多表 UPDATE 的数据库才允许带上表名，例如 SQLite 只能使用列名
+func (u *Updater[T]) buildSetColumn(table TableReference, fd string) error {
+	if u.dialect.supportsJoinUpdate() {
+		return u.buildColumn(table, fd)
+	}
+	colName, err := u.colName(table, fd)
+	if err != nil {
+		return err
+	}
+	u.quote(colName)
+	return nil
+}
+
 // Where 方法用于设置更新操作的条件
 // 它允许调用者指定一个或多个谓词来限定哪些记录应该被更新
 // 该方法通过返回带有修改过的内部状态的 Updater 实例，支持方法链调用
@@ -134,13 +344,14 @@ func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
 }
 
 // Exec 执行更新操作
-// 该方法首先通过调用 Build 方法构建更新查询，然后使用当前会话执行构建的查询
+// 和其它语句一样，会经过中间件
 // 它接受一个 context.Context 参数，用于控制请求的超时和取消
 func (u *Updater[T]) Exec(ctx context.Context) Result {
-	q, err := u.Build()
-	if err != nil {
-		return Result{err: err}
+	if sdb := u.sharding; sdb != nil {
+		return u.shardingExec(ctx, sdb)
+	}
+	if m := u.partitionModel(new(T), u.table); m != nil {
+		return u.partitionExec(ctx, m)
 	}
-	res, err := u.sess.execContext(ctx, q.SQL, q.Args...)
-	return Result{err: err, res: res}
+	return exec(ctx, u.sess, u.core, newQueryContext[T](u.core, TypeUpdate, u))
 }
@@ -1,6 +1,8 @@
 package sorm
 
 import (
+	"context"
+	"database/sql"
 	"github.com/stretchr/testify/assert"
 	"github.com/xzhHas/sorm/internal/errs"
 	"testing"
@@ -23,7 +25,7 @@ func TestUpdater_Build(t *testing.T) {
 			name: "single column",
 			u: NewUpdater[TestModel](db).Update(&TestModel{
 				Age: 18,
-			}).Set(C("Age")),
+			}).Set(C("Age")).AllowFullTable(),
 			want: &Query{
 				SQL:  "UPDATE `test_model` SET `age`=?;",
 				Args: []any{int8(18)},
@@ -34,7 +36,7 @@ func TestUpdater_Build(t *testing.T) {
 			u: NewUpdater[TestModel](db).Update(&TestModel{
 				Age:       18,
 				FirstName: "Tom",
-			}).Set(C("Age"), Assign("FirstName", "DaMing")),
+			}).Set(C("Age"), Assign("FirstName", "DaMing")).AllowFullTable(),
 			want: &Query{
 				SQL:  "UPDATE `test_model` SET `age`=?,`first_name`=?;",
 				Args: []any{int8(18), "DaMing"},
@@ -57,7 +59,7 @@ func TestUpdater_Build(t *testing.T) {
 			u: NewUpdater[TestModel](db).Update(&TestModel{
 				Age:       18,
 				FirstName: "Tom",
-			}).Set(Assign("Age", C("Age").Add(1))),
+			}).Set(Assign("Age", C("Age").Add(1))).AllowFullTable(),
 			want: &Query{
 				SQL:  "UPDATE `test_model` SET `age`=`age` + ?;",
 				Args: []any{1},
@@ -68,12 +70,130 @@ func TestUpdater_Build(t *testing.T) {
 			u: NewUpdater[TestModel](db).Update(&TestModel{
 				Age:       18,
 				FirstName: "Tom",
-			}).Set(Assign("Age", Raw("`age`+?", 1))),
+			}).Set(Assign("Age", Raw("`age`+?", 1))).AllowFullTable(),
 			want: &Query{
 				SQL:  "UPDATE `test_model` SET `age`=`age`+?;",
 				Args: []any{1},
 			},
 		},
+		{
+			// 按照字段定义的顺序排列，key 可以是列名
+			name: "set map",
+			u: NewUpdater[TestModel](db).SetMap(map[string]any{
+				"age":       C("Age").Add(1),
+				"FirstName": "Tom",
+				"last_name": nil,
+			}).Where(C("Id").EQ(1)),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=?,`age`=`age` + ?,`last_name`=? WHERE `id` = ?;",
+				Args: []any{"Tom", 1, nil, 1},
+			},
+		},
+		{
+			name: "set and set map",
+			u: NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).Set(C("Age")).
+				SetMap(map[string]any{"FirstName": "Tom"}).SetMap(map[string]any{"Id": 2}).AllowFullTable(),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=?,`id`=?,`first_name`=?;",
+				Args: []any{int8(18), 2, "Tom"},
+			},
+		},
+		{
+			name:    "set map unknown key",
+			u:       NewUpdater[TestModel](db).SetMap(map[string]any{"Invalid": 1}),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "set map duplicate key",
+			u:       NewUpdater[TestModel](db).SetMap(map[string]any{"Age": 1, "age": 2}),
+			wantErr: errs.NewErrDuplicateField("Age"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.u.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, q)
+		})
+	}
+}
+
+func TestUpdater_UpdateStruct(t *testing.T) {
+	db := MemoryDB(t)
+	testCases := []struct {
+		name    string
+		u       QueryBuilder
+		want    *Query
+		wantErr error
+	}{
+		{
+			// 零值的 Age 和 nil 的 LastName 被跳过
+			name: "non zero",
+			u:    NewUpdater[TestModel](db).UpdateNonZero(&TestModel{Id: 1, FirstName: "Tom"}),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=? WHERE `id` = ?;",
+				Args: []any{"Tom", int64(1)},
+			},
+		},
+		{
+			name: "all",
+			u:    NewUpdater[TestModel](db).UpdateAll(&TestModel{Id: 1, FirstName: "Tom"}),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=?,`age`=?,`last_name`=? WHERE `id` = ?;",
+				Args: []any{"Tom", int8(0), (*sql.NullString)(nil), int64(1)},
+			},
+		},
+		{
+			name: "only",
+			u: NewUpdater[TestModel](db).UpdateAll(&TestModel{Id: 1, FirstName: "Tom", Age: 18}).
+				Only("Age", "LastName"),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=?,`last_name`=? WHERE `id` = ?;",
+				Args: []any{int8(18), (*sql.NullString)(nil), int64(1)},
+			},
+		},
+		{
+			name: "omit",
+			u: NewUpdater[TestModel](db).UpdateNonZero(&TestModel{Id: 1, FirstName: "Tom", Age: 18}).
+				Omit("FirstName"),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=? WHERE `id` = ?;",
+				Args: []any{int8(18), int64(1)},
+			},
+		},
+		{
+			// Set 指定的列不会重复生成，额外的 WHERE 条件和主键条件一起使用
+			name: "with set and where",
+			u: NewUpdater[TestModel](db).UpdateNonZero(&TestModel{Id: 1, FirstName: "Tom", Age: 18}).
+				Set(Assign("Age", C("Age").Add(1))).Where(C("Age").LT(100)),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=?,`age`=`age` + ? WHERE (`id` = ?) AND (`age` < ?);",
+				Args: []any{"Tom", 1, int64(1), 100},
+			},
+		},
+		{
+			name:    "zero primary key",
+			u:       NewUpdater[TestModel](db).UpdateNonZero(&TestModel{FirstName: "Tom"}),
+			wantErr: errs.NewErrZeroPrimaryKey("Id"),
+		},
+		{
+			name:    "no primary key",
+			u:       NewUpdater[testNoPKModel](db).UpdateAll(&testNoPKModel{Name: "Tom"}),
+			wantErr: errs.ErrNoPrimaryKey,
+		},
+		{
+			name:    "no columns",
+			u:       NewUpdater[TestModel](db).UpdateNonZero(&TestModel{Id: 1}),
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name:    "unknown field",
+			u:       NewUpdater[TestModel](db).UpdateAll(&TestModel{Id: 1}).Omit("Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -86,3 +206,136 @@ func TestUpdater_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdater_Join_Build(t *testing.T) {
+	o := TableOf(&TestModel{}).As("o")
+	a := TableOf(&testArchiveModel{}).As("a")
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		u       func(db *DB) QueryBuilder
+		want    *Query
+		wantErr error
+	}{
+		{
+			name:    "mysql join",
+			dialect: MySQL,
+			u: func(db *DB) QueryBuilder {
+				return NewUpdater[TestModel](db).Table(o.Join(a).On(o.C("Id").EQ(a.C("Id")))).
+					Set(o.C("Age").Assign(a.C("Age")), o.C("FirstName").Assign("Tom")).
+					Where(a.C("Age").GT(18))
+			},
+			want: &Query{
+				SQL: "UPDATE (`test_model` AS `o` JOIN `test_archive_model` AS `a` ON `o`.`id` = `a`.`id`) " +
+					"SET `o`.`age`=`a`.`age`,`o`.`first_name`=? WHERE `a`.`age` > ?;",
+				Args: []any{"Tom", 18},
+			},
+		},
+		{
+			name:    "sqlite join",
+			dialect: SQLite3,
+			u: func(db *DB) QueryBuilder {
+				return NewUpdater[TestModel](db).Table(o.Join(a).On(o.C("Id").EQ(a.C("Id")))).
+					Set(o.C("Age").Assign(a.C("Age")), Assign("FirstName", "Tom")).
+					Where(a.C("Age").GT(18))
+			},
+			want: &Query{
+				SQL: "UPDATE `test_model` AS `o` SET `age`=`a`.`age`,`first_name`=? " +
+					"FROM `test_archive_model` AS `a` WHERE (`o`.`id` = `a`.`id`) AND (`a`.`age` > ?);",
+				Args: []any{"Tom", 18},
+			},
+		},
+		{
+			// 多个 JOIN 的连接条件都会放到 WHERE 里面
+			name:    "sqlite multiple joins",
+			dialect: SQLite3,
+			u: func(db *DB) QueryBuilder {
+				b := TableOf(&testArchiveModel{}).As("b")
+				return NewUpdater[TestModel](db).
+					Table(o.Join(a).On(o.C("Id").EQ(a.C("Id"))).Join(b).On(a.C("Age").EQ(b.C("Age")))).
+					Set(Assign("FirstName", b.C("FirstName")))
+			},
+			want: &Query{
+				SQL: "UPDATE `test_model` AS `o` SET `first_name`=`b`.`first_name` " +
+					"FROM `test_archive_model` AS `a`,`test_archive_model` AS `b` " +
+					"WHERE (`o`.`id` = `a`.`id`) AND (`a`.`age` = `b`.`age`);",
+			},
+		},
+		{
+			name:    "sqlite left join",
+			dialect: SQLite3,
+			u: func(db *DB) QueryBuilder {
+				return NewUpdater[TestModel](db).Table(o.LeftJoin(a).On(o.C("Id").EQ(a.C("Id")))).
+					Set(Assign("Age", 1)).Where(o.C("Id").EQ(1))
+			},
+			wantErr: errs.NewErrUnsupportedJoin("LEFT JOIN"),
+		},
+		{
+			name:    "subquery condition",
+			dialect: SQLite3,
+			u: func(db *DB) QueryBuilder {
+				return NewUpdater[TestModel](db).Set(Assign("Age", 1)).
+					Where(C("Id").InQuery(NewSelector[testArchiveModel](db).Select(C("Id")).AsSubquery("sub")))
+			},
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=? WHERE `id` IN (SELECT `id` FROM `test_archive_model`);",
+				Args: []any{1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := MemoryDB(t, DBWithDialect(tc.dialect))
+			q, err := tc.u(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, q)
+		})
+	}
+}
+
+func TestUpdater_Join_Exec(t *testing.T) {
+	db := memoryDBWithDB("update_join", t)
+	db.dialect = SQLite3
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("CREATE TABLE IF NOT EXISTS `test_archive_model`(" +
+		"id INTEGER PRIMARY KEY, first_name TEXT NOT NULL, age INTEGER)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES " +
+		"(1, 'Tom', 18, ''), (2, 'Jerry', 20, ''), (3, 'Deng', 30, '')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_archive_model`(`id`,`first_name`,`age`) VALUES (1, 'Tom', 28), (3, 'Deng', 40)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	o := TableOf(&TestModel{}).As("o")
+	a := TableOf(&testArchiveModel{}).As("a")
+	res := NewUpdater[TestModel](db).Table(o.Join(a).On(o.C("Id").EQ(a.C("Id")))).
+		Set(o.C("Age").Assign(a.C("Age"))).Exec(ctx)
+	assert.Nil(t, res.Err())
+	affected, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	res = NewDeleter[TestModel](db).From(o.Join(a).On(o.C("Id").EQ(a.C("Id")))).Where(a.C("Age").GT(30)).Exec(ctx)
+	assert.Nil(t, res.Err())
+
+	got, err := NewSelector[TestModel](db).Select(C("Id"), C("Age")).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{{Id: 1, Age: 28}, {Id: 2, Age: 20}}, got)
+}
+
+type testNoPKModel struct {
+	Name string
+}